@@ -0,0 +1,382 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package lib
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+)
+
+// CBOR major types.
+const (
+	cborUint = iota
+	cborNegInt
+	cborBytes
+	cborText
+	cborArray
+	cborMap
+	cborTag
+	cborSimple
+)
+
+// cborIndefinite is the additional information value marking an
+// indefinite length item, and cborBreak is the byte terminating one.
+const (
+	cborIndefinite = 31
+	cborBreak      = 0xff
+)
+
+// cborMaxDepth is the maximum nesting depth of arrays, maps and tags
+// accepted by the decoder.
+const cborMaxDepth = 1000
+
+// errCBORBreak is returned by decode when a break byte is found.
+var errCBORBreak = errors.New("unexpected break")
+
+// cborDecoder decodes a single CBOR data item (RFC 8949) into native Go
+// values that can be converted to CEL values by types.DefaultTypeAdapter.
+type cborDecoder struct {
+	r     *bufio.Reader
+	depth int
+}
+
+// decodeCBOR decodes the single CBOR data item held in r.
+func decodeCBOR(r io.Reader) (interface{}, error) {
+	dec := cborDecoder{r: bufio.NewReader(r)}
+	v, err := dec.decode()
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	_, err = dec.r.ReadByte()
+	if err != io.EOF {
+		return nil, errors.New("unexpected data after top-level item")
+	}
+	return v, nil
+}
+
+func (d *cborDecoder) decode() (interface{}, error) {
+	major, info, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+	if major == cborSimple {
+		return d.simple(info)
+	}
+	if info == cborIndefinite {
+		return d.indefinite(major)
+	}
+	n, err := d.arg(info)
+	if err != nil {
+		return nil, err
+	}
+	switch major {
+	case cborUint:
+		if n > math.MaxInt64 {
+			return n, nil
+		}
+		return int64(n), nil
+	case cborNegInt:
+		if n > math.MaxInt64 {
+			return nil, fmt.Errorf("negative integer overflows int64: -1-%d", n)
+		}
+		return -1 - int64(n), nil
+	case cborBytes:
+		return d.bytes(n)
+	case cborText:
+		b, err := d.bytes(n)
+		return string(b), err
+	case cborArray:
+		return d.array(n, false)
+	case cborMap:
+		return d.dict(n, false)
+	case cborTag:
+		return d.tag(n)
+	default:
+		// This should never happen since major is a 3-bit value.
+		return nil, fmt.Errorf("invalid major type: %d", major)
+	}
+}
+
+// head returns the major type and additional information of the next
+// item. A break byte results in errCBORBreak.
+func (d *cborDecoder) head() (major, info byte, err error) {
+	b, err := d.r.ReadByte()
+	if err != nil {
+		return 0, 0, err
+	}
+	if b == cborBreak {
+		return 0, 0, errCBORBreak
+	}
+	return b >> 5, b & 0x1f, nil
+}
+
+// arg returns the argument of an item with the given additional information.
+func (d *cborDecoder) arg(info byte) (uint64, error) {
+	var size int
+	switch {
+	case info < 24:
+		return uint64(info), nil
+	case info == 24:
+		size = 1
+	case info == 25:
+		size = 2
+	case info == 26:
+		size = 4
+	case info == 27:
+		size = 8
+	default:
+		return 0, fmt.Errorf("invalid additional information: %d", info)
+	}
+	var buf [8]byte
+	_, err := io.ReadFull(d.r, buf[8-size:])
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(buf[:]), nil
+}
+
+// bytes returns the next n bytes. The buffer is grown as data is read
+// so that an invalid length does not result in a large allocation.
+func (d *cborDecoder) bytes(n uint64) ([]byte, error) {
+	var buf bytes.Buffer
+	if n > math.MaxInt64 {
+		return nil, fmt.Errorf("string length too large: %d", n)
+	}
+	_, err := io.CopyN(&buf, d.r, int64(n))
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// indefinite decodes an indefinite length item of the given major type.
+func (d *cborDecoder) indefinite(major byte) (interface{}, error) {
+	switch major {
+	case cborBytes, cborText:
+		var buf []byte
+		for {
+			m, info, err := d.head()
+			if err == errCBORBreak {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			if m != major || info == cborIndefinite {
+				return nil, errors.New("invalid chunk in indefinite length string")
+			}
+			n, err := d.arg(info)
+			if err != nil {
+				return nil, err
+			}
+			b, err := d.bytes(n)
+			if err != nil {
+				return nil, err
+			}
+			buf = append(buf, b...)
+		}
+		if major == cborText {
+			return string(buf), nil
+		}
+		if buf == nil {
+			buf = []byte{}
+		}
+		return buf, nil
+	case cborArray:
+		return d.array(0, true)
+	case cborMap:
+		return d.dict(0, true)
+	default:
+		return nil, fmt.Errorf("invalid indefinite length item of major type %d", major)
+	}
+}
+
+// array decodes an array of n elements, or until a break if indefinite
+// is true.
+func (d *cborDecoder) array(n uint64, indefinite bool) (interface{}, error) {
+	if err := d.enter(); err != nil {
+		return nil, err
+	}
+	defer d.leave()
+	// Limit preallocation since n has not been validated.
+	a := make([]interface{}, 0, minUint64(n, 1024))
+	for i := uint64(0); indefinite || i < n; i++ {
+		v, err := d.decode()
+		if indefinite && err == errCBORBreak {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		a = append(a, v)
+	}
+	return a, nil
+}
+
+// dict decodes a map of n pairs, or until a break if indefinite is true.
+// Integer and bool keys are rendered as strings. Other non-string keys
+// result in an error.
+func (d *cborDecoder) dict(n uint64, indefinite bool) (interface{}, error) {
+	if err := d.enter(); err != nil {
+		return nil, err
+	}
+	defer d.leave()
+	m := make(map[string]interface{})
+	for i := uint64(0); indefinite || i < n; i++ {
+		k, err := d.decode()
+		if indefinite && err == errCBORBreak {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var key string
+		switch k := k.(type) {
+		case string:
+			key = k
+		case int64:
+			key = strconv.FormatInt(k, 10)
+		case uint64:
+			key = strconv.FormatUint(k, 10)
+		case bool:
+			key = strconv.FormatBool(k)
+		default:
+			return nil, fmt.Errorf("unsupported map key type: %T", k)
+		}
+		v, err := d.decode()
+		if err != nil {
+			if err == errCBORBreak {
+				err = errors.New("missing map value")
+			}
+			return nil, err
+		}
+		m[key] = v
+	}
+	return m, nil
+}
+
+// tag decodes a tagged item. Standard and epoch date/time items, tags 0
+// and 1, are returned as time.Time. All other tags are ignored and the
+// tagged item is returned.
+func (d *cborDecoder) tag(n uint64) (interface{}, error) {
+	if err := d.enter(); err != nil {
+		return nil, err
+	}
+	defer d.leave()
+	v, err := d.decode()
+	if err != nil {
+		if err == errCBORBreak {
+			err = errors.New("missing tagged item")
+		}
+		return nil, err
+	}
+	switch n {
+	case 0:
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid type for date/time string: %T", v)
+		}
+		return time.Parse(time.RFC3339Nano, s)
+	case 1:
+		switch v := v.(type) {
+		case int64:
+			return time.Unix(v, 0).In(time.UTC), nil
+		case float64:
+			sec, frac := math.Modf(v)
+			return time.Unix(int64(sec), int64(frac*1e9)).In(time.UTC), nil
+		default:
+			return nil, fmt.Errorf("invalid type for epoch date/time: %T", v)
+		}
+	default:
+		return v, nil
+	}
+}
+
+// simple decodes a simple value or floating point number.
+func (d *cborDecoder) simple(info byte) (interface{}, error) {
+	switch info {
+	case 20:
+		return false, nil
+	case 21:
+		return true, nil
+	case 22, 23:
+		// Null and undefined.
+		return nil, nil
+	case 25:
+		n, err := d.arg(info)
+		return halfToFloat64(uint16(n)), err
+	case 26:
+		n, err := d.arg(info)
+		return float64(math.Float32frombits(uint32(n))), err
+	case 27:
+		n, err := d.arg(info)
+		return math.Float64frombits(n), err
+	default:
+		return nil, fmt.Errorf("unsupported simple value: %d", info)
+	}
+}
+
+func (d *cborDecoder) enter() error {
+	d.depth++
+	if d.depth > cborMaxDepth {
+		return fmt.Errorf("exceeded maximum nesting depth of %d", cborMaxDepth)
+	}
+	return nil
+}
+
+func (d *cborDecoder) leave() { d.depth-- }
+
+// halfToFloat64 returns the value of an IEEE 754 half-precision number.
+func halfToFloat64(h uint16) float64 {
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+	var f float64
+	switch exp {
+	case 0:
+		f = math.Ldexp(mant, -24)
+	case 0x1f:
+		if mant == 0 {
+			f = math.Inf(1)
+		} else {
+			f = math.NaN()
+		}
+	default:
+		f = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		f = -f
+	}
+	return f
+}
+
+func minUint64(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+	return b
+}
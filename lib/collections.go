@@ -18,8 +18,11 @@
 package lib
 
 import (
+	"fmt"
+	"math"
 	"reflect"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/google/cel-go/cel"
@@ -84,6 +87,38 @@ import (
 // If the the path to be dropped includes a dot, it can be escaped with a literal
 // backslash. See drop below.
 //
+// # Collate Pairs
+//
+// Returns a list of maps pairing each value obtained by collate with the path
+// that it was collated from. The path is held in the "path" field and the
+// value in the "value" field:
+//
+//	collate_pairs(<dyn>, <string>) -> <list<map<string,dyn>>>
+//	collate_pairs(<dyn>, <list<string>>) -> <list<map<string,dyn>>>
+//	<dyn>.collate_pairs(<string>) -> <list<map<string,dyn>>>
+//	<dyn>.collate_pairs(<list<string>>) -> <list<map<string,dyn>>>
+//
+// Examples:
+//
+//	Given v as for collate:
+//
+//	v.collate_pairs(["a.b", "b.b"])  // return [{"path": "a.b", "value": 1}, {"path": "a.b", "value": 2}, {"path": "a.b", "value": 3},
+//	                                 //         {"path": "b.b", "value": -1}, {"path": "b.b", "value": -2}, {"path": "b.b", "value": -3}]
+//
+// # Cartesian
+//
+// Returns the cartesian product of a list of lists. The product of a list
+// that includes an empty list is empty, and the product of an empty list is
+// a list holding a single empty list:
+//
+//	cartesian(<list<list<dyn>>>) -> <list<list<dyn>>>
+//	<list<list<dyn>>>.cartesian() -> <list<list<dyn>>>
+//
+// Examples:
+//
+//	[[1, 2], ["a", "b"]].cartesian()  // return [[1, "a"], [1, "b"], [2, "a"], [2, "b"]]
+//	cartesian([[1, 2], []])           // return []
+//
 // # Drop
 //
 // Returns the value of the receiver with the object at the given paths remove:
@@ -130,13 +165,44 @@ import (
 //
 //	v.drop("dotted\\.path.b")  // return {"dotted.path": [{"c": 10}, {"c": 20}, {"c": 30}]}
 //
+// # Keep
+//
+// Returns the value of the receiver with only the objects at the given paths
+// retained, preserving the nesting of the original value. This is the inverse
+// of drop. Paths that are not present are ignored, and path elements that
+// include a dot can be escaped with a literal backslash as for drop. When the
+// receiver is a list, each map element is pruned and other elements are
+// retained unaltered:
+//
+//	<list<dyn>>.keep(<string>) -> <list<dyn>>
+//	<list<dyn>>.keep(<list<string>>) -> <list<dyn>>
+//	<map<string,dyn>>.keep(<string>) -> <map<string,dyn>>
+//	<map<string,dyn>>.keep(<list<string>>) -> <map<string,dyn>>
+//
+// Examples:
+//
+//	Given v:
+//	{
+//	        "a": {"b": 1, "c": 2},
+//	        "b": {"c": 3, "d": 4}
+//	}
+//
+//	v.keep("a.b")                      // return {"a": {"b": 1}}
+//	v.keep(["a.b", "b.c"])             // return {"a": {"b": 1}, "b": {"c": 3}}
+//	[v, {"a": {"c": 5}}].keep("a.c")  // return [{"a": {"c": 2}}, {"a": {"c": 5}}]
+//
 // # Drop Empty
 //
 // Returns the value of the receiver with all empty lists and maps removed,
-// recursively
+// recursively. If an options map is provided, the values that are considered
+// to be empty can be configured; the "collections" option removes empty lists
+// and maps and defaults to true, the "strings" option removes empty strings
+// and the "nulls" option removes null values, both defaulting to false.
 //
 //	<list<dyn>>.drop_empty() -> <list<dyn>>
 //	<map<string,dyn>>.drop_empty() -> <map<string,dyn>>
+//	<list<dyn>>.drop_empty(<map<string,bool>>) -> <list<dyn>>
+//	<map<string,dyn>>.drop_empty(<map<string,bool>>) -> <map<string,dyn>>
 //
 // Examples:
 //
@@ -156,6 +222,13 @@ import (
 //
 //	v.drop_empty()  // return {"b":[{"b":-1, "c":10}, {"b":-2, "c":20}, {"b":-3, "c":30}]}
 //
+//	Given w:
+//	{"a": "", "b": null, "c": [], "d": 1}
+//
+//	w.drop_empty()                                          // return {"a": "", "b": null, "d": 1}
+//	w.drop_empty({"strings": true, "nulls": true})          // return {"d": 1}
+//	w.drop_empty({"collections": false, "strings": true})   // return {"b": null, "c": [], "d": 1}
+//
 // # Flatten
 //
 // Returns a list of non-list objects resulting from the depth-first
@@ -168,6 +241,130 @@ import (
 //	[[1],[2,3],[[[4]],[5,6]]].flatten()                     // return [1, 2, 3, 4, 5, 6]
 //	[[{"a":1,"b":[10, 11]}],[2,3],[[[4]],[5,6]]].flatten()  // return [{"a":1, "b":[10, 11]}, 2, 3, 4, 5, 6]
 //
+// # Flatten Unique
+//
+// Returns a list of the unique non-list objects resulting from the depth-first
+// traversal of a nested list, in order of first appearance. Lists are fully
+// flattened, including lists that hold both lists and non-list objects:
+//
+//	flatten_unique(<list<dyn>>) -> <list<dyn>>
+//	<list<dyn>>.flatten_unique() -> <list<dyn>>
+//
+// Examples:
+//
+//	[[1, 2], [2, [3, 1]], 4].flatten_unique()          // return [1, 2, 3, 4]
+//	[[{"a":1}], [{"a":1}, {"a":2}]].flatten_unique()  // return [{"a":1}, {"a":2}]
+//
+// # Unique
+//
+// Returns a list of the unique elements of a list in order of first
+// appearance. If a dotted path is provided, elements are considered
+// duplicates when they have equal values at the path, and every element
+// must be a map with a single value at the path:
+//
+//	unique(<list<dyn>>) -> <list<dyn>>
+//	unique(<list<dyn>>, <string>) -> <list<dyn>>
+//	<list<dyn>>.unique() -> <list<dyn>>
+//	<list<dyn>>.unique(<string>) -> <list<dyn>>
+//
+// Examples:
+//
+//	[1, 2, 1, 3, 2].unique()                             // return [1, 2, 3]
+//	[{"a":1}, {"a":2}, {"a":1}].unique()                 // return [{"a":1}, {"a":2}]
+//	[{"id":1, "v":"a"}, {"id":1, "v":"b"}].unique("id")  // return [{"id":1, "v":"a"}]
+//
+// # Union
+//
+// Returns a list of the unique elements of the receiver or first parameter
+// followed by the unique elements of the second list that are not in the
+// first. Elements are compared using CEL equality:
+//
+//	union(<list<dyn>>, <list<dyn>>) -> <list<dyn>>
+//	<list<dyn>>.union(<list<dyn>>) -> <list<dyn>>
+//
+// Examples:
+//
+//	[1, 2, 2].union([3, 2, 1.0])         // return [1, 2, 3]
+//	[{"a":1}].union([{"a":2}, {"a":1}])  // return [{"a":1}, {"a":2}]
+//
+// # Intersection
+//
+// Returns a list of the unique elements of the receiver or first parameter
+// that are also elements of the second list, in order of first appearance.
+// Elements are compared using CEL equality:
+//
+//	intersection(<list<dyn>>, <list<dyn>>) -> <list<dyn>>
+//	<list<dyn>>.intersection(<list<dyn>>) -> <list<dyn>>
+//
+// Examples:
+//
+//	[1, 2, 3, 2].intersection([2, 3, 4])                 // return [2, 3]
+//	[{"a":1}, {"a":2}].intersection([{"a":2}, {"a":3}])  // return [{"a":2}]
+//
+// # Difference
+//
+// Returns a list of the unique elements of the receiver or first parameter
+// that are not elements of the second list, in order of first appearance.
+// Elements are compared using CEL equality:
+//
+//	difference(<list<dyn>>, <list<dyn>>) -> <list<dyn>>
+//	<list<dyn>>.difference(<list<dyn>>) -> <list<dyn>>
+//
+// Examples:
+//
+//	[1, 2, 3, 1].difference([2, 4])                    // return [1, 3]
+//	[{"a":1}, {"a":2}].difference([{"a":2}, {"a":3}])  // return [{"a":1}]
+//
+// # Flatten Map
+//
+// Returns a map with the nested maps and lists of a map recursively flattened
+// into dotted keys. List elements are keyed by their index. Dots in keys
+// are escaped with a backslash, following the path convention used by drop,
+// collate and pick. Empty maps and lists are retained as values:
+//
+//	flatten_map(<map<string,dyn>>) -> <map<string,dyn>>
+//	<map<string,dyn>>.flatten_map() -> <map<string,dyn>>
+//
+// Examples:
+//
+//	{"a":{"b":1}, "c":[{"d":2}, 3]}.flatten_map()  // return {"a.b":1, "c.0.d":2, "c.1":3}
+//	{"a.b":{"c":1}, "d":{}}.flatten_map()          // return {"a\\.b.c":1, "d":{}}
+//
+// # Unflatten Map
+//
+// Returns the nested map described by a map with dotted keys, reversing
+// flatten_map. Nested maps whose keys are exactly the indexes of a list,
+// 0 to n-1, are returned as lists. An error is returned if a key is both
+// a value and a prefix of another key:
+//
+//	unflatten_map(<map<string,dyn>>) -> <map<string,dyn>>
+//	<map<string,dyn>>.unflatten_map() -> <map<string,dyn>>
+//
+// Examples:
+//
+//	{"a.b":1, "c.0.d":2, "c.1":3}.unflatten_map()  // return {"a":{"b":1}, "c":[{"d":2}, 3]}
+//	{"a\\.b.c":1}.unflatten_map()                  // return {"a.b":{"c":1}}
+//	{"a":1, "a.b":2}.unflatten_map()               // return error
+//
+// # Map Fields
+//
+// Returns a map with values moved from the source paths to the target paths
+// given by the keys and values of the mapping parameter. Paths are dotted
+// paths through nested maps, with dots in keys escaped with a backslash as
+// for drop, collate and pick. Maps are created as needed to hold values at
+// target paths. Source paths that are not present are ignored. All values
+// are read before any are moved, so a target path may also be a source path.
+// Maps left empty by moving their values are retained. An error is returned
+// if a target path passes through a value that is not a map:
+//
+//	map_fields(<map<string,dyn>>, <map<string,string>>) -> <map<string,dyn>>
+//	<map<string,dyn>>.map_fields(<map<string,string>>) -> <map<string,dyn>>
+//
+// Examples:
+//
+//	{"src_ip":"10.0.0.1", "user":"alice"}.map_fields({"src_ip":"source.ip", "user":"user.name"})  // return {"source":{"ip":"10.0.0.1"}, "user":{"name":"alice"}}
+//	{"vendor":{"sev":3}, "msg":"a"}.map_fields({"vendor.sev":"event.severity"})                  // return {"event":{"severity":3}, "msg":"a", "vendor":{}}
+//
 // # Max
 //
 // Returns the maximum value of a list of comparable objects:
@@ -192,6 +389,97 @@ import (
 //	[1,2,3,4,5,6,7].min()  // return 1
 //	min([1,2,3,4,5,6,7])   // return 1
 //
+// # Sort
+//
+// Returns a new list holding the elements of a list of mutually comparable
+// objects in ascending order. The sort is stable. An error is returned if
+// the elements are not mutually comparable:
+//
+//	<list<dyn>>.sort() -> <list<dyn>>
+//	sort(<list<dyn>>) -> <list<dyn>>
+//
+// Examples:
+//
+//	[3, 1, 2].sort()         // return [1, 2, 3]
+//	sort(["b", "c", "a"])    // return ["a", "b", "c"]
+//	[1, "a"].sort()          // return error
+//
+// # Sort By
+//
+// Returns a new list holding the maps of a list in ascending order of the
+// value at the dotted path in each map. The sort is stable. An error is
+// returned if a map does not have a single value at the path or if the
+// values are not mutually comparable:
+//
+//	<list<map<string,dyn>>>.sort_by(<string>) -> <list<map<string,dyn>>>
+//	sort_by(<list<map<string,dyn>>>, <string>) -> <list<map<string,dyn>>>
+//
+// Examples:
+//
+//	[{"a":{"b":2}}, {"a":{"b":1}}].sort_by("a.b")  // return [{"a":{"b":1}}, {"a":{"b":2}}]
+//	sort_by([{"n":"b"}, {"n":"a"}], "n")           // return [{"n":"a"}, {"n":"b"}]
+//
+// # Transpose
+//
+// Returns the transpose of a list of lists. If the lists differ in length,
+// an error is returned unless a padding value is provided, in which case
+// shorter lists are padded with the value to the length of the longest list:
+//
+//	transpose(<list<list<dyn>>>) -> <list<list<dyn>>>
+//	transpose(<list<list<dyn>>>, <dyn>) -> <list<list<dyn>>>
+//	<list<list<dyn>>>.transpose() -> <list<list<dyn>>>
+//	<list<list<dyn>>>.transpose(<dyn>) -> <list<list<dyn>>>
+//
+// Examples:
+//
+//	[[1, 2, 3], ["a", "b", "c"]].transpose()  // return [[1, "a"], [2, "b"], [3, "c"]]
+//	[[1, 2, 3], ["a"]].transpose(null)       // return [[1, "a"], [2, null], [3, null]]
+//
+// # Windows
+//
+// Returns a list of sub-lists of a list, each of the given size, starting
+// at every step elements. Trailing elements that do not fill a complete
+// window are not included. The size and step must be positive:
+//
+//	windows(<list<dyn>>, <int>, <int>) -> <list<list<dyn>>>
+//	<list<dyn>>.windows(<int>, <int>) -> <list<list<dyn>>>
+//
+// Examples:
+//
+//	[1, 2, 3, 4].windows(2, 1)     // return [[1, 2], [2, 3], [3, 4]]
+//	[1, 2, 3, 4, 5].windows(2, 2)  // return [[1, 2], [3, 4]]
+//	windows([1, 2, 3, 4], 1, 3)    // return [[1], [4]]
+//
+// # Chunk
+//
+// Returns a list of consecutive sub-lists of a list, each holding at most
+// the given number of elements. The last chunk holds any remaining elements
+// and may be shorter. The size must be positive:
+//
+//	chunk(<list<dyn>>, <int>) -> <list<list<dyn>>>
+//	<list<dyn>>.chunk(<int>) -> <list<list<dyn>>>
+//
+// Examples:
+//
+//	[1, 2, 3, 4].chunk(2)     // return [[1, 2], [3, 4]]
+//	[1, 2, 3, 4, 5].chunk(2)  // return [[1, 2], [3, 4], [5]]
+//	chunk([], 2)              // return []
+//
+// # Split Bytes
+//
+// Returns a list of consecutive chunks of a bytes value, each no longer
+// than the given maximum length. The last chunk holds any remaining
+// bytes and may be shorter. The maximum must be positive:
+//
+//	split_bytes(<bytes>, <int>) -> <list<bytes>>
+//	<bytes>.split_bytes(<int>) -> <list<bytes>>
+//
+// Examples:
+//
+//	b"abcdef".split_bytes(2)    // return [b"ab", b"cd", b"ef"]
+//	split_bytes(b"abcde", 2)    // return [b"ab", b"cd", b"e"]
+//	b"".split_bytes(2)          // return []
+//
 // # With
 //
 // Returns the receiver's value with the value of the parameter updating
@@ -225,6 +513,21 @@ import (
 //
 //	{"a":1, "b":2}.with({"a":10, "c":3})  // return {"a":1, "b":2, "c":3}
 //
+// # Merge Patch
+//
+// Returns the result of applying the parameter to the receiver as an
+// RFC 7386 JSON Merge Patch. Fields in the patch with a null value are
+// deleted from the result, nested maps are merged recursively and all
+// other values replace the value in the target:
+//
+//	<map<string,dyn>>.merge_patch(<map<string,dyn>>) -> <map<string,dyn>>
+//	merge_patch(<map<string,dyn>>, <map<string,dyn>>) -> <map<string,dyn>>
+//
+// Examples:
+//
+//	{"a":{"b":1, "c":2}, "d":3}.merge_patch({"a":{"b":10, "c":null}})  // return {"a":{"b":10}, "d":3}
+//	merge_patch({"a":[1, 2]}, {"a":[3], "e":{"f":null}})              // return {"a":[3], "e":{}}
+//
 // # Zip
 //
 // Returns a map keyed on elements of a list with values from another equally
@@ -238,6 +541,156 @@ import (
 //	zip(["a", "b"], [1, 2])  // return {"a":1, "b":2}
 //	["a", "b"].zip([1, 2])   // return {"a":1, "b":2}
 //
+// # Histogram
+//
+// Returns a map of counts of values in a list falling into the buckets
+// defined by a list of ascending boundaries. Each bucket includes its lower
+// bound and excludes its upper bound, and the lowest and highest buckets
+// extend to -inf and +inf respectively. Buckets are keyed by their interval
+// and all buckets are included in the result, even when empty:
+//
+//	histogram(<list<double>>, <list<double>>) -> <map<string,int>>
+//	<list<double>>.histogram(<list<double>>) -> <map<string,int>>
+//
+// Examples:
+//
+//	[0.5, 1.0, 2.5, 10.0].histogram([1.0, 5.0])  // return {"(-inf,1)": 1, "[1,5)": 2, "[5,+inf)": 1}
+//	histogram([], [0.0])                        // return {"(-inf,0)": 0, "[0,+inf)": 0}
+//
+// # Percentile
+//
+// Returns the p-th percentile of a list of numbers, using linear
+// interpolation between the closest ranks. The percentile must be in
+// the range [0, 100] and the list must not be empty:
+//
+//	percentile(<list<double>>, <double>) -> <double>
+//	<list<double>>.percentile(<double>) -> <double>
+//
+// Examples:
+//
+//	[1.0, 2.0, 3.0, 4.0].percentile(50.0)  // return 2.5
+//	[1.0, 2.0, 3.0, 4.0].percentile(90.0)  // return 3.7
+//	percentile([15.0, 20.0, 35.0], 100.0)  // return 35.0
+//
+// # Join On
+//
+// Returns a list of maps made by merging each map in a left list with each
+// map in a right list that has an equal value for the given key. Fields of
+// the right map replace fields of the left map with the same name. A left
+// map matching more than one right map appears once for each match. The
+// optional fourth parameter selects the join type; with "left", the default,
+// left maps without a match are included unchanged, and with "inner", they
+// are omitted. Maps without the key do not match:
+//
+//	join_on(<list<map<string,dyn>>>, <list<map<string,dyn>>>, <string>) -> <list<map<string,dyn>>>
+//	join_on(<list<map<string,dyn>>>, <list<map<string,dyn>>>, <string>, <string>) -> <list<map<string,dyn>>>
+//	<list<map<string,dyn>>>.join_on(<list<map<string,dyn>>>, <string>) -> <list<map<string,dyn>>>
+//	<list<map<string,dyn>>>.join_on(<list<map<string,dyn>>>, <string>, <string>) -> <list<map<string,dyn>>>
+//
+// Examples:
+//
+//	[{"id": 1, "a": "x"}, {"id": 2, "a": "y"}].join_on([{"id": 1, "b": "z"}], "id")
+//
+//	will return:
+//
+//	[{"id": 1, "a": "x", "b": "z"}, {"id": 2, "a": "y"}]
+//
+//	[{"id": 1, "a": "x"}, {"id": 2, "a": "y"}].join_on([{"id": 1, "b": "z"}], "id", "inner")
+//
+//	will return:
+//
+//	[{"id": 1, "a": "x", "b": "z"}]
+//
+// # Pick
+//
+// Returns a map holding only the values at the specified paths in the
+// receiver, preserving the nesting of the original map. Paths that are not
+// present in the receiver are ignored. Lists in the path are traversed, with
+// elements that do not hold the path being represented by empty maps. Path
+// elements that include a dot can be escaped with a literal backslash as
+// for drop:
+//
+//	pick(<map<string,dyn>>, <string>) -> <map<string,dyn>>
+//	pick(<map<string,dyn>>, <list<string>>) -> <map<string,dyn>>
+//	<map<string,dyn>>.pick(<string>) -> <map<string,dyn>>
+//	<map<string,dyn>>.pick(<list<string>>) -> <map<string,dyn>>
+//
+// Examples:
+//
+//	Given v:
+//	{
+//	        "a": {"b": 1, "c": 2},
+//	        "d": [
+//	            {"e": -1, "f": 10},
+//	            {"e": -2, "f": 20}
+//	        ],
+//	        "g.h": {"i": true, "j": false}
+//	}
+//
+//	v.pick("a.b")                    // return {"a": {"b": 1}}
+//	v.pick(["a.c", "d.f"])           // return {"a": {"c": 2}, "d": [{"f": 10}, {"f": 20}]}
+//	v.pick(["g\\.h.i", "missing"])  // return {"g.h": {"i": true}}
+//
+// # Search
+//
+// Returns a list of all the values in the receiver, at any depth, that are
+// held in a map under the given key. Values are returned in depth-first
+// order with map keys visited in sorted order. Matching values are also
+// searched:
+//
+//	search(<dyn>, <string>) -> <list<dyn>>
+//	<dyn>.search(<string>) -> <list<dyn>>
+//
+// Examples:
+//
+//	Given v:
+//	{
+//	        "id": 1,
+//	        "a": [
+//	            {"id": 2, "b": {"id": 3}},
+//	            {"c": 4}
+//	        ],
+//	        "d": {"id": {"id": 5}}
+//	}
+//
+//	v.search("id")  // return [3, 2, {"id": 5}, 5, 1]
+//
+// # JSON Path
+//
+// Returns a list of the values in the receiver selected by a JSONPath
+// expression, or an empty list if there are no matches. A practical subset
+// of JSONPath is supported. Paths start with the root, $, and are followed
+// by steps selecting a map field with .name or ['name'], a list element by
+// index with [n], where negative indexes count back from the end of the list,
+// all list elements or map values with [*] or .*, or the list elements or map
+// values that match a filter with [?(@.path op value)]. Filter operators are
+// ==, !=, <, <=, > and >=, and values may be numbers, quoted strings, true,
+// false or null. A filter without an operator and value, [?(@.path)], selects
+// the elements that have the path. Map values are selected in key order:
+//
+//	json_path(<dyn>, <string>) -> <list<dyn>>
+//	<dyn>.json_path(<string>) -> <list<dyn>>
+//
+// Examples:
+//
+//	Given v:
+//	{
+//	        "store": {
+//	            "books": [
+//	                {"title": "A", "price": 8.95, "tags": ["x"]},
+//	                {"title": "B", "price": 12.99},
+//	                {"title": "C", "price": 22.99, "tags": ["y"]}
+//	            ]
+//	        }
+//	}
+//
+//	v.json_path("$.store.books[0].title")                // return ["A"]
+//	v.json_path("$.store.books[-1].title")               // return ["C"]
+//	v.json_path("$.store.books[*].price")                // return [8.95, 12.99, 22.99]
+//	v.json_path("$.store.books[?(@.price < 20)].title")  // return ["A", "B"]
+//	v.json_path("$.store.books[?(@.tags)].title")        // return ["A", "C"]
+//	v.json_path("$.store.missing")                       // return []
+//
 // # Keys
 //
 // Returns a list of keys from a map:
@@ -297,6 +750,40 @@ func (collectionsLib) CompileOptions() []cel.EnvOption {
 					[]string{"V"},
 				),
 			),
+			decls.NewFunction("collate_pairs",
+				decls.NewInstanceOverload(
+					"dyn_collate_pairs_string",
+					[]*expr.Type{decls.Dyn, decls.String},
+					decls.NewListType(mapStringDyn),
+				),
+				decls.NewInstanceOverload(
+					"dyn_collate_pairs_list_string",
+					[]*expr.Type{decls.Dyn, decls.NewListType(decls.String)},
+					decls.NewListType(mapStringDyn),
+				),
+				decls.NewOverload(
+					"collate_pairs_dyn_string",
+					[]*expr.Type{decls.Dyn, decls.String},
+					decls.NewListType(mapStringDyn),
+				),
+				decls.NewOverload(
+					"collate_pairs_dyn_list_string",
+					[]*expr.Type{decls.Dyn, decls.NewListType(decls.String)},
+					decls.NewListType(mapStringDyn),
+				),
+			),
+			decls.NewFunction("cartesian",
+				decls.NewInstanceOverload(
+					"list_cartesian",
+					[]*expr.Type{decls.NewListType(decls.NewListType(decls.Dyn))},
+					decls.NewListType(decls.NewListType(decls.Dyn)),
+				),
+				decls.NewOverload(
+					"cartesian_list",
+					[]*expr.Type{decls.NewListType(decls.NewListType(decls.Dyn))},
+					decls.NewListType(decls.NewListType(decls.Dyn)),
+				),
+			),
 			decls.NewFunction("drop",
 				decls.NewInstanceOverload(
 					"list_drop_string",
@@ -319,6 +806,28 @@ func (collectionsLib) CompileOptions() []cel.EnvOption {
 					mapKV,
 				),
 			),
+			decls.NewFunction("keep",
+				decls.NewInstanceOverload(
+					"list_keep_string",
+					[]*expr.Type{decls.NewListType(decls.Dyn), decls.String},
+					decls.NewListType(decls.Dyn),
+				),
+				decls.NewInstanceOverload(
+					"list_keep_list_string",
+					[]*expr.Type{decls.NewListType(decls.Dyn), decls.NewListType(decls.String)},
+					decls.NewListType(decls.Dyn),
+				),
+				decls.NewInstanceOverload(
+					"map_keep_string",
+					[]*expr.Type{mapKV, decls.String},
+					mapKV,
+				),
+				decls.NewInstanceOverload(
+					"map_keep_list_string",
+					[]*expr.Type{mapKV, decls.NewListType(decls.String)},
+					mapKV,
+				),
+			),
 			decls.NewFunction("drop_empty",
 				decls.NewInstanceOverload(
 					"list_drop_empty",
@@ -330,6 +839,16 @@ func (collectionsLib) CompileOptions() []cel.EnvOption {
 					[]*expr.Type{mapKV},
 					mapKV,
 				),
+				decls.NewInstanceOverload(
+					"list_drop_empty_map",
+					[]*expr.Type{decls.NewListType(decls.Dyn), mapKV},
+					decls.NewListType(decls.Dyn),
+				),
+				decls.NewInstanceOverload(
+					"map_drop_empty_map",
+					[]*expr.Type{mapKV, mapKV},
+					mapKV,
+				),
 			),
 			decls.NewFunction("flatten",
 				decls.NewInstanceOverload(
@@ -338,58 +857,272 @@ func (collectionsLib) CompileOptions() []cel.EnvOption {
 					decls.NewListType(decls.Dyn),
 				),
 			),
-			decls.NewFunction("max",
+			decls.NewFunction("flatten_unique",
+				decls.NewInstanceOverload(
+					"list_flatten_unique",
+					[]*expr.Type{decls.NewListType(decls.Dyn)},
+					decls.NewListType(decls.Dyn),
+				),
+				decls.NewOverload(
+					"flatten_unique_list",
+					[]*expr.Type{decls.NewListType(decls.Dyn)},
+					decls.NewListType(decls.Dyn),
+				),
+			),
+			decls.NewFunction("union",
 				decls.NewParameterizedInstanceOverload(
-					"list_max",
-					[]*expr.Type{listV},
-					typeV,
+					"list_union_list",
+					[]*expr.Type{listV, listV},
+					listV,
 					[]string{"V"},
 				),
 				decls.NewParameterizedOverload(
-					"max_list",
-					[]*expr.Type{listV},
-					typeV,
+					"union_list_list",
+					[]*expr.Type{listV, listV},
+					listV,
 					[]string{"V"},
 				),
 			),
-			decls.NewFunction("min",
+			decls.NewFunction("intersection",
 				decls.NewParameterizedInstanceOverload(
-					"list_min",
-					[]*expr.Type{listV},
-					typeV,
+					"list_intersection_list",
+					[]*expr.Type{listV, listV},
+					listV,
 					[]string{"V"},
 				),
 				decls.NewParameterizedOverload(
-					"min_list",
-					[]*expr.Type{listV},
-					typeV,
+					"intersection_list_list",
+					[]*expr.Type{listV, listV},
+					listV,
 					[]string{"V"},
 				),
 			),
-			decls.NewFunction("with",
+			decls.NewFunction("difference",
 				decls.NewParameterizedInstanceOverload(
-					"map_with_map",
-					[]*expr.Type{mapKV, mapKV},
-					mapKV,
-					[]string{"K", "V"},
+					"list_difference_list",
+					[]*expr.Type{listV, listV},
+					listV,
+					[]string{"V"},
 				),
-			),
-			decls.NewFunction("with_update",
-				decls.NewParameterizedInstanceOverload(
-					"map_with_update_map",
-					[]*expr.Type{mapKV, mapKV},
-					mapKV,
-					[]string{"K", "V"},
+				decls.NewParameterizedOverload(
+					"difference_list_list",
+					[]*expr.Type{listV, listV},
+					listV,
+					[]string{"V"},
 				),
 			),
-			decls.NewFunction("with_replace",
+			decls.NewFunction("unique",
 				decls.NewParameterizedInstanceOverload(
-					"map_with_replace_map",
-					[]*expr.Type{mapKV, mapKV},
-					mapKV,
-					[]string{"K", "V"},
-				),
-			),
+					"list_unique",
+					[]*expr.Type{listV},
+					listV,
+					[]string{"V"},
+				),
+				decls.NewParameterizedOverload(
+					"unique_list",
+					[]*expr.Type{listV},
+					listV,
+					[]string{"V"},
+				),
+				decls.NewParameterizedInstanceOverload(
+					"list_unique_string",
+					[]*expr.Type{listV, decls.String},
+					listV,
+					[]string{"V"},
+				),
+				decls.NewParameterizedOverload(
+					"unique_list_string",
+					[]*expr.Type{listV, decls.String},
+					listV,
+					[]string{"V"},
+				),
+			),
+			decls.NewFunction("flatten_map",
+				decls.NewInstanceOverload(
+					"map_flatten_map",
+					[]*expr.Type{mapStringDyn},
+					mapStringDyn,
+				),
+				decls.NewOverload(
+					"flatten_map_map",
+					[]*expr.Type{mapStringDyn},
+					mapStringDyn,
+				),
+			),
+			decls.NewFunction("unflatten_map",
+				decls.NewInstanceOverload(
+					"map_unflatten_map",
+					[]*expr.Type{mapStringDyn},
+					mapStringDyn,
+				),
+				decls.NewOverload(
+					"unflatten_map_map",
+					[]*expr.Type{mapStringDyn},
+					mapStringDyn,
+				),
+			),
+			decls.NewFunction("map_fields",
+				decls.NewInstanceOverload(
+					"map_map_fields_map",
+					[]*expr.Type{mapStringDyn, decls.NewMapType(decls.String, decls.String)},
+					mapStringDyn,
+				),
+				decls.NewOverload(
+					"map_fields_map_map",
+					[]*expr.Type{mapStringDyn, decls.NewMapType(decls.String, decls.String)},
+					mapStringDyn,
+				),
+			),
+			decls.NewFunction("max",
+				decls.NewParameterizedInstanceOverload(
+					"list_max",
+					[]*expr.Type{listV},
+					typeV,
+					[]string{"V"},
+				),
+				decls.NewParameterizedOverload(
+					"max_list",
+					[]*expr.Type{listV},
+					typeV,
+					[]string{"V"},
+				),
+			),
+			decls.NewFunction("min",
+				decls.NewParameterizedInstanceOverload(
+					"list_min",
+					[]*expr.Type{listV},
+					typeV,
+					[]string{"V"},
+				),
+				decls.NewParameterizedOverload(
+					"min_list",
+					[]*expr.Type{listV},
+					typeV,
+					[]string{"V"},
+				),
+			),
+			decls.NewFunction("sort",
+				decls.NewParameterizedInstanceOverload(
+					"list_sort",
+					[]*expr.Type{listV},
+					listV,
+					[]string{"V"},
+				),
+				decls.NewParameterizedOverload(
+					"sort_list",
+					[]*expr.Type{listV},
+					listV,
+					[]string{"V"},
+				),
+			),
+			decls.NewFunction("sort_by",
+				decls.NewParameterizedInstanceOverload(
+					"list_sort_by_string",
+					[]*expr.Type{listV, decls.String},
+					listV,
+					[]string{"V"},
+				),
+				decls.NewParameterizedOverload(
+					"sort_by_list_string",
+					[]*expr.Type{listV, decls.String},
+					listV,
+					[]string{"V"},
+				),
+			),
+			decls.NewFunction("transpose",
+				decls.NewInstanceOverload(
+					"list_transpose",
+					[]*expr.Type{decls.NewListType(decls.NewListType(decls.Dyn))},
+					decls.NewListType(decls.NewListType(decls.Dyn)),
+				),
+				decls.NewOverload(
+					"transpose_list",
+					[]*expr.Type{decls.NewListType(decls.NewListType(decls.Dyn))},
+					decls.NewListType(decls.NewListType(decls.Dyn)),
+				),
+				decls.NewInstanceOverload(
+					"list_transpose_dyn",
+					[]*expr.Type{decls.NewListType(decls.NewListType(decls.Dyn)), decls.Dyn},
+					decls.NewListType(decls.NewListType(decls.Dyn)),
+				),
+				decls.NewOverload(
+					"transpose_list_dyn",
+					[]*expr.Type{decls.NewListType(decls.NewListType(decls.Dyn)), decls.Dyn},
+					decls.NewListType(decls.NewListType(decls.Dyn)),
+				),
+			),
+			decls.NewFunction("windows",
+				decls.NewInstanceOverload(
+					"list_windows_int_int",
+					[]*expr.Type{decls.NewListType(decls.Dyn), decls.Int, decls.Int},
+					decls.NewListType(decls.NewListType(decls.Dyn)),
+				),
+				decls.NewOverload(
+					"windows_list_int_int",
+					[]*expr.Type{decls.NewListType(decls.Dyn), decls.Int, decls.Int},
+					decls.NewListType(decls.NewListType(decls.Dyn)),
+				),
+			),
+			decls.NewFunction("chunk",
+				decls.NewInstanceOverload(
+					"list_chunk_int",
+					[]*expr.Type{decls.NewListType(decls.Dyn), decls.Int},
+					decls.NewListType(decls.NewListType(decls.Dyn)),
+				),
+				decls.NewOverload(
+					"chunk_list_int",
+					[]*expr.Type{decls.NewListType(decls.Dyn), decls.Int},
+					decls.NewListType(decls.NewListType(decls.Dyn)),
+				),
+			),
+			decls.NewFunction("split_bytes",
+				decls.NewInstanceOverload(
+					"bytes_split_bytes_int",
+					[]*expr.Type{decls.Bytes, decls.Int},
+					decls.NewListType(decls.Bytes),
+				),
+				decls.NewOverload(
+					"split_bytes_bytes_int",
+					[]*expr.Type{decls.Bytes, decls.Int},
+					decls.NewListType(decls.Bytes),
+				),
+			),
+			decls.NewFunction("with",
+				decls.NewParameterizedInstanceOverload(
+					"map_with_map",
+					[]*expr.Type{mapKV, mapKV},
+					mapKV,
+					[]string{"K", "V"},
+				),
+			),
+			decls.NewFunction("with_update",
+				decls.NewParameterizedInstanceOverload(
+					"map_with_update_map",
+					[]*expr.Type{mapKV, mapKV},
+					mapKV,
+					[]string{"K", "V"},
+				),
+			),
+			decls.NewFunction("with_replace",
+				decls.NewParameterizedInstanceOverload(
+					"map_with_replace_map",
+					[]*expr.Type{mapKV, mapKV},
+					mapKV,
+					[]string{"K", "V"},
+				),
+			),
+			decls.NewFunction("merge_patch",
+				decls.NewInstanceOverload(
+					"map_merge_patch_map",
+					[]*expr.Type{mapStringDyn, mapStringDyn},
+					mapStringDyn,
+				),
+				decls.NewOverload(
+					"merge_patch_map_map",
+					[]*expr.Type{mapStringDyn, mapStringDyn},
+					mapStringDyn,
+				),
+			),
 			decls.NewFunction("zip",
 				decls.NewParameterizedInstanceOverload(
 					"list_zip",
@@ -404,6 +1137,98 @@ func (collectionsLib) CompileOptions() []cel.EnvOption {
 					[]string{"K", "V"},
 				),
 			),
+			decls.NewFunction("histogram",
+				decls.NewInstanceOverload(
+					"list_double_histogram_list_double",
+					[]*expr.Type{decls.NewListType(decls.Double), decls.NewListType(decls.Double)},
+					decls.NewMapType(decls.String, decls.Int),
+				),
+				decls.NewOverload(
+					"histogram_list_double_list_double",
+					[]*expr.Type{decls.NewListType(decls.Double), decls.NewListType(decls.Double)},
+					decls.NewMapType(decls.String, decls.Int),
+				),
+			),
+			decls.NewFunction("percentile",
+				decls.NewInstanceOverload(
+					"list_double_percentile_double",
+					[]*expr.Type{decls.NewListType(decls.Double), decls.Double},
+					decls.Double,
+				),
+				decls.NewOverload(
+					"percentile_list_double_double",
+					[]*expr.Type{decls.NewListType(decls.Double), decls.Double},
+					decls.Double,
+				),
+			),
+			decls.NewFunction("join_on",
+				decls.NewInstanceOverload(
+					"list_join_on_list_string",
+					[]*expr.Type{listMapStringDyn, listMapStringDyn, decls.String},
+					listMapStringDyn,
+				),
+				decls.NewOverload(
+					"join_on_list_list_string",
+					[]*expr.Type{listMapStringDyn, listMapStringDyn, decls.String},
+					listMapStringDyn,
+				),
+				decls.NewInstanceOverload(
+					"list_join_on_list_string_string",
+					[]*expr.Type{listMapStringDyn, listMapStringDyn, decls.String, decls.String},
+					listMapStringDyn,
+				),
+				decls.NewOverload(
+					"join_on_list_list_string_string",
+					[]*expr.Type{listMapStringDyn, listMapStringDyn, decls.String, decls.String},
+					listMapStringDyn,
+				),
+			),
+			decls.NewFunction("pick",
+				decls.NewInstanceOverload(
+					"map_pick_string",
+					[]*expr.Type{mapKV, decls.String},
+					mapKV,
+				),
+				decls.NewOverload(
+					"pick_map_string",
+					[]*expr.Type{mapKV, decls.String},
+					mapKV,
+				),
+				decls.NewInstanceOverload(
+					"map_pick_list_string",
+					[]*expr.Type{mapKV, decls.NewListType(decls.String)},
+					mapKV,
+				),
+				decls.NewOverload(
+					"pick_map_list_string",
+					[]*expr.Type{mapKV, decls.NewListType(decls.String)},
+					mapKV,
+				),
+			),
+			decls.NewFunction("search",
+				decls.NewInstanceOverload(
+					"dyn_search_string",
+					[]*expr.Type{decls.Dyn, decls.String},
+					decls.NewListType(decls.Dyn),
+				),
+				decls.NewOverload(
+					"search_dyn_string",
+					[]*expr.Type{decls.Dyn, decls.String},
+					decls.NewListType(decls.Dyn),
+				),
+			),
+			decls.NewFunction("json_path",
+				decls.NewInstanceOverload(
+					"dyn_json_path_string",
+					[]*expr.Type{decls.Dyn, decls.String},
+					decls.NewListType(decls.Dyn),
+				),
+				decls.NewOverload(
+					"json_path_dyn_string",
+					[]*expr.Type{decls.Dyn, decls.String},
+					decls.NewListType(decls.Dyn),
+				),
+			),
 			decls.NewFunction("keys",
 				decls.NewParameterizedInstanceOverload(
 					"map_keys",
@@ -456,6 +1281,34 @@ func (collectionsLib) ProgramOptions() []cel.ProgramOption {
 				Binary:   collateFields,
 			},
 		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "dyn_collate_pairs_string",
+				Binary:   collatePairs,
+			},
+			&functions.Overload{
+				Operator: "dyn_collate_pairs_list_string",
+				Binary:   collatePairs,
+			},
+			&functions.Overload{
+				Operator: "collate_pairs_dyn_string",
+				Binary:   collatePairs,
+			},
+			&functions.Overload{
+				Operator: "collate_pairs_dyn_list_string",
+				Binary:   collatePairs,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "list_cartesian",
+				Unary:    cartesian,
+			},
+			&functions.Overload{
+				Operator: "cartesian_list",
+				Unary:    cartesian,
+			},
+		),
 		cel.Functions(
 			&functions.Overload{
 				Operator: "list_drop_string",
@@ -474,6 +1327,24 @@ func (collectionsLib) ProgramOptions() []cel.ProgramOption {
 				Binary:   dropFields,
 			},
 		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "list_keep_string",
+				Binary:   keepFields,
+			},
+			&functions.Overload{
+				Operator: "list_keep_list_string",
+				Binary:   keepFields,
+			},
+			&functions.Overload{
+				Operator: "map_keep_string",
+				Binary:   keepFields,
+			},
+			&functions.Overload{
+				Operator: "map_keep_list_string",
+				Binary:   keepFields,
+			},
+		),
 		cel.Functions(
 			&functions.Overload{
 				Operator: "list_drop_empty",
@@ -483,6 +1354,14 @@ func (collectionsLib) ProgramOptions() []cel.ProgramOption {
 				Operator: "map_drop_empty",
 				Unary:    dropEmpty,
 			},
+			&functions.Overload{
+				Operator: "list_drop_empty_map",
+				Binary:   dropEmptyWithOptions,
+			},
+			&functions.Overload{
+				Operator: "map_drop_empty_map",
+				Binary:   dropEmptyWithOptions,
+			},
 		),
 		cel.Functions(
 			&functions.Overload{
@@ -492,94 +1371,1019 @@ func (collectionsLib) ProgramOptions() []cel.ProgramOption {
 		),
 		cel.Functions(
 			&functions.Overload{
-				Operator: "min_list",
-				Unary:    min,
+				Operator: "list_flatten_unique",
+				Unary:    flattenUnique,
 			},
 			&functions.Overload{
-				Operator: "list_min",
-				Unary:    min,
+				Operator: "flatten_unique_list",
+				Unary:    flattenUnique,
 			},
 		),
 		cel.Functions(
 			&functions.Overload{
-				Operator: "max_list",
-				Unary:    max,
+				Operator: "list_union_list",
+				Binary:   setUnion,
 			},
 			&functions.Overload{
-				Operator: "list_max",
-				Unary:    max,
+				Operator: "union_list_list",
+				Binary:   setUnion,
 			},
 		),
 		cel.Functions(
 			&functions.Overload{
-				Operator: "map_with_map",
-				Binary:   withAll,
+				Operator: "list_intersection_list",
+				Binary:   setIntersection,
 			},
 			&functions.Overload{
-				Operator: "map_with_update_map",
-				Binary:   withUpdate,
+				Operator: "intersection_list_list",
+				Binary:   setIntersection,
 			},
+		),
+		cel.Functions(
 			&functions.Overload{
-				Operator: "map_with_replace_map",
-				Binary:   withReplace,
+				Operator: "list_difference_list",
+				Binary:   setDifference,
+			},
+			&functions.Overload{
+				Operator: "difference_list_list",
+				Binary:   setDifference,
 			},
 		),
 		cel.Functions(
 			&functions.Overload{
-				Operator: "zip_list",
-				Binary:   zipLists,
+				Operator: "list_unique",
+				Unary:    unique,
 			},
 			&functions.Overload{
-				Operator: "list_zip",
-				Binary:   zipLists,
+				Operator: "unique_list",
+				Unary:    unique,
+			},
+			&functions.Overload{
+				Operator: "list_unique_string",
+				Binary:   uniqueBy,
+			},
+			&functions.Overload{
+				Operator: "unique_list_string",
+				Binary:   uniqueBy,
 			},
 		),
 		cel.Functions(
 			&functions.Overload{
-				Operator: "map_keys",
-				Unary:    mapKeys,
+				Operator: "map_flatten_map",
+				Unary:    flattenMap,
 			},
 			&functions.Overload{
-				Operator: "keys_map",
-				Unary:    mapKeys,
+				Operator: "flatten_map_map",
+				Unary:    flattenMap,
 			},
 		),
 		cel.Functions(
 			&functions.Overload{
-				Operator: "map_values",
-				Unary:    mapValues,
+				Operator: "map_unflatten_map",
+				Unary:    unflattenMap,
 			},
 			&functions.Overload{
-				Operator: "values_map",
-				Unary:    mapValues,
+				Operator: "unflatten_map_map",
+				Unary:    unflattenMap,
 			},
 		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "map_map_fields_map",
+				Binary:   mapFields,
+			},
+			&functions.Overload{
+				Operator: "map_fields_map_map",
+				Binary:   mapFields,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "min_list",
+				Unary:    min,
+			},
+			&functions.Overload{
+				Operator: "list_min",
+				Unary:    min,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "max_list",
+				Unary:    max,
+			},
+			&functions.Overload{
+				Operator: "list_max",
+				Unary:    max,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "list_sort",
+				Unary:    sortList,
+			},
+			&functions.Overload{
+				Operator: "sort_list",
+				Unary:    sortList,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "list_sort_by_string",
+				Binary:   sortBy,
+			},
+			&functions.Overload{
+				Operator: "sort_by_list_string",
+				Binary:   sortBy,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "list_transpose",
+				Unary:    transposeStrict,
+			},
+			&functions.Overload{
+				Operator: "transpose_list",
+				Unary:    transposeStrict,
+			},
+			&functions.Overload{
+				Operator: "list_transpose_dyn",
+				Binary:   transpose,
+			},
+			&functions.Overload{
+				Operator: "transpose_list_dyn",
+				Binary:   transpose,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "list_windows_int_int",
+				Function: windows,
+			},
+			&functions.Overload{
+				Operator: "windows_list_int_int",
+				Function: windows,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "list_chunk_int",
+				Binary:   chunk,
+			},
+			&functions.Overload{
+				Operator: "chunk_list_int",
+				Binary:   chunk,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "bytes_split_bytes_int",
+				Binary:   splitBytes,
+			},
+			&functions.Overload{
+				Operator: "split_bytes_bytes_int",
+				Binary:   splitBytes,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "map_with_map",
+				Binary:   withAll,
+			},
+			&functions.Overload{
+				Operator: "map_with_update_map",
+				Binary:   withUpdate,
+			},
+			&functions.Overload{
+				Operator: "map_with_replace_map",
+				Binary:   withReplace,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "map_merge_patch_map",
+				Binary:   mergePatch,
+			},
+			&functions.Overload{
+				Operator: "merge_patch_map_map",
+				Binary:   mergePatch,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "zip_list",
+				Binary:   zipLists,
+			},
+			&functions.Overload{
+				Operator: "list_zip",
+				Binary:   zipLists,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "list_double_histogram_list_double",
+				Binary:   histogram,
+			},
+			&functions.Overload{
+				Operator: "histogram_list_double_list_double",
+				Binary:   histogram,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "list_double_percentile_double",
+				Binary:   percentile,
+			},
+			&functions.Overload{
+				Operator: "percentile_list_double_double",
+				Binary:   percentile,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "list_join_on_list_string",
+				Function: joinOn,
+			},
+			&functions.Overload{
+				Operator: "join_on_list_list_string",
+				Function: joinOn,
+			},
+			&functions.Overload{
+				Operator: "list_join_on_list_string_string",
+				Function: joinOn,
+			},
+			&functions.Overload{
+				Operator: "join_on_list_list_string_string",
+				Function: joinOn,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "map_pick_string",
+				Binary:   pickFields,
+			},
+			&functions.Overload{
+				Operator: "pick_map_string",
+				Binary:   pickFields,
+			},
+			&functions.Overload{
+				Operator: "map_pick_list_string",
+				Binary:   pickFields,
+			},
+			&functions.Overload{
+				Operator: "pick_map_list_string",
+				Binary:   pickFields,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "dyn_search_string",
+				Binary:   search,
+			},
+			&functions.Overload{
+				Operator: "search_dyn_string",
+				Binary:   search,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "dyn_json_path_string",
+				Binary:   jsonPath,
+			},
+			&functions.Overload{
+				Operator: "json_path_dyn_string",
+				Binary:   jsonPath,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "map_keys",
+				Unary:    mapKeys,
+			},
+			&functions.Overload{
+				Operator: "keys_map",
+				Unary:    mapKeys,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "map_values",
+				Unary:    mapValues,
+			},
+			&functions.Overload{
+				Operator: "values_map",
+				Unary:    mapValues,
+			},
+		),
+	}
+}
+
+func cartesian(arg ref.Val) ref.Val {
+	list, ok := arg.(traits.Lister)
+	if !ok {
+		return types.ValOrErr(list, "no such overload for cartesian")
+	}
+	product := [][]ref.Val{nil}
+	it := list.Iterator()
+	for it.HasNext() == types.True {
+		elem, ok := it.Next().(traits.Lister)
+		if !ok {
+			return types.NewErr("cartesian: element is not a list")
+		}
+		var next [][]ref.Val
+		for _, p := range product {
+			e := elem.Iterator()
+			for e.HasNext() == types.True {
+				q := make([]ref.Val, len(p), len(p)+1)
+				copy(q, p)
+				next = append(next, append(q, e.Next()))
+			}
+		}
+		product = next
+	}
+	res := make([]ref.Val, len(product))
+	for i, p := range product {
+		res[i] = types.NewRefValList(types.DefaultTypeAdapter, p)
+	}
+	return types.NewRefValList(types.DefaultTypeAdapter, res)
+}
+
+func transposeStrict(arg ref.Val) ref.Val {
+	return transpose(arg, nil)
+}
+
+// transpose returns the transpose of the list of lists in arg. If pad is
+// nil, ragged lists are an error, otherwise they are padded with pad.
+func transpose(arg, pad ref.Val) ref.Val {
+	list, ok := arg.(traits.Lister)
+	if !ok {
+		return types.ValOrErr(list, "no such overload for transpose")
+	}
+	var (
+		rows  []traits.Lister
+		width types.Int
+	)
+	it := list.Iterator()
+	for it.HasNext() == types.True {
+		row, ok := it.Next().(traits.Lister)
+		if !ok {
+			return types.NewErr("transpose: element is not a list")
+		}
+		n, _ := row.Size().(types.Int)
+		if len(rows) != 0 && n != width && pad == nil {
+			return types.NewErr("transpose: ragged lists: len %d != %d", n, width)
+		}
+		if n > width {
+			width = n
+		}
+		rows = append(rows, row)
+	}
+	res := make([]ref.Val, width)
+	for i := range res {
+		col := make([]ref.Val, len(rows))
+		for j, row := range rows {
+			if types.Int(i) < row.Size().(types.Int) {
+				col[j] = row.Get(types.Int(i))
+			} else {
+				col[j] = pad
+			}
+		}
+		res[i] = types.NewRefValList(types.DefaultTypeAdapter, col)
+	}
+	return types.NewRefValList(types.DefaultTypeAdapter, res)
+}
+
+func windows(args ...ref.Val) ref.Val {
+	if len(args) != 3 {
+		return types.NewErr("no such overload for windows")
+	}
+	list, ok := args[0].(traits.Lister)
+	if !ok {
+		return types.ValOrErr(list, "no such overload for windows")
+	}
+	size, ok := args[1].(types.Int)
+	if !ok {
+		return types.ValOrErr(size, "no such overload for windows size")
+	}
+	step, ok := args[2].(types.Int)
+	if !ok {
+		return types.ValOrErr(step, "no such overload for windows step")
+	}
+	if size < 1 {
+		return types.NewErr("windows: size must be positive: %d", size)
+	}
+	if step < 1 {
+		return types.NewErr("windows: step must be positive: %d", step)
+	}
+	n, _ := list.Size().(types.Int)
+	var res []ref.Val
+	for i := types.Int(0); i+size <= n; i += step {
+		w := make([]ref.Val, size)
+		for j := range w {
+			w[j] = list.Get(i + types.Int(j))
+		}
+		res = append(res, types.NewRefValList(types.DefaultTypeAdapter, w))
+	}
+	return types.NewRefValList(types.DefaultTypeAdapter, res)
+}
+
+func chunk(arg, size ref.Val) ref.Val {
+	list, ok := arg.(traits.Lister)
+	if !ok {
+		return types.ValOrErr(list, "no such overload for chunk")
+	}
+	n, ok := size.(types.Int)
+	if !ok {
+		return types.ValOrErr(n, "no such overload for chunk size")
+	}
+	if n < 1 {
+		return types.NewErr("chunk: size must be positive: %d", n)
+	}
+	total, _ := list.Size().(types.Int)
+	var res []ref.Val
+	for i := types.Int(0); i < total; i += n {
+		end := total
+		if total-i > n {
+			end = i + n
+		}
+		c := make([]ref.Val, 0, end-i)
+		for j := i; j < end; j++ {
+			c = append(c, list.Get(j))
+		}
+		res = append(res, types.NewRefValList(types.DefaultTypeAdapter, c))
+	}
+	return types.NewRefValList(types.DefaultTypeAdapter, res)
+}
+
+func splitBytes(arg, max ref.Val) ref.Val {
+	b, ok := arg.(types.Bytes)
+	if !ok {
+		return types.ValOrErr(b, "no such overload for split_bytes")
+	}
+	n, ok := max.(types.Int)
+	if !ok {
+		return types.ValOrErr(n, "no such overload for split_bytes max")
+	}
+	if n < 1 {
+		return types.NewErr("split_bytes: max must be positive: %d", n)
+	}
+	var res []ref.Val
+	for len(b) != 0 {
+		end := n
+		if types.Int(len(b)) < end {
+			end = types.Int(len(b))
+		}
+		res = append(res, b[:end:end])
+		b = b[end:]
+	}
+	return types.NewRefValList(types.DefaultTypeAdapter, res)
+}
+
+func flatten(arg ref.Val) ref.Val {
+	obj := arg
+	l, ok := obj.(traits.Lister)
+	if !ok {
+		return types.ValOrErr(obj, "no such overload")
+	}
+	dst := types.NewMutableList(types.DefaultTypeAdapter)
+	flattenParts(dst, l)
+	return dst.ToImmutableList()
+}
+
+func flattenParts(dst traits.MutableLister, val traits.Lister) {
+	it := val.Iterator()
+	for it.HasNext().Value().(bool) {
+		if _, ok := it.Next().(traits.Lister); !ok {
+			dst.Add(val)
+			return
+		}
+	}
+	it = val.Iterator()
+	for it.HasNext() == types.True {
+		flattenParts(dst, it.Next().(traits.Lister))
+	}
+}
+
+func flattenUnique(arg ref.Val) ref.Val {
+	l, ok := arg.(traits.Lister)
+	if !ok {
+		return types.ValOrErr(l, "no such overload")
+	}
+	leaves := flattenLeaves(nil, l)
+	return types.NewRefValList(types.DefaultTypeAdapter, uniqueVals(leaves, leaves))
+}
+
+// flattenLeaves appends the non-list objects in val to dst, recursively.
+// Unlike flattenParts, lists holding both lists and non-list objects are
+// also flattened.
+func flattenLeaves(dst []ref.Val, val traits.Lister) []ref.Val {
+	it := val.Iterator()
+	for it.HasNext() == types.True {
+		elem := it.Next()
+		if l, ok := elem.(traits.Lister); ok {
+			dst = flattenLeaves(dst, l)
+			continue
+		}
+		dst = append(dst, elem)
+	}
+	return dst
+}
+
+func unique(arg ref.Val) ref.Val {
+	list, ok := arg.(traits.Lister)
+	if !ok {
+		return types.ValOrErr(list, "no such overload for unique")
+	}
+	n, _ := list.Size().(types.Int)
+	elems := make([]ref.Val, n)
+	for i := range elems {
+		elems[i] = list.Get(types.Int(i))
+	}
+	return types.NewRefValList(types.DefaultTypeAdapter, uniqueVals(elems, elems))
+}
+
+func uniqueBy(arg, path ref.Val) (vals ref.Val) {
+	defer func() {
+		switch err := recover().(type) {
+		case *types.Err:
+			vals = err
+		}
+	}()
+	list, ok := arg.(traits.Lister)
+	if !ok {
+		return types.ValOrErr(list, "no such overload for unique")
+	}
+	p, ok := path.(types.String)
+	if !ok {
+		return types.ValOrErr(p, "no such overload for unique path")
+	}
+	n, _ := list.Size().(types.Int)
+	elems := make([]ref.Val, n)
+	keys := make([]ref.Val, n)
+	for i := range elems {
+		elems[i] = list.Get(types.Int(i))
+		if _, ok := elems[i].(traits.Mapper); !ok {
+			return types.NewErr("unique: element %d is not a map: %s", i, elems[i].Type())
+		}
+		k := collateFieldPath(elems[i], p)
+		if len(k) != 1 {
+			return types.NewErr("unique: element %d does not have a single value at %s", i, p)
+		}
+		keys[i] = k[0]
+	}
+	return types.NewRefValList(types.DefaultTypeAdapter, uniqueVals(elems, keys))
+}
+
+// uniqueVals returns the elements of elems whose corresponding values in
+// keys have not been seen earlier in keys. elems and keys may be the same
+// slice.
+func uniqueVals(elems, keys []ref.Val) []ref.Val {
+	var (
+		res  = make([]ref.Val, 0, len(elems))
+		seen valSet
+	)
+	for i, k := range keys {
+		if seen.add(k) {
+			res = append(res, elems[i])
+		}
+	}
+	return res
+}
+
+// valSet is a set of CEL values. Values that can be hashed are compared
+// by hash lookup and all other values are compared with the Equal method.
+// The zero value is an empty set.
+type valSet struct {
+	hashed map[interface{}]bool
+	others []ref.Val
+}
+
+// has returns whether v is in the set.
+func (s *valSet) has(v ref.Val) bool {
+	if h, ok := hashKey(v); ok {
+		return s.hashed[h]
+	}
+	for _, o := range s.others {
+		if o.Equal(v) == types.True {
+			return true
+		}
+	}
+	return false
+}
+
+// add adds v to the set, returning whether it was not already present.
+func (s *valSet) add(v ref.Val) bool {
+	if s.has(v) {
+		return false
+	}
+	if h, ok := hashKey(v); ok {
+		if s.hashed == nil {
+			s.hashed = make(map[interface{}]bool)
+		}
+		s.hashed[h] = true
+	} else {
+		s.others = append(s.others, v)
+	}
+	return true
+}
+
+// setUnion returns the unique elements of a followed by the unique
+// elements of b that are not in a.
+func setUnion(a, b ref.Val) ref.Val {
+	la, lb, err := setOperands("union", a, b)
+	if err != nil {
+		return err
+	}
+	var (
+		res  []ref.Val
+		seen valSet
+	)
+	for _, l := range []traits.Lister{la, lb} {
+		it := l.Iterator()
+		for it.HasNext() == types.True {
+			v := it.Next()
+			if seen.add(v) {
+				res = append(res, v)
+			}
+		}
+	}
+	return types.NewRefValList(types.DefaultTypeAdapter, res)
+}
+
+// setIntersection returns the unique elements of a that are in b.
+func setIntersection(a, b ref.Val) ref.Val {
+	return filterSet("intersection", a, b, true)
+}
+
+// setDifference returns the unique elements of a that are not in b.
+func setDifference(a, b ref.Val) ref.Val {
+	return filterSet("difference", a, b, false)
+}
+
+// filterSet returns the unique elements of a whose membership of b
+// matches in.
+func filterSet(name string, a, b ref.Val, in bool) ref.Val {
+	la, lb, err := setOperands(name, a, b)
+	if err != nil {
+		return err
+	}
+	var other valSet
+	it := lb.Iterator()
+	for it.HasNext() == types.True {
+		other.add(it.Next())
+	}
+	var (
+		res  []ref.Val
+		seen valSet
+	)
+	it = la.Iterator()
+	for it.HasNext() == types.True {
+		v := it.Next()
+		if other.has(v) == in && seen.add(v) {
+			res = append(res, v)
+		}
+	}
+	return types.NewRefValList(types.DefaultTypeAdapter, res)
+}
+
+// setOperands returns the operands of the named set operation as lists.
+func setOperands(name string, a, b ref.Val) (la, lb traits.Lister, err ref.Val) {
+	la, ok := a.(traits.Lister)
+	if !ok {
+		return nil, nil, types.ValOrErr(a, "no such overload for %s", name)
+	}
+	lb, ok = b.(traits.Lister)
+	if !ok {
+		return nil, nil, types.ValOrErr(b, "no such overload for %s", name)
+	}
+	return la, lb, nil
+}
+
+func flattenMap(arg ref.Val) ref.Val {
+	m, ok := arg.(traits.Mapper)
+	if !ok {
+		return types.ValOrErr(m, "no such overload for flatten_map")
+	}
+	dst := make(map[ref.Val]ref.Val)
+	err := flattenMapInto(dst, "", m)
+	if err != nil {
+		return err
+	}
+	return types.NewRefValMap(types.DefaultTypeAdapter, dst)
+}
+
+// flattenMapInto adds the flattened fields of val to dst with keys
+// prefixed by prefix.
+func flattenMapInto(dst map[ref.Val]ref.Val, prefix string, val ref.Val) ref.Val {
+	switch obj := val.(type) {
+	case traits.Mapper:
+		if obj.Size() == types.IntZero && prefix != "" {
+			dst[types.String(prefix)] = obj
+			return nil
+		}
+		it := obj.Iterator()
+		for it.HasNext() == types.True {
+			k := it.Next()
+			key, ok := k.(types.String)
+			if !ok {
+				return types.NewErr("flatten_map: invalid key type: %s", k.Type())
+			}
+			err := flattenMapInto(dst, joinFlatKey(prefix, strings.ReplaceAll(string(key), ".", `\.`)), obj.Get(k))
+			if err != nil {
+				return err
+			}
+		}
+	case traits.Lister:
+		if obj.Size() == types.IntZero {
+			dst[types.String(prefix)] = obj
+			return nil
+		}
+		n, _ := obj.Size().(types.Int)
+		for i := types.Int(0); i < n; i++ {
+			err := flattenMapInto(dst, joinFlatKey(prefix, strconv.Itoa(int(i))), obj.Get(i))
+			if err != nil {
+				return err
+			}
+		}
+	default:
+		dst[types.String(prefix)] = val
+	}
+	return nil
+}
+
+func joinFlatKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+func unflattenMap(arg ref.Val) ref.Val {
+	m, ok := arg.(traits.Mapper)
+	if !ok {
+		return types.ValOrErr(m, "no such overload for unflatten_map")
+	}
+	root := &flatNode{children: make(map[string]*flatNode)}
+	it := m.Iterator()
+	for it.HasNext() == types.True {
+		k := it.Next()
+		key, ok := k.(types.String)
+		if !ok {
+			return types.NewErr("unflatten_map: invalid key type: %s", k.Type())
+		}
+		n := root
+		path := string(key)
+		for {
+			if n.val != nil {
+				return types.NewErr("unflatten_map: conflicting key: %s", key)
+			}
+			if n.children == nil {
+				n.children = make(map[string]*flatNode)
+			}
+			dotIdx, escaped := pathSepIndex(path)
+			head := path
+			if dotIdx >= 0 {
+				head = path[:dotIdx]
+			}
+			if escaped {
+				head = strings.ReplaceAll(head, `\.`, ".")
+			}
+			c, ok := n.children[head]
+			if !ok {
+				c = &flatNode{}
+				n.children[head] = c
+			}
+			n = c
+			if dotIdx < 0 {
+				break
+			}
+			path = path[dotIdx+1:]
+		}
+		if n.val != nil || n.children != nil {
+			return types.NewErr("unflatten_map: conflicting key: %s", key)
+		}
+		n.val = m.Get(k)
+	}
+	return root.toMap()
+}
+
+// flatNode is a node in the tree of fields constructed by unflatten_map.
+// Leaf nodes have a non-nil val and internal nodes have non-nil children.
+type flatNode struct {
+	val      ref.Val
+	children map[string]*flatNode
+}
+
+func (n *flatNode) value() ref.Val {
+	if n.children == nil {
+		return n.val
+	}
+	if isListIndexes(n.children) {
+		l := make([]ref.Val, len(n.children))
+		for k, c := range n.children {
+			i, _ := strconv.Atoi(k)
+			l[i] = c.value()
+		}
+		return types.NewRefValList(types.DefaultTypeAdapter, l)
+	}
+	return n.toMap()
+}
+
+func (n *flatNode) toMap() ref.Val {
+	m := make(map[ref.Val]ref.Val, len(n.children))
+	for k, c := range n.children {
+		m[types.String(k)] = c.value()
+	}
+	return types.NewRefValMap(types.DefaultTypeAdapter, m)
+}
+
+// isListIndexes returns whether the keys of m are the canonical decimal
+// representations of 0 to len(m)-1.
+func isListIndexes(m map[string]*flatNode) bool {
+	for k := range m {
+		i, err := strconv.Atoi(k)
+		if err != nil || i < 0 || i >= len(m) || strconv.Itoa(i) != k {
+			return false
+		}
+	}
+	return true
+}
+
+func mapFields(arg, mapping ref.Val) (val ref.Val) {
+	defer func() {
+		switch err := recover().(type) {
+		case *types.Err:
+			val = err
+		}
+	}()
+	obj, ok := arg.(traits.Mapper)
+	if !ok {
+		return types.ValOrErr(obj, "no such overload for map_fields")
+	}
+	m, ok := mapping.(traits.Mapper)
+	if !ok {
+		return types.ValOrErr(m, "no such overload for map_fields")
+	}
+	type move struct {
+		src, dst types.String
+		val      ref.Val
+	}
+	var moves []move
+	it := m.Iterator()
+	for it.HasNext() == types.True {
+		k := it.Next()
+		src, ok := k.(types.String)
+		if !ok {
+			return types.NewErr("invalid type for map_fields source path: %s", k.Type())
+		}
+		dst, ok := m.Get(k).(types.String)
+		if !ok {
+			return types.NewErr("invalid type for map_fields target path: %s", m.Get(k).Type())
+		}
+		v, ok := mapFieldPath(obj, splitFieldPath("map_fields", src))
+		if !ok {
+			continue
+		}
+		moves = append(moves, move{src: src, dst: dst, val: v})
+	}
+	// Apply moves in a stable order so that conflicting target
+	// paths are resolved deterministically.
+	sort.Slice(moves, func(i, j int) bool { return moves[i].src < moves[j].src })
+
+	var res ref.Val = obj
+	for _, mv := range moves {
+		res = deleteFieldPath(res, splitFieldPath("map_fields", mv.src))
+	}
+	for _, mv := range moves {
+		res = setFieldPath(res, splitFieldPath("map_fields", mv.dst), mv.dst, mv.val)
+	}
+	return res
+}
+
+// splitFieldPath returns the unescaped elements of a dotted path. Invalid
+// paths result in a panic with a *types.Err naming fn.
+func splitFieldPath(fn string, path types.String) []types.String {
+	var elems []types.String
+	s := string(path)
+	for {
+		dotIdx, escaped := pathSepIndex(s)
+		if dotIdx == 0 || dotIdx == len(s)-1 || s == "" {
+			panic(types.NewErr("invalid parameter path for %s: %s", fn, path))
+		}
+		head := s
+		if dotIdx >= 0 {
+			head = s[:dotIdx]
+		}
+		if escaped {
+			head = strings.ReplaceAll(head, `\.`, ".")
+		}
+		elems = append(elems, types.String(head))
+		if dotIdx < 0 {
+			return elems
+		}
+		s = s[dotIdx+1:]
+	}
+}
+
+// mapFieldPath returns the value at the path through nested maps described
+// by elems and whether it was found.
+func mapFieldPath(val ref.Val, elems []types.String) (ref.Val, bool) {
+	for _, e := range elems {
+		m, ok := val.(traits.Mapper)
+		if !ok {
+			return nil, false
+		}
+		val, ok = m.Find(e)
+		if !ok {
+			return nil, false
+		}
+	}
+	return val, true
+}
+
+// deleteFieldPath returns a copy of val without the value at the path
+// through nested maps described by elems. If the path is not found, val
+// is returned.
+func deleteFieldPath(val ref.Val, elems []types.String) ref.Val {
+	m, ok := val.(traits.Mapper)
+	if !ok {
+		return val
+	}
+	v, ok := m.Find(elems[0])
+	if !ok {
+		return val
+	}
+	new := make(map[ref.Val]ref.Val)
+	it := m.Iterator()
+	for it.HasNext() == types.True {
+		k := it.Next()
+		new[k] = m.Get(k)
+	}
+	if len(elems) == 1 {
+		delete(new, elems[0])
+	} else {
+		new[elems[0]] = deleteFieldPath(v, elems[1:])
+	}
+	return types.NewRefValMap(types.DefaultTypeAdapter, new)
+}
+
+// setFieldPath returns a copy of obj with v set at the path through nested
+// maps described by elems, creating maps as needed. If a non-map value is
+// found on the path, setFieldPath panics with a *types.Err.
+func setFieldPath(obj ref.Val, elems []types.String, path types.String, v ref.Val) ref.Val {
+	new := make(map[ref.Val]ref.Val)
+	if obj != nil {
+		m, ok := obj.(traits.Mapper)
+		if !ok {
+			panic(types.NewErr("map_fields: cannot set %s: %s is not a map", path, obj.Type().TypeName()))
+		}
+		it := m.Iterator()
+		for it.HasNext() == types.True {
+			k := it.Next()
+			new[k] = m.Get(k)
+		}
+	}
+	if len(elems) == 1 {
+		new[elems[0]] = v
+	} else {
+		new[elems[0]] = setFieldPath(new[elems[0]], elems[1:], path, v)
+	}
+	return types.NewRefValMap(types.DefaultTypeAdapter, new)
+}
+
+// bytesKey is the hash key for a types.Bytes. It is distinct from a
+// types.String key holding the same data.
+type bytesKey string
+
+// hashKey returns a comparable key for v that is consistent with CEL
+// equality, and whether v can be hashed. Numeric values are normalised
+// so that numerically equal values of different types have the same key.
+func hashKey(v ref.Val) (interface{}, bool) {
+	switch v := v.(type) {
+	case types.String, types.Bool, types.Null, types.Int:
+		return v, true
+	case types.Bytes:
+		return bytesKey(v), true
+	case types.Uint:
+		if v <= math.MaxInt64 {
+			return types.Int(v), true
+		}
+		return v, true
+	case types.Double:
+		f := float64(v)
+		switch {
+		case f != math.Trunc(f):
+			// Fractional, infinite or NaN.
+			return v, true
+		case -(1<<63) <= f && f < 1<<63:
+			return types.Int(f), true
+		case 0 <= f && f < 1<<64:
+			return types.Uint(f), true
+		}
+		return v, true
+	default:
+		return nil, false
 	}
-}
-
-func flatten(arg ref.Val) ref.Val {
-	obj := arg
-	l, ok := obj.(traits.Lister)
-	if !ok {
-		return types.ValOrErr(obj, "no such overload")
-	}
-	dst := types.NewMutableList(types.DefaultTypeAdapter)
-	flattenParts(dst, l)
-	return dst.ToImmutableList()
-}
-
-func flattenParts(dst traits.MutableLister, val traits.Lister) {
-	it := val.Iterator()
-	for it.HasNext().Value().(bool) {
-		if _, ok := it.Next().(traits.Lister); !ok {
-			dst.Add(val)
-			return
-		}
-	}
-	it = val.Iterator()
-	for it.HasNext() == types.True {
-		flattenParts(dst, it.Next().(traits.Lister))
-	}
 }
 
 func withAll(dst, src ref.Val) ref.Val {
@@ -648,10 +2452,114 @@ func with(dst, src ref.Val) (res, other map[ref.Val]ref.Val, maybe ref.Val) {
 	return new, m.(map[ref.Val]ref.Val), nil
 }
 
-// TODO: Make this configurable to allow map, list and string emptiness and null.
+func mergePatch(target, patch ref.Val) ref.Val {
+	if _, ok := target.(traits.Mapper); !ok {
+		return types.ValOrErr(target, "no such overload for merge_patch")
+	}
+	if _, ok := patch.(traits.Mapper); !ok {
+		return types.ValOrErr(patch, "no such overload for merge_patch patch")
+	}
+	return applyMergePatch(target, patch)
+}
+
+// applyMergePatch applies patch to target following the MergePatch
+// algorithm in RFC 7386 section 2. target may be nil.
+func applyMergePatch(target, patch ref.Val) ref.Val {
+	p, ok := patch.(traits.Mapper)
+	if !ok {
+		return patch
+	}
+	res := make(map[ref.Val]ref.Val)
+	if t, ok := target.(traits.Mapper); ok {
+		it := t.Iterator()
+		for it.HasNext() == types.True {
+			k := it.Next()
+			res[k] = t.Get(k)
+		}
+	}
+	it := p.Iterator()
+	for it.HasNext() == types.True {
+		k := it.Next()
+		v := p.Get(k)
+		if v == types.NullValue {
+			delete(res, k)
+			continue
+		}
+		res[k] = applyMergePatch(res[k], v)
+	}
+	return types.NewRefValMap(types.DefaultTypeAdapter, res)
+}
+
 func dropEmpty(val ref.Val) ref.Val {
+	return defaultEmptyOptions.drop(val)
+}
+
+func dropEmptyWithOptions(val, opts ref.Val) ref.Val {
+	o, err := emptyOptionsFrom(opts)
+	if err != nil {
+		return types.NewErr("drop_empty: %v", err)
+	}
+	return o.drop(val)
+}
+
+// emptyOptions specifies the values that are considered to be empty
+// by drop_empty.
+type emptyOptions struct {
+	collections bool // Zero-sized maps and lists.
+	strings     bool // Zero-length strings.
+	nulls       bool // Null values.
+}
+
+// defaultEmptyOptions is the drop_empty behaviour when no options are given.
+var defaultEmptyOptions = emptyOptions{collections: true}
+
+// emptyOptionsFrom returns the emptyOptions described by the opts map.
+// Options that are not present in opts take their default value.
+func emptyOptionsFrom(opts ref.Val) (emptyOptions, error) {
+	o := defaultEmptyOptions
+	m, ok := opts.(traits.Mapper)
+	if !ok {
+		return o, fmt.Errorf("invalid options type: %s", opts.Type())
+	}
+	it := m.Iterator()
+	for it.HasNext() == types.True {
+		k := it.Next()
+		v, ok := m.Get(k).(types.Bool)
+		if !ok {
+			return o, fmt.Errorf("invalid type for %v option: %s", k, m.Get(k).Type())
+		}
+		switch k {
+		case types.String("collections"):
+			o.collections = bool(v)
+		case types.String("strings"):
+			o.strings = bool(v)
+		case types.String("nulls"):
+			o.nulls = bool(v)
+		default:
+			return o, fmt.Errorf("unknown option: %v", k)
+		}
+	}
+	return o, nil
+}
+
+// isEmpty returns whether val is empty under the options.
+func (o emptyOptions) isEmpty(val ref.Val) bool {
+	switch val := val.(type) {
+	case types.String:
+		return o.strings && val == ""
+	case types.Null:
+		return o.nulls
+	case iterator:
+		return o.collections && val.Size() == types.IntZero
+	default:
+		return false
+	}
+}
+
+// drop returns val with all its empty elements removed recursively.
+func (o emptyOptions) drop(val ref.Val) ref.Val {
 	obj, ok := val.(iterator)
-	if !ok || !hasEmpty(obj) {
+	if !ok || !o.hasEmpty(obj) {
 		return val
 	}
 
@@ -661,21 +2569,16 @@ func dropEmpty(val ref.Val) ref.Val {
 		it := obj.Iterator()
 		for it.HasNext() == types.True {
 			elem := it.Next()
-			switch val := elem.(type) {
-			case iterator:
-				if val.Size() != types.IntZero {
-					res := dropEmpty(val)
-					if v, ok := res.(traits.Sizer); ok {
-						if v.Size() != types.IntZero {
-							new = append(new, res)
-						}
-					} else {
-						new = append(new, res)
-					}
+			if o.isEmpty(elem) {
+				continue
+			}
+			if _, ok := elem.(iterator); ok {
+				elem = o.drop(elem)
+				if o.isEmpty(elem) {
+					continue
 				}
-			default:
-				new = append(new, val)
 			}
+			new = append(new, elem)
 		}
 		return types.NewRefValList(types.DefaultTypeAdapter, new)
 
@@ -686,58 +2589,41 @@ func dropEmpty(val ref.Val) ref.Val {
 			return types.NewErr("unable to convert map to native: %v", err)
 		}
 		for k, v := range m.(map[ref.Val]ref.Val) {
-			switch val := v.(type) {
-			case iterator:
-				if val.Size() != types.IntZero {
-					res := dropEmpty(v)
-					if v, ok := res.(traits.Sizer); ok {
-						if v.Size() != types.IntZero {
-							new[k] = res
-						}
-					} else {
-						new[k] = res
-					}
-				}
-			default:
-				new[k] = v
-			}
-		}
-		return types.NewRefValMap(types.DefaultTypeAdapter, new)
-
-	default:
-		// This should never happen since non-iterator
-		// types will have been returned in the preamble.
-		return val
-	}
-}
-
-// hasEmpty returns whether val is a map or a list that has any zero-sized
-// map or list elements recursively. Zero sized strings are not considered
-// to be empty.
-func hasEmpty(val iterator) bool {
-	it := val.Iterator()
-	switch val := val.(type) {
-	case traits.Lister:
-		for it.HasNext() == types.True {
-			elem := it.Next()
-			iter, ok := elem.(iterator)
-			if !ok {
-				continue
-			}
-			if iter.Size() == types.IntZero || hasEmpty(iter) {
-				return true
-			}
-		}
-	case traits.Mapper:
-		for it.HasNext() == types.True {
-			elem := val.Get(it.Next())
-			iter, ok := elem.(iterator)
-			if !ok {
+			if o.isEmpty(v) {
 				continue
 			}
-			if iter.Size() == types.IntZero || hasEmpty(iter) {
-				return true
+			if _, ok := v.(iterator); ok {
+				v = o.drop(v)
+				if o.isEmpty(v) {
+					continue
+				}
 			}
+			new[k] = v
+		}
+		return types.NewRefValMap(types.DefaultTypeAdapter, new)
+
+	default:
+		// This should never happen since non-iterator
+		// types will have been returned in the preamble.
+		return val
+	}
+}
+
+// hasEmpty returns whether val is a map or a list that has any elements
+// that are empty under the options recursively.
+func (o emptyOptions) hasEmpty(val iterator) bool {
+	m, isMap := val.(traits.Mapper)
+	it := val.Iterator()
+	for it.HasNext() == types.True {
+		elem := it.Next()
+		if isMap {
+			elem = m.Get(elem)
+		}
+		if o.isEmpty(elem) {
+			return true
+		}
+		if iter, ok := elem.(iterator); ok && o.hasEmpty(iter) {
+			return true
 		}
 	}
 	return false
@@ -908,6 +2794,42 @@ func collateFields(arg, fields ref.Val) (vals ref.Val) {
 	return types.NewErr("invalid parameter type for collate: %v", fields.Type())
 }
 
+func collatePairs(arg, fields ref.Val) (vals ref.Val) {
+	defer func() {
+		switch err := recover().(type) {
+		case *types.Err:
+			vals = err
+		}
+	}()
+	var paths []types.String
+	switch fields := fields.(type) {
+	case types.String:
+		paths = []types.String{fields}
+	case traits.Lister:
+		it := fields.Iterator()
+		for it.HasNext() == types.True {
+			switch field := it.Next().(type) {
+			case types.String:
+				paths = append(paths, field)
+			default:
+				return types.NewErr("invalid parameter type for collate_pairs fields: %v", field.Type())
+			}
+		}
+	default:
+		return types.NewErr("invalid parameter type for collate_pairs: %v", fields.Type())
+	}
+	var pairs []ref.Val
+	for _, path := range paths {
+		for _, v := range collateFieldPath(arg, path) {
+			pairs = append(pairs, types.NewRefValMap(types.DefaultTypeAdapter, map[ref.Val]ref.Val{
+				types.String("path"):  path,
+				types.String("value"): v,
+			}))
+		}
+	}
+	return types.NewRefValList(types.DefaultTypeAdapter, pairs)
+}
+
 func collateFieldPath(arg ref.Val, path types.String) []ref.Val {
 	var collation []ref.Val
 	switch obj := arg.(type) {
@@ -1002,6 +2924,87 @@ func compare(arg ref.Val, cmp types.Int) ref.Val {
 	return min
 }
 
+func sortList(arg ref.Val) ref.Val {
+	list, ok := arg.(traits.Lister)
+	if !ok {
+		return types.ValOrErr(list, "no such overload for sort")
+	}
+	n, _ := list.Size().(types.Int)
+	elems := make([]ref.Val, n)
+	for i := range elems {
+		elems[i] = list.Get(types.Int(i))
+	}
+	return sortedList("sort", elems, elems)
+}
+
+func sortBy(arg, path ref.Val) (sorted ref.Val) {
+	defer func() {
+		switch err := recover().(type) {
+		case *types.Err:
+			sorted = err
+		}
+	}()
+	list, ok := arg.(traits.Lister)
+	if !ok {
+		return types.ValOrErr(list, "no such overload for sort_by")
+	}
+	p, ok := path.(types.String)
+	if !ok {
+		return types.ValOrErr(p, "no such overload for sort_by path")
+	}
+	n, _ := list.Size().(types.Int)
+	elems := make([]ref.Val, n)
+	keys := make([]ref.Val, n)
+	for i := range elems {
+		elems[i] = list.Get(types.Int(i))
+		if _, ok := elems[i].(traits.Mapper); !ok {
+			return types.NewErr("sort_by: element %d is not a map: %s", i, elems[i].Type())
+		}
+		k := collateFieldPath(elems[i], p)
+		if len(k) != 1 {
+			return types.NewErr("sort_by: element %d does not have a single value at %s", i, p)
+		}
+		keys[i] = k[0]
+	}
+	return sortedList("sort_by", elems, keys)
+}
+
+// sortedList returns a list of elems stably sorted by the corresponding
+// values in keys. elems and keys may be the same slice. An error is
+// returned if the keys are not mutually comparable.
+func sortedList(fn string, elems, keys []ref.Val) ref.Val {
+	idx := make([]int, len(elems))
+	for i := range idx {
+		idx[i] = i
+	}
+	for i, k := range keys {
+		if _, ok := k.(traits.Comparer); !ok {
+			return types.NewErr("%s: element %d is not comparable: %s", fn, i, k.Type())
+		}
+	}
+	var err ref.Val
+	sort.SliceStable(idx, func(i, j int) bool {
+		if err != nil {
+			return false
+		}
+		a, b := keys[idx[i]], keys[idx[j]]
+		cmp, ok := a.(traits.Comparer).Compare(b).(types.Int)
+		if !ok {
+			err = types.NewErr("%s: cannot compare %s and %s", fn, a.Type(), b.Type())
+			return false
+		}
+		return cmp < 0
+	})
+	if err != nil {
+		return err
+	}
+	res := make([]ref.Val, len(idx))
+	for i, j := range idx {
+		res[i] = elems[j]
+	}
+	return types.NewRefValList(types.DefaultTypeAdapter, res)
+}
+
 func zipLists(arg0, arg1 ref.Val) ref.Val {
 	keys, ok := arg0.(traits.Lister)
 	if !ok {
@@ -1022,6 +3025,387 @@ func zipLists(arg0, arg1 ref.Val) ref.Val {
 	return types.NewRefValMap(types.DefaultTypeAdapter, m)
 }
 
+func histogram(arg0, arg1 ref.Val) ref.Val {
+	vals, ok := arg0.(traits.Lister)
+	if !ok {
+		return types.ValOrErr(vals, "no such overload for histogram")
+	}
+	bounds, ok := arg1.(traits.Lister)
+	if !ok {
+		return types.ValOrErr(bounds, "no such overload for histogram")
+	}
+	var boundaries []float64
+	it := bounds.Iterator()
+	for it.HasNext() == types.True {
+		b, err := floatValue("histogram", it.Next())
+		if err != nil {
+			return err
+		}
+		if len(boundaries) != 0 && b <= boundaries[len(boundaries)-1] {
+			return types.NewErr("histogram: boundaries not strictly ascending")
+		}
+		boundaries = append(boundaries, b)
+	}
+	counts := make([]int64, len(boundaries)+1)
+	it = vals.Iterator()
+	for it.HasNext() == types.True {
+		v, err := floatValue("histogram", it.Next())
+		if err != nil {
+			return err
+		}
+		counts[sort.Search(len(boundaries), func(i int) bool { return boundaries[i] > v })]++
+	}
+	hist := make(map[string]int64, len(counts))
+	lower := "(-inf"
+	for i, b := range boundaries {
+		upper := strconv.FormatFloat(b, 'g', -1, 64)
+		hist[lower+","+upper+")"] = counts[i]
+		lower = "[" + upper
+	}
+	hist[lower+",+inf)"] = counts[len(boundaries)]
+	return types.DefaultTypeAdapter.NativeToValue(hist)
+}
+
+func percentile(arg0, arg1 ref.Val) ref.Val {
+	list, ok := arg0.(traits.Lister)
+	if !ok {
+		return types.ValOrErr(list, "no such overload for percentile")
+	}
+	p, err := floatValue("percentile", arg1)
+	if err != nil {
+		return err
+	}
+	if p < 0 || 100 < p {
+		return types.NewErr("percentile: percentile out of range [0, 100]: %v", p)
+	}
+	var vals []float64
+	it := list.Iterator()
+	for it.HasNext() == types.True {
+		v, err := floatValue("percentile", it.Next())
+		if err != nil {
+			return err
+		}
+		vals = append(vals, v)
+	}
+	if len(vals) == 0 {
+		return types.NewErr("percentile: empty list")
+	}
+	sort.Float64s(vals)
+	rank := p / 100 * float64(len(vals)-1)
+	lo := math.Floor(rank)
+	hi := math.Ceil(rank)
+	if lo == hi {
+		return types.Double(vals[int(lo)])
+	}
+	return types.Double(vals[int(lo)] + (vals[int(hi)]-vals[int(lo)])*(rank-lo))
+}
+
+// floatValue returns the float64 value of a numeric val, or an error
+// prefixed with name if val is not numeric or is NaN.
+func floatValue(name string, val ref.Val) (float64, ref.Val) {
+	var f float64
+	switch val := val.(type) {
+	case types.Double:
+		f = float64(val)
+	case types.Int:
+		f = float64(val)
+	case types.Uint:
+		f = float64(val)
+	default:
+		return 0, types.NewErr("%s: invalid value type: %s", name, val.Type())
+	}
+	if math.IsNaN(f) {
+		return 0, types.NewErr("%s: invalid value: NaN", name)
+	}
+	return f, nil
+}
+
+func joinOn(args ...ref.Val) ref.Val {
+	if len(args) != 3 && len(args) != 4 {
+		return types.NewErr("no such overload for join_on")
+	}
+	left, ok := args[0].(traits.Lister)
+	if !ok {
+		return types.ValOrErr(left, "no such overload for join_on")
+	}
+	right, ok := args[1].(traits.Lister)
+	if !ok {
+		return types.ValOrErr(right, "no such overload for join_on")
+	}
+	key, ok := args[2].(types.String)
+	if !ok {
+		return types.ValOrErr(key, "no such overload for join_on key")
+	}
+	inner := false
+	if len(args) == 4 {
+		mode, ok := args[3].(types.String)
+		if !ok {
+			return types.ValOrErr(mode, "no such overload for join_on mode")
+		}
+		switch mode {
+		case "left":
+		case "inner":
+			inner = true
+		default:
+			return types.NewErr("join_on: invalid join type: %q", mode)
+		}
+	}
+
+	var rights []traits.Mapper
+	it := right.Iterator()
+	for it.HasNext() == types.True {
+		r, ok := it.Next().(traits.Mapper)
+		if !ok {
+			return types.NewErr("join_on: right element is not a map")
+		}
+		rights = append(rights, r)
+	}
+	var res []ref.Val
+	it = left.Iterator()
+	for it.HasNext() == types.True {
+		elem := it.Next()
+		l, ok := elem.(traits.Mapper)
+		if !ok {
+			return types.NewErr("join_on: left element is not a map")
+		}
+		matched := false
+		if lv, ok := l.Find(key); ok {
+			for _, r := range rights {
+				rv, ok := r.Find(key)
+				if !ok || lv.Equal(rv) != types.True {
+					continue
+				}
+				matched = true
+				merged := withAll(l, r)
+				if types.IsError(merged) {
+					return merged
+				}
+				res = append(res, merged)
+			}
+		}
+		if !matched && !inner {
+			res = append(res, elem)
+		}
+	}
+	return types.NewRefValList(types.DefaultTypeAdapter, res)
+}
+
+func pickFields(obj, fields ref.Val) ref.Val {
+	return selectFields("pick", obj, fields)
+}
+
+func keepFields(obj, fields ref.Val) ref.Val {
+	l, ok := obj.(traits.Lister)
+	if !ok {
+		return selectFields("keep", obj, fields)
+	}
+	n, _ := l.Size().(types.Int)
+	new := make([]ref.Val, 0, n)
+	it := l.Iterator()
+	for it.HasNext() == types.True {
+		elem := it.Next()
+		if _, ok := elem.(traits.Mapper); ok {
+			elem = selectFields("keep", elem, fields)
+			if types.IsError(elem) {
+				return elem
+			}
+		}
+		new = append(new, elem)
+	}
+	return types.NewRefValList(types.DefaultTypeAdapter, new)
+}
+
+// selectFields returns a map holding only the values at the paths in fields
+// in obj. The name of the calling function is used in error messages.
+func selectFields(fn string, obj, fields ref.Val) (val ref.Val) {
+	defer func() {
+		switch err := recover().(type) {
+		case *types.Err:
+			val = err
+		}
+	}()
+	if _, ok := obj.(traits.Mapper); !ok {
+		return types.ValOrErr(obj, "no such overload for %s", fn)
+	}
+	var paths []types.String
+	switch fields := fields.(type) {
+	case types.String:
+		paths = []types.String{fields}
+	case traits.Lister:
+		it := fields.Iterator()
+		for it.HasNext() == types.True {
+			switch field := it.Next().(type) {
+			case types.String:
+				paths = append(paths, field)
+			default:
+				return types.NewErr("invalid parameter type for %s fields: %v", fn, field.Type())
+			}
+		}
+	default:
+		return types.NewErr("invalid parameter type for %s: %v", fn, fields.Type())
+	}
+	var picked ref.Val = types.NewRefValMap(types.DefaultTypeAdapter, map[ref.Val]ref.Val{})
+	for _, path := range paths {
+		v, ok := pickFieldPath(fn, obj, path)
+		if ok {
+			picked = mergePicked(picked, v)
+		}
+	}
+	return picked
+}
+
+// pickFieldPath returns the value at path in arg, wrapped in the structure
+// leading to it, and whether the path was found. Invalid paths result in a
+// panic with a *types.Err naming the calling function, fn.
+func pickFieldPath(fn string, arg ref.Val, path types.String) (ref.Val, bool) {
+	switch obj := arg.(type) {
+	case traits.Lister:
+		var (
+			new   = make([]ref.Val, 0, obj.Size().Value().(int64))
+			found bool
+		)
+		it := obj.Iterator()
+		for it.HasNext() == types.True {
+			v, ok := pickFieldPath(fn, it.Next(), path)
+			if !ok {
+				v = types.NewRefValMap(types.DefaultTypeAdapter, map[ref.Val]ref.Val{})
+			}
+			found = found || ok
+			new = append(new, v)
+		}
+		if !found {
+			return nil, false
+		}
+		return types.NewRefValList(types.DefaultTypeAdapter, new), true
+
+	case traits.Mapper:
+		dotIdx, escaped := pathSepIndex(string(path))
+		switch {
+		case dotIdx == 0, dotIdx == len(path)-1:
+			panic(types.NewErr("invalid parameter path for %s: %s", fn, path))
+
+		case dotIdx < 0:
+			if escaped {
+				path = types.String(strings.ReplaceAll(string(path), `\.`, "."))
+			}
+			v, ok := obj.Find(path)
+			if !ok {
+				return nil, false
+			}
+			return types.NewRefValMap(types.DefaultTypeAdapter, map[ref.Val]ref.Val{path: v}), true
+
+		default:
+			head := path[:dotIdx]
+			if escaped {
+				head = types.String(strings.ReplaceAll(string(head), `\.`, "."))
+			}
+			tail := path[dotIdx+1:]
+			v, ok := obj.Find(head)
+			if !ok {
+				return nil, false
+			}
+			v, ok = pickFieldPath(fn, v, tail)
+			if !ok {
+				return nil, false
+			}
+			return types.NewRefValMap(types.DefaultTypeAdapter, map[ref.Val]ref.Val{head: v}), true
+		}
+
+	default:
+		return nil, false
+	}
+}
+
+// mergePicked merges the picked structure src into dst. Maps are merged by
+// key and lists of equal length are merged element-wise. Otherwise src is
+// returned.
+func mergePicked(dst, src ref.Val) ref.Val {
+	switch d := dst.(type) {
+	case traits.Mapper:
+		s, ok := src.(traits.Mapper)
+		if !ok {
+			return src
+		}
+		new := make(map[ref.Val]ref.Val)
+		it := d.Iterator()
+		for it.HasNext() == types.True {
+			k := it.Next()
+			new[k] = d.Get(k)
+		}
+		it = s.Iterator()
+		for it.HasNext() == types.True {
+			k := it.Next()
+			v := s.Get(k)
+			if old, ok := d.Find(k); ok {
+				v = mergePicked(old, v)
+			}
+			new[k] = v
+		}
+		return types.NewRefValMap(types.DefaultTypeAdapter, new)
+
+	case traits.Lister:
+		s, ok := src.(traits.Lister)
+		if !ok || d.Size() != s.Size() {
+			return src
+		}
+		new := make([]ref.Val, 0, d.Size().Value().(int64))
+		for i := types.Int(0); i < d.Size().(types.Int); i++ {
+			new = append(new, mergePicked(d.Get(i), s.Get(i)))
+		}
+		return types.NewRefValList(types.DefaultTypeAdapter, new)
+
+	default:
+		return src
+	}
+}
+
+func search(obj, key ref.Val) ref.Val {
+	name, ok := key.(types.String)
+	if !ok {
+		return types.ValOrErr(name, "no such overload for search")
+	}
+	found, err := searchKey(obj, name, []ref.Val{})
+	if err != nil {
+		return err
+	}
+	return types.NewRefValList(types.DefaultTypeAdapter, found)
+}
+
+// searchKey appends the values held under key in val to found, recursively.
+// A non-nil ref.Val is returned if an error occurs.
+func searchKey(val ref.Val, key types.String, found []ref.Val) ([]ref.Val, ref.Val) {
+	switch obj := val.(type) {
+	case traits.Mapper:
+		keys := mapKeys(obj)
+		if types.IsError(keys) {
+			return nil, keys
+		}
+		it := keys.(traits.Lister).Iterator()
+		for it.HasNext() == types.True {
+			k := it.Next()
+			v := obj.Get(k)
+			if k.Equal(key) == types.True {
+				found = append(found, v)
+			}
+			var err ref.Val
+			found, err = searchKey(v, key, found)
+			if err != nil {
+				return nil, err
+			}
+		}
+	case traits.Lister:
+		it := obj.Iterator()
+		for it.HasNext() == types.True {
+			var err ref.Val
+			found, err = searchKey(it.Next(), key, found)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return found, nil
+}
+
 func mapKeys(val ref.Val) ref.Val {
 	mapK, ok := val.(traits.Mapper)
 	if !ok {
@@ -20,11 +20,22 @@ package lib
 import (
 	"crypto/hmac"
 	"crypto/md5"
+	"crypto/rand"
 	"crypto/sha1"
 	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base32"
 	"encoding/base64"
 	"encoding/hex"
 	"hash"
+	"hash/adler32"
+	"hash/crc32"
+	"hash/crc64"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/google/cel-go/cel"
 	"github.com/google/cel-go/checker/decls"
@@ -32,6 +43,7 @@ import (
 	"github.com/google/cel-go/common/types/ref"
 	"github.com/google/cel-go/interpreter/functions"
 	"github.com/google/uuid"
+	"golang.org/x/crypto/sha3"
 	expr "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
 )
 
@@ -86,6 +98,80 @@ import (
 //
 //	"aGVsbG8gd29ybGQ".base64_raw_decode()  // return b"hello world"
 //
+// # Base32
+//
+// Returns a string of the base32 encoding of a string or bytes:
+//
+//	base32(<bytes>) -> <string>
+//	base32(<string>) -> <string>
+//	<bytes>.base32() -> <string>
+//	<string>.base32() -> <string>
+//
+// Examples:
+//
+//	"hello world".base32()  // return "NBSWY3DPEB3W64TMMQ======"
+//
+// # Base32 Decode
+//
+// Returns a bytes from the base32 encoding in a string:
+//
+//	base32_decode(<string>) -> <bytes>
+//	<string>.base32_decode() -> <bytes>
+//
+// Examples:
+//
+//	"NBSWY3DPEB3W64TMMQ======".base32_decode()  // return b"hello world"
+//
+// # Base32 Raw
+//
+// Returns a string of the raw unpadded base32 encoding of a string or bytes:
+//
+//	base32_raw(<bytes>) -> <string>
+//	base32_raw(<string>) -> <string>
+//	<bytes>.base32_raw() -> <string>
+//	<string>.base32_raw() -> <string>
+//
+// Examples:
+//
+//	"hello world".base32_raw()  // return "NBSWY3DPEB3W64TMMQ"
+//
+// # Base32 Raw Decode
+//
+// Returns a bytes from the raw base32 encoding in a string:
+//
+//	base32_raw_decode(<string>) -> <bytes>
+//	<string>.base32_raw_decode() -> <bytes>
+//
+// Examples:
+//
+//	"NBSWY3DPEB3W64TMMQ".base32_raw_decode()  // return b"hello world"
+//
+// # Base58
+//
+// Returns a string of the base58 encoding of a string or bytes using the
+// Bitcoin alphabet:
+//
+//	base58(<bytes>) -> <string>
+//	base58(<string>) -> <string>
+//	<bytes>.base58() -> <string>
+//	<string>.base58() -> <string>
+//
+// Examples:
+//
+//	"hello world".base58()  // return "StV1DL6CwTryKyV"
+//
+// # Base58 Decode
+//
+// Returns a bytes from the base58 encoding in a string using the Bitcoin
+// alphabet:
+//
+//	base58_decode(<string>) -> <bytes>
+//	<string>.base58_decode() -> <bytes>
+//
+// Examples:
+//
+//	"StV1DL6CwTryKyV".base58_decode()  // return b"hello world"
+//
 // # Hex
 //
 // Returns a string of the hexadecimal representation of a string or bytes:
@@ -99,6 +185,21 @@ import (
 //
 //	"hello world".hex()  // return "68656c6c6f20776f726c64"
 //
+// # Hex Dump
+//
+// Returns a string of a hex dump of a string or bytes in the format of the
+// output of "hexdump -C" with offsets, hexadecimal values and a printable
+// ASCII column:
+//
+//	hexdump(<bytes>) -> <string>
+//	hexdump(<string>) -> <string>
+//	<bytes>.hexdump() -> <string>
+//	<string>.hexdump() -> <string>
+//
+// Examples:
+//
+//	"hello world".hexdump()  // return "00000000  68 65 6c 6c 6f 20 77 6f  72 6c 64                 |hello world|\n"
+//
 // # MD5
 //
 // Returns a bytes of the md5 hash of a string or bytes:
@@ -141,10 +242,106 @@ import (
 //	"hello world".sha1()        // return "uU0nuZNNPgilLlLX2n2r+sSE7+N6U4DukIj3rOLvzek="
 //	"hello world".sha1().hex()  // return "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"
 //
+// # SHA-384
+//
+// Returns a bytes of the sha-384 cryptographic hash of a string or bytes:
+//
+//	sha384(<bytes>) -> <bytes>
+//	sha384(<string>) -> <bytes>
+//	<bytes>.sha384() -> <bytes>
+//	<string>.sha384() -> <bytes>
+//
+// Examples:
+//
+//	"hello world".sha384().hex()  // return "fdbd8e75a67f29f701a4e040385e2e23986303ea10239211af907fcbb83578b3e417cb71ce646efd0819dd8c088de1bd"
+//
+// # SHA-512
+//
+// Returns a bytes of the sha-512 cryptographic hash of a string or bytes:
+//
+//	sha512(<bytes>) -> <bytes>
+//	sha512(<string>) -> <bytes>
+//	<bytes>.sha512() -> <bytes>
+//	<string>.sha512() -> <bytes>
+//
+// Examples:
+//
+//	"hello world".sha512().hex()  // return "309ecc489c12d6eb4cc40f50c902f2b4d0ed77ee511a7c7a9bcd3ca86d4cd86f989dd35bc5ff499670da34255b45b0cfd830e81f605dcf7dc5542e93ae9cd76f"
+//
+// # SHA3-256
+//
+// Returns a bytes of the sha3-256 cryptographic hash of a string or bytes:
+//
+//	sha3_256(<bytes>) -> <bytes>
+//	sha3_256(<string>) -> <bytes>
+//	<bytes>.sha3_256() -> <bytes>
+//	<string>.sha3_256() -> <bytes>
+//
+// Examples:
+//
+//	"hello world".sha3_256().hex()  // return "644bcc7e564373040999aac89e7622f3ca71fba1d972fd94a31c3bfbf24e3938"
+//
+// # SHA3-512
+//
+// Returns a bytes of the sha3-512 cryptographic hash of a string or bytes:
+//
+//	sha3_512(<bytes>) -> <bytes>
+//	sha3_512(<string>) -> <bytes>
+//	<bytes>.sha3_512() -> <bytes>
+//	<string>.sha3_512() -> <bytes>
+//
+// Examples:
+//
+//	"hello world".sha3_512().hex()  // return "840006653e9ac9e95117a15c915caab81662918e925de9e004f774ff82d7079a40d4d27b1b372657c61d46d470304c88c788b3a4527ad074d1dccbee5dbaa99a"
+//
+// # CRC-32
+//
+// Returns an int of the CRC-32 checksum of a string or bytes using the IEEE
+// polynomial. This is the checksum reported in the CRC32 field of zip
+// archive entries:
+//
+//	crc32(<bytes>) -> <int>
+//	crc32(<string>) -> <int>
+//	<bytes>.crc32() -> <int>
+//	<string>.crc32() -> <int>
+//
+// Examples:
+//
+//	"hello world".crc32()  // return 222957957
+//
+// # CRC-64
+//
+// Returns an int of the CRC-64 checksum of a string or bytes using the ECMA
+// polynomial. Checksums with the high bit set are returned as negative
+// values:
+//
+//	crc64(<bytes>) -> <int>
+//	crc64(<string>) -> <int>
+//	<bytes>.crc64() -> <int>
+//	<string>.crc64() -> <int>
+//
+// Examples:
+//
+//	"hello world".crc64()  // return 5981764153023615706
+//
+// # Adler-32
+//
+// Returns an int of the Adler-32 checksum of a string or bytes:
+//
+//	adler32(<bytes>) -> <int>
+//	adler32(<string>) -> <int>
+//	<bytes>.adler32() -> <int>
+//	<string>.adler32() -> <int>
+//
+// Examples:
+//
+//	"hello world".adler32()  // return 436929629
+//
 // # HMAC
 //
-// Returns a bytes of the HMAC keyed MAC of a string or bytes using either
-// the sha-1 or sha-256 hash function depending on the the second parameter:
+// Returns a bytes of the HMAC keyed MAC of a string or bytes using the
+// sha-1, sha-256, sha-384 or sha-512 hash function depending on the the
+// second parameter:
 //
 //	hmac(<bytes>, <string>, <bytes>) -> <bytes>
 //	hmac(<string>, <string>, <bytes>) -> <bytes>
@@ -156,6 +353,19 @@ import (
 //	"hello world".hmac("sha256", b"key")        // return "C6BvH5pjAEYeQ0VFNdw8QiPkex01cHPXU26ukOwJW+E="
 //	"hello world".hmac("sha256", b"key").hex()  // return "0ba06f1f9a6300461e43454535dc3c4223e47b1d357073d7536eae90ec095be1"
 //
+// # Constant Time Compare
+//
+// Returns whether two bytes values are equal. The time taken is independent
+// of the contents of the values, so it is suitable for comparing secrets
+// such as HMAC signatures. Values of different lengths compare unequal:
+//
+//	constant_time_compare(<bytes>, <bytes>) -> <bool>
+//	<bytes>.constant_time_compare(<bytes>) -> <bool>
+//
+// Examples:
+//
+//	"hello world".hmac("sha256", b"key").constant_time_compare("C6BvH5pjAEYeQ0VFNdw8QiPkex01cHPXU26ukOwJW+E=".base64_decode())  // return true
+//
 // # UUID
 //
 // Returns a string of a random (Version 4) UUID based on the the Go crypto/rand
@@ -166,6 +376,30 @@ import (
 // Examples:
 //
 //	uuid()  // return "582fc58b-f983-4c35-abb1-65c507c1dc0c"
+//
+// # UUID v5
+//
+// Returns a string of a name-based (Version 5) UUID from a namespace and a
+// name. The namespace may be one of the standard namespace names "DNS",
+// "URL", "OID" or "X500", or a UUID string. The same namespace and name
+// always result in the same UUID:
+//
+//	uuid_v5(<string>, <string>) -> <string>
+//
+// Examples:
+//
+//	uuid_v5("DNS", "python.org")  // return "886313e1-3b8a-5372-9b90-0c9aee199e5d"
+//
+// # UUID v7
+//
+// Returns a string of a time-ordered (Version 7) UUID. UUIDs returned by
+// successive calls sort in the order in which they were created:
+//
+//	uuid_v7() -> <string>
+//
+// Examples:
+//
+//	uuid_v7()  // return "0190a3e4-6e0b-7c3d-9d4a-3b2f1c9e8a71"
 func Crypto() cel.EnvOption {
 	return cel.Lib(cryptoLib{})
 }
@@ -243,6 +477,108 @@ func (cryptoLib) CompileOptions() []cel.EnvOption {
 					decls.Bytes,
 				),
 			),
+			decls.NewFunction("base32",
+				decls.NewOverload(
+					"base32_bytes",
+					[]*expr.Type{decls.Bytes},
+					decls.String,
+				),
+				decls.NewInstanceOverload(
+					"bytes_base32",
+					[]*expr.Type{decls.Bytes},
+					decls.String,
+				),
+				decls.NewOverload(
+					"base32_string",
+					[]*expr.Type{decls.String},
+					decls.String,
+				),
+				decls.NewInstanceOverload(
+					"string_base32",
+					[]*expr.Type{decls.String},
+					decls.String,
+				),
+			),
+			decls.NewFunction("base32_decode",
+				decls.NewOverload(
+					"base32_decode_string",
+					[]*expr.Type{decls.String},
+					decls.Bytes,
+				),
+				decls.NewInstanceOverload(
+					"string_base32_decode",
+					[]*expr.Type{decls.String},
+					decls.Bytes,
+				),
+			),
+			decls.NewFunction("base32_raw",
+				decls.NewOverload(
+					"base32_raw_bytes",
+					[]*expr.Type{decls.Bytes},
+					decls.String,
+				),
+				decls.NewInstanceOverload(
+					"bytes_base32_raw",
+					[]*expr.Type{decls.Bytes},
+					decls.String,
+				),
+				decls.NewOverload(
+					"base32_raw_string",
+					[]*expr.Type{decls.String},
+					decls.String,
+				),
+				decls.NewInstanceOverload(
+					"string_base32_raw",
+					[]*expr.Type{decls.String},
+					decls.String,
+				),
+			),
+			decls.NewFunction("base32_raw_decode",
+				decls.NewOverload(
+					"base32_raw_decode_string",
+					[]*expr.Type{decls.String},
+					decls.Bytes,
+				),
+				decls.NewInstanceOverload(
+					"string_base32_raw_decode",
+					[]*expr.Type{decls.String},
+					decls.Bytes,
+				),
+			),
+			decls.NewFunction("base58",
+				decls.NewOverload(
+					"base58_bytes",
+					[]*expr.Type{decls.Bytes},
+					decls.String,
+				),
+				decls.NewInstanceOverload(
+					"bytes_base58",
+					[]*expr.Type{decls.Bytes},
+					decls.String,
+				),
+				decls.NewOverload(
+					"base58_string",
+					[]*expr.Type{decls.String},
+					decls.String,
+				),
+				decls.NewInstanceOverload(
+					"string_base58",
+					[]*expr.Type{decls.String},
+					decls.String,
+				),
+			),
+			decls.NewFunction("base58_decode",
+				decls.NewOverload(
+					"base58_decode_string",
+					[]*expr.Type{decls.String},
+					decls.Bytes,
+				),
+				decls.NewInstanceOverload(
+					"string_base58_decode",
+					[]*expr.Type{decls.String},
+					decls.Bytes,
+				),
+			),
 			decls.NewFunction("hex",
 				decls.NewOverload(
 					"hex_bytes",
@@ -265,6 +601,28 @@ func (cryptoLib) CompileOptions() []cel.EnvOption {
 					decls.String,
 				),
 			),
+			decls.NewFunction("hexdump",
+				decls.NewOverload(
+					"hexdump_bytes",
+					[]*expr.Type{decls.Bytes},
+					decls.String,
+				),
+				decls.NewInstanceOverload(
+					"bytes_hexdump",
+					[]*expr.Type{decls.Bytes},
+					decls.String,
+				),
+				decls.NewOverload(
+					"hexdump_string",
+					[]*expr.Type{decls.String},
+					decls.String,
+				),
+				decls.NewInstanceOverload(
+					"string_hexdump",
+					[]*expr.Type{decls.String},
+					decls.String,
+				),
+			),
 			decls.NewFunction("md5",
 				decls.NewOverload(
 					"md5_bytes",
@@ -331,63 +689,243 @@ func (cryptoLib) CompileOptions() []cel.EnvOption {
 					decls.Bytes,
 				),
 			),
-			decls.NewFunction("hmac",
+			decls.NewFunction("sha384",
 				decls.NewOverload(
-					"hmac_bytes_string_bytes",
-					[]*expr.Type{decls.Bytes, decls.String, decls.Bytes},
+					"sha384_bytes",
+					[]*expr.Type{decls.Bytes},
 					decls.Bytes,
 				),
 				decls.NewInstanceOverload(
-					"bytes_hmac_string_bytes",
-					[]*expr.Type{decls.Bytes, decls.String, decls.Bytes},
+					"bytes_sha384",
+					[]*expr.Type{decls.Bytes},
 					decls.Bytes,
 				),
 				decls.NewOverload(
-					"hmac_string_string_bytes",
-					[]*expr.Type{decls.String, decls.String, decls.Bytes},
+					"sha384_string",
+					[]*expr.Type{decls.String},
 					decls.Bytes,
 				),
 				decls.NewInstanceOverload(
-					"string_hmac_string_bytes",
-					[]*expr.Type{decls.String, decls.String, decls.Bytes},
+					"string_sha384",
+					[]*expr.Type{decls.String},
 					decls.Bytes,
 				),
 			),
-			decls.NewFunction("uuid",
+			decls.NewFunction("sha512",
 				decls.NewOverload(
-					"uuid_string",
-					nil,
-					decls.String,
+					"sha512_bytes",
+					[]*expr.Type{decls.Bytes},
+					decls.Bytes,
+				),
+				decls.NewInstanceOverload(
+					"bytes_sha512",
+					[]*expr.Type{decls.Bytes},
+					decls.Bytes,
+				),
+				decls.NewOverload(
+					"sha512_string",
+					[]*expr.Type{decls.String},
+					decls.Bytes,
+				),
+				decls.NewInstanceOverload(
+					"string_sha512",
+					[]*expr.Type{decls.String},
+					decls.Bytes,
 				),
 			),
-		),
-	}
-}
-
-func (cryptoLib) ProgramOptions() []cel.ProgramOption {
-	return []cel.ProgramOption{
-		cel.Functions(
-			&functions.Overload{
-				Operator: "base64_bytes",
-				Unary:    base64Encode,
-			},
-			&functions.Overload{
-				Operator: "bytes_base64",
-				Unary:    base64Encode,
-			},
-			&functions.Overload{
-				Operator: "base64_string",
-				Unary:    base64Encode,
-			},
-			&functions.Overload{
-				Operator: "string_base64",
-				Unary:    base64Encode,
-			},
-		),
-		cel.Functions(
-			&functions.Overload{
-				Operator: "base64_decode_string",
-				Unary:    base64Decode,
+			decls.NewFunction("sha3_256",
+				decls.NewOverload(
+					"sha3_256_bytes",
+					[]*expr.Type{decls.Bytes},
+					decls.Bytes,
+				),
+				decls.NewInstanceOverload(
+					"bytes_sha3_256",
+					[]*expr.Type{decls.Bytes},
+					decls.Bytes,
+				),
+				decls.NewOverload(
+					"sha3_256_string",
+					[]*expr.Type{decls.String},
+					decls.Bytes,
+				),
+				decls.NewInstanceOverload(
+					"string_sha3_256",
+					[]*expr.Type{decls.String},
+					decls.Bytes,
+				),
+			),
+			decls.NewFunction("sha3_512",
+				decls.NewOverload(
+					"sha3_512_bytes",
+					[]*expr.Type{decls.Bytes},
+					decls.Bytes,
+				),
+				decls.NewInstanceOverload(
+					"bytes_sha3_512",
+					[]*expr.Type{decls.Bytes},
+					decls.Bytes,
+				),
+				decls.NewOverload(
+					"sha3_512_string",
+					[]*expr.Type{decls.String},
+					decls.Bytes,
+				),
+				decls.NewInstanceOverload(
+					"string_sha3_512",
+					[]*expr.Type{decls.String},
+					decls.Bytes,
+				),
+			),
+			decls.NewFunction("crc32",
+				decls.NewOverload(
+					"crc32_bytes",
+					[]*expr.Type{decls.Bytes},
+					decls.Int,
+				),
+				decls.NewInstanceOverload(
+					"bytes_crc32",
+					[]*expr.Type{decls.Bytes},
+					decls.Int,
+				),
+				decls.NewOverload(
+					"crc32_string",
+					[]*expr.Type{decls.String},
+					decls.Int,
+				),
+				decls.NewInstanceOverload(
+					"string_crc32",
+					[]*expr.Type{decls.String},
+					decls.Int,
+				),
+			),
+			decls.NewFunction("crc64",
+				decls.NewOverload(
+					"crc64_bytes",
+					[]*expr.Type{decls.Bytes},
+					decls.Int,
+				),
+				decls.NewInstanceOverload(
+					"bytes_crc64",
+					[]*expr.Type{decls.Bytes},
+					decls.Int,
+				),
+				decls.NewOverload(
+					"crc64_string",
+					[]*expr.Type{decls.String},
+					decls.Int,
+				),
+				decls.NewInstanceOverload(
+					"string_crc64",
+					[]*expr.Type{decls.String},
+					decls.Int,
+				),
+			),
+			decls.NewFunction("adler32",
+				decls.NewOverload(
+					"adler32_bytes",
+					[]*expr.Type{decls.Bytes},
+					decls.Int,
+				),
+				decls.NewInstanceOverload(
+					"bytes_adler32",
+					[]*expr.Type{decls.Bytes},
+					decls.Int,
+				),
+				decls.NewOverload(
+					"adler32_string",
+					[]*expr.Type{decls.String},
+					decls.Int,
+				),
+				decls.NewInstanceOverload(
+					"string_adler32",
+					[]*expr.Type{decls.String},
+					decls.Int,
+				),
+			),
+			decls.NewFunction("hmac",
+				decls.NewOverload(
+					"hmac_bytes_string_bytes",
+					[]*expr.Type{decls.Bytes, decls.String, decls.Bytes},
+					decls.Bytes,
+				),
+				decls.NewInstanceOverload(
+					"bytes_hmac_string_bytes",
+					[]*expr.Type{decls.Bytes, decls.String, decls.Bytes},
+					decls.Bytes,
+				),
+				decls.NewOverload(
+					"hmac_string_string_bytes",
+					[]*expr.Type{decls.String, decls.String, decls.Bytes},
+					decls.Bytes,
+				),
+				decls.NewInstanceOverload(
+					"string_hmac_string_bytes",
+					[]*expr.Type{decls.String, decls.String, decls.Bytes},
+					decls.Bytes,
+				),
+			),
+			decls.NewFunction("constant_time_compare",
+				decls.NewOverload(
+					"constant_time_compare_bytes_bytes",
+					[]*expr.Type{decls.Bytes, decls.Bytes},
+					decls.Bool,
+				),
+				decls.NewInstanceOverload(
+					"bytes_constant_time_compare_bytes",
+					[]*expr.Type{decls.Bytes, decls.Bytes},
+					decls.Bool,
+				),
+			),
+			decls.NewFunction("uuid",
+				decls.NewOverload(
+					"uuid_string",
+					nil,
+					decls.String,
+				),
+			),
+			decls.NewFunction("uuid_v5",
+				decls.NewOverload(
+					"uuid_v5_string_string",
+					[]*expr.Type{decls.String, decls.String},
+					decls.String,
+				),
+			),
+			decls.NewFunction("uuid_v7",
+				decls.NewOverload(
+					"uuid_v7_string",
+					nil,
+					decls.String,
+				),
+			),
+		),
+	}
+}
+
+func (cryptoLib) ProgramOptions() []cel.ProgramOption {
+	return []cel.ProgramOption{
+		cel.Functions(
+			&functions.Overload{
+				Operator: "base64_bytes",
+				Unary:    base64Encode,
+			},
+			&functions.Overload{
+				Operator: "bytes_base64",
+				Unary:    base64Encode,
+			},
+			&functions.Overload{
+				Operator: "base64_string",
+				Unary:    base64Encode,
+			},
+			&functions.Overload{
+				Operator: "string_base64",
+				Unary:    base64Encode,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "base64_decode_string",
+				Unary:    base64Decode,
 			},
 			&functions.Overload{
 				Operator: "string_base64_decode",
@@ -422,6 +960,90 @@ func (cryptoLib) ProgramOptions() []cel.ProgramOption {
 				Unary:    base64RawDecode,
 			},
 		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "base32_bytes",
+				Unary:    base32Encode,
+			},
+			&functions.Overload{
+				Operator: "bytes_base32",
+				Unary:    base32Encode,
+			},
+			&functions.Overload{
+				Operator: "base32_string",
+				Unary:    base32Encode,
+			},
+			&functions.Overload{
+				Operator: "string_base32",
+				Unary:    base32Encode,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "base32_decode_string",
+				Unary:    base32Decode,
+			},
+			&functions.Overload{
+				Operator: "string_base32_decode",
+				Unary:    base32Decode,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "base32_raw_bytes",
+				Unary:    base32RawEncode,
+			},
+			&functions.Overload{
+				Operator: "bytes_base32_raw",
+				Unary:    base32RawEncode,
+			},
+			&functions.Overload{
+				Operator: "base32_raw_string",
+				Unary:    base32RawEncode,
+			},
+			&functions.Overload{
+				Operator: "string_base32_raw",
+				Unary:    base32RawEncode,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "base32_raw_decode_string",
+				Unary:    base32RawDecode,
+			},
+			&functions.Overload{
+				Operator: "string_base32_raw_decode",
+				Unary:    base32RawDecode,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "base58_bytes",
+				Unary:    base58Encode,
+			},
+			&functions.Overload{
+				Operator: "bytes_base58",
+				Unary:    base58Encode,
+			},
+			&functions.Overload{
+				Operator: "base58_string",
+				Unary:    base58Encode,
+			},
+			&functions.Overload{
+				Operator: "string_base58",
+				Unary:    base58Encode,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "base58_decode_string",
+				Unary:    base58Decode,
+			},
+			&functions.Overload{
+				Operator: "string_base58_decode",
+				Unary:    base58Decode,
+			},
+		),
 		cel.Functions(
 			&functions.Overload{
 				Operator: "hex_bytes",
@@ -440,6 +1062,24 @@ func (cryptoLib) ProgramOptions() []cel.ProgramOption {
 				Unary:    hexEncode,
 			},
 		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "hexdump_bytes",
+				Unary:    hexDump,
+			},
+			&functions.Overload{
+				Operator: "bytes_hexdump",
+				Unary:    hexDump,
+			},
+			&functions.Overload{
+				Operator: "hexdump_string",
+				Unary:    hexDump,
+			},
+			&functions.Overload{
+				Operator: "string_hexdump",
+				Unary:    hexDump,
+			},
+		),
 		cel.Functions(
 			&functions.Overload{
 				Operator: "md5_bytes",
@@ -494,6 +1134,132 @@ func (cryptoLib) ProgramOptions() []cel.ProgramOption {
 				Unary:    sha256Hash,
 			},
 		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "sha384_bytes",
+				Unary:    sha384Hash,
+			},
+			&functions.Overload{
+				Operator: "bytes_sha384",
+				Unary:    sha384Hash,
+			},
+			&functions.Overload{
+				Operator: "sha384_string",
+				Unary:    sha384Hash,
+			},
+			&functions.Overload{
+				Operator: "string_sha384",
+				Unary:    sha384Hash,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "sha512_bytes",
+				Unary:    sha512Hash,
+			},
+			&functions.Overload{
+				Operator: "bytes_sha512",
+				Unary:    sha512Hash,
+			},
+			&functions.Overload{
+				Operator: "sha512_string",
+				Unary:    sha512Hash,
+			},
+			&functions.Overload{
+				Operator: "string_sha512",
+				Unary:    sha512Hash,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "sha3_256_bytes",
+				Unary:    sha3Hash256,
+			},
+			&functions.Overload{
+				Operator: "bytes_sha3_256",
+				Unary:    sha3Hash256,
+			},
+			&functions.Overload{
+				Operator: "sha3_256_string",
+				Unary:    sha3Hash256,
+			},
+			&functions.Overload{
+				Operator: "string_sha3_256",
+				Unary:    sha3Hash256,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "sha3_512_bytes",
+				Unary:    sha3Hash512,
+			},
+			&functions.Overload{
+				Operator: "bytes_sha3_512",
+				Unary:    sha3Hash512,
+			},
+			&functions.Overload{
+				Operator: "sha3_512_string",
+				Unary:    sha3Hash512,
+			},
+			&functions.Overload{
+				Operator: "string_sha3_512",
+				Unary:    sha3Hash512,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "crc32_bytes",
+				Unary:    crc32Checksum,
+			},
+			&functions.Overload{
+				Operator: "bytes_crc32",
+				Unary:    crc32Checksum,
+			},
+			&functions.Overload{
+				Operator: "crc32_string",
+				Unary:    crc32Checksum,
+			},
+			&functions.Overload{
+				Operator: "string_crc32",
+				Unary:    crc32Checksum,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "crc64_bytes",
+				Unary:    crc64Checksum,
+			},
+			&functions.Overload{
+				Operator: "bytes_crc64",
+				Unary:    crc64Checksum,
+			},
+			&functions.Overload{
+				Operator: "crc64_string",
+				Unary:    crc64Checksum,
+			},
+			&functions.Overload{
+				Operator: "string_crc64",
+				Unary:    crc64Checksum,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "adler32_bytes",
+				Unary:    adler32Checksum,
+			},
+			&functions.Overload{
+				Operator: "bytes_adler32",
+				Unary:    adler32Checksum,
+			},
+			&functions.Overload{
+				Operator: "adler32_string",
+				Unary:    adler32Checksum,
+			},
+			&functions.Overload{
+				Operator: "string_adler32",
+				Unary:    adler32Checksum,
+			},
+		),
 		cel.Functions(
 			&functions.Overload{
 				Operator: "hmac_bytes_string_bytes",
@@ -512,12 +1278,34 @@ func (cryptoLib) ProgramOptions() []cel.ProgramOption {
 				Function: hmacHash,
 			},
 		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "constant_time_compare_bytes_bytes",
+				Binary:   constantTimeCompare,
+			},
+			&functions.Overload{
+				Operator: "bytes_constant_time_compare_bytes",
+				Binary:   constantTimeCompare,
+			},
+		),
 		cel.Functions(
 			&functions.Overload{
 				Operator: "uuid_string",
 				Function: uuidString,
 			},
 		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "uuid_v5_string_string",
+				Binary:   uuidV5String,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "uuid_v7_string",
+				Function: uuidV7String,
+			},
+		),
 	}
 }
 
@@ -569,6 +1357,150 @@ func base64RawDecode(val ref.Val) ref.Val {
 	}
 }
 
+func base32Encode(val ref.Val) ref.Val {
+	switch val := val.(type) {
+	case types.Bytes:
+		return types.String(base32.StdEncoding.EncodeToString(val))
+	case types.String:
+		return types.String(base32.StdEncoding.EncodeToString([]byte(val)))
+	default:
+		return types.NewErr("invalid type for base32: %s", val.Type())
+	}
+}
+
+func base32Decode(val ref.Val) ref.Val {
+	switch val := val.(type) {
+	case types.String:
+		b, err := base32.StdEncoding.DecodeString(string(val))
+		if err != nil {
+			return types.NewErr("invalid base32 encoding: %w", err)
+		}
+		return types.Bytes(b)
+	default:
+		return types.NewErr("invalid type for base32_decode: %s", val.Type())
+	}
+}
+
+func base32RawEncode(val ref.Val) ref.Val {
+	switch val := val.(type) {
+	case types.Bytes:
+		return types.String(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(val))
+	case types.String:
+		return types.String(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte(val)))
+	default:
+		return types.NewErr("invalid type for base32_raw: %s", val.Type())
+	}
+}
+
+func base32RawDecode(val ref.Val) ref.Val {
+	switch val := val.(type) {
+	case types.String:
+		b, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(string(val))
+		if err != nil {
+			return types.NewErr("invalid raw base32 encoding: %w", err)
+		}
+		return types.Bytes(b)
+	default:
+		return types.NewErr("invalid type for base32_raw_decode: %s", val.Type())
+	}
+}
+
+func base58Encode(val ref.Val) ref.Val {
+	switch val := val.(type) {
+	case types.Bytes:
+		return types.String(encodeBase58(val))
+	case types.String:
+		return types.String(encodeBase58([]byte(val)))
+	default:
+		return types.NewErr("invalid type for base58: %s", val.Type())
+	}
+}
+
+func base58Decode(val ref.Val) ref.Val {
+	switch val := val.(type) {
+	case types.String:
+		b, err := decodeBase58(string(val))
+		if err != nil {
+			return types.NewErr("invalid base58 encoding: %w", err)
+		}
+		return types.Bytes(b)
+	default:
+		return types.NewErr("invalid type for base58_decode: %s", val.Type())
+	}
+}
+
+// base58Alphabet is the Bitcoin base58 alphabet.
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// encodeBase58 returns the base58 encoding of b. Leading zero bytes are
+// encoded as leading '1' characters.
+func encodeBase58(b []byte) string {
+	var zeros int
+	for zeros < len(b) && b[zeros] == 0 {
+		zeros++
+	}
+	// The encoding is at most log(256)/log(58) ≈ 1.37 times the input length.
+	digits := make([]byte, 0, (len(b)-zeros)*138/100+1)
+	for _, c := range b[zeros:] {
+		carry := int(c)
+		for i := range digits {
+			carry += int(digits[i]) << 8
+			digits[i] = byte(carry % 58)
+			carry /= 58
+		}
+		for carry > 0 {
+			digits = append(digits, byte(carry%58))
+			carry /= 58
+		}
+	}
+	dst := make([]byte, zeros+len(digits))
+	for i := 0; i < zeros; i++ {
+		dst[i] = base58Alphabet[0]
+	}
+	for i, d := range digits {
+		dst[len(dst)-1-i] = base58Alphabet[d]
+	}
+	return string(dst)
+}
+
+// decodeBase58 returns the bytes represented by the base58 string s.
+func decodeBase58(s string) ([]byte, error) {
+	var zeros int
+	for zeros < len(s) && s[zeros] == base58Alphabet[0] {
+		zeros++
+	}
+	// The decoding is at most log(58)/log(256) ≈ 0.733 times the input length.
+	digits := make([]byte, 0, (len(s)-zeros)*733/1000+1)
+	for i := zeros; i < len(s); i++ {
+		carry := strings.IndexByte(base58Alphabet, s[i])
+		if carry < 0 {
+			return nil, base58CorruptInputError(i)
+		}
+		for j := range digits {
+			carry += int(digits[j]) * 58
+			digits[j] = byte(carry)
+			carry >>= 8
+		}
+		for carry > 0 {
+			digits = append(digits, byte(carry))
+			carry >>= 8
+		}
+	}
+	dst := make([]byte, zeros+len(digits))
+	for i, d := range digits {
+		dst[len(dst)-1-i] = d
+	}
+	return dst, nil
+}
+
+// base58CorruptInputError is the offset of an invalid character in a
+// base58 string.
+type base58CorruptInputError int64
+
+func (e base58CorruptInputError) Error() string {
+	return "illegal base58 data at input byte " + strconv.FormatInt(int64(e), 10)
+}
+
 func hexEncode(val ref.Val) ref.Val {
 	switch val := val.(type) {
 	case types.Bytes:
@@ -580,6 +1512,17 @@ func hexEncode(val ref.Val) ref.Val {
 	}
 }
 
+func hexDump(val ref.Val) ref.Val {
+	switch val := val.(type) {
+	case types.Bytes:
+		return types.String(hex.Dump(val))
+	case types.String:
+		return types.String(hex.Dump([]byte(val)))
+	default:
+		return types.NewErr("invalid type for hexdump: %s", val.Type())
+	}
+}
+
 func md5Hash(val ref.Val) ref.Val {
 	switch val := val.(type) {
 	case types.Bytes:
@@ -625,6 +1568,102 @@ func sha256Hash(val ref.Val) ref.Val {
 	}
 }
 
+func sha384Hash(val ref.Val) ref.Val {
+	switch val := val.(type) {
+	case types.Bytes:
+		h := sha512.New384()
+		h.Write(val)
+		return types.Bytes(h.Sum(nil))
+	case types.String:
+		h := sha512.New384()
+		h.Write([]byte(val))
+		return types.Bytes(h.Sum(nil))
+	default:
+		return types.NewErr("invalid type for sha384: %s", val.Type())
+	}
+}
+
+func sha512Hash(val ref.Val) ref.Val {
+	switch val := val.(type) {
+	case types.Bytes:
+		h := sha512.New()
+		h.Write(val)
+		return types.Bytes(h.Sum(nil))
+	case types.String:
+		h := sha512.New()
+		h.Write([]byte(val))
+		return types.Bytes(h.Sum(nil))
+	default:
+		return types.NewErr("invalid type for sha512: %s", val.Type())
+	}
+}
+
+func sha3Hash256(val ref.Val) ref.Val {
+	switch val := val.(type) {
+	case types.Bytes:
+		h := sha3.New256()
+		h.Write(val)
+		return types.Bytes(h.Sum(nil))
+	case types.String:
+		h := sha3.New256()
+		h.Write([]byte(val))
+		return types.Bytes(h.Sum(nil))
+	default:
+		return types.NewErr("invalid type for sha3_256: %s", val.Type())
+	}
+}
+
+func sha3Hash512(val ref.Val) ref.Val {
+	switch val := val.(type) {
+	case types.Bytes:
+		h := sha3.New512()
+		h.Write(val)
+		return types.Bytes(h.Sum(nil))
+	case types.String:
+		h := sha3.New512()
+		h.Write([]byte(val))
+		return types.Bytes(h.Sum(nil))
+	default:
+		return types.NewErr("invalid type for sha3_512: %s", val.Type())
+	}
+}
+
+func crc32Checksum(val ref.Val) ref.Val {
+	switch val := val.(type) {
+	case types.Bytes:
+		return types.Int(crc32.ChecksumIEEE(val))
+	case types.String:
+		return types.Int(crc32.ChecksumIEEE([]byte(val)))
+	default:
+		return types.NewErr("invalid type for crc32: %s", val.Type())
+	}
+}
+
+// crc64Table is the CRC-64 table for the ECMA polynomial.
+var crc64Table = crc64.MakeTable(crc64.ECMA)
+
+func crc64Checksum(val ref.Val) ref.Val {
+	switch val := val.(type) {
+	case types.Bytes:
+		return types.Int(crc64.Checksum(val, crc64Table))
+	case types.String:
+		return types.Int(crc64.Checksum([]byte(val), crc64Table))
+	default:
+		return types.NewErr("invalid type for crc64: %s", val.Type())
+	}
+}
+
+func adler32Checksum(val ref.Val) ref.Val {
+	switch val := val.(type) {
+	case types.Bytes:
+		return types.Int(adler32.Checksum(val))
+	case types.String:
+		return types.Int(adler32.Checksum([]byte(val)))
+	default:
+		return types.NewErr("invalid type for adler32: %s", val.Type())
+	}
+}
+
 func hmacHash(args ...ref.Val) ref.Val {
 	if len(args) != 3 {
 		return types.NewErr("no such overload for hmac")
@@ -652,6 +1691,10 @@ func hmacHash(args ...ref.Val) ref.Val {
 		mac = hmac.New(sha1.New, key)
 	case "sha256":
 		mac = hmac.New(sha256.New, key)
+	case "sha384":
+		mac = hmac.New(sha512.New384, key)
+	case "sha512":
+		mac = hmac.New(sha512.New, key)
 	default:
 		return types.NewErr("invalid hash for hmac: %s", hashName)
 	}
@@ -659,6 +1702,18 @@ func hmacHash(args ...ref.Val) ref.Val {
 	return types.Bytes(mac.Sum(nil))
 }
 
+func constantTimeCompare(a, b ref.Val) ref.Val {
+	x, ok := a.(types.Bytes)
+	if !ok {
+		return types.ValOrErr(a, "no such overload for constant_time_compare")
+	}
+	y, ok := b.(types.Bytes)
+	if !ok {
+		return types.ValOrErr(b, "no such overload for constant_time_compare")
+	}
+	return types.Bool(subtle.ConstantTimeCompare(x, y) == 1)
+}
+
 func uuidString(args ...ref.Val) ref.Val {
 	id, err := uuid.NewRandom()
 	if err != nil {
@@ -666,3 +1721,81 @@ func uuidString(args ...ref.Val) ref.Val {
 	}
 	return types.String(id.String())
 }
+
+// uuidNamespaces are the standard name-based UUID namespaces.
+var uuidNamespaces = map[string]uuid.UUID{
+	"DNS":  uuid.NameSpaceDNS,
+	"URL":  uuid.NameSpaceURL,
+	"OID":  uuid.NameSpaceOID,
+	"X500": uuid.NameSpaceX500,
+}
+
+func uuidV5String(namespace, name ref.Val) ref.Val {
+	ns, ok := namespace.(types.String)
+	if !ok {
+		return types.ValOrErr(namespace, "no such overload for uuid_v5")
+	}
+	n, ok := name.(types.String)
+	if !ok {
+		return types.ValOrErr(name, "no such overload for uuid_v5")
+	}
+	space, ok := uuidNamespaces[string(ns)]
+	if !ok {
+		var err error
+		space, err = uuid.Parse(string(ns))
+		if err != nil {
+			return types.NewErr("uuid_v5: invalid namespace: %v", err)
+		}
+	}
+	return types.String(uuid.NewSHA1(space, []byte(n)).String())
+}
+
+func uuidV7String(args ...ref.Val) ref.Val {
+	id, err := newUUIDv7()
+	if err != nil {
+		return types.NewErr("failed to create uuid: %v", err)
+	}
+	return types.String(id.String())
+}
+
+// lastV7 holds the time and sequence state of the last Version 7 UUID
+// created, and is used to ensure that successive UUIDs are ordered.
+var lastV7 struct {
+	sync.Mutex
+	t int64
+}
+
+// newUUIDv7 returns a Version 7 UUID as described in RFC 9562. The
+// 12 bits following the millisecond timestamp hold the sub-millisecond
+// time, incremented when necessary to ensure monotonicity.
+func newUUIDv7() (uuid.UUID, error) {
+	var id uuid.UUID
+	_, err := rand.Read(id[:])
+	if err != nil {
+		return id, err
+	}
+
+	now := time.Now().UnixNano()
+	ms := now / int64(time.Millisecond)
+	// The sub-millisecond remainder is less than 2^20, so
+	// scale it to fit in 12 bits.
+	t := ms<<12 | (now-ms*int64(time.Millisecond))>>8
+	lastV7.Lock()
+	if t <= lastV7.t {
+		t = lastV7.t + 1
+	}
+	lastV7.t = t
+	lastV7.Unlock()
+
+	ms, seq := t>>12, t&0xfff
+	id[0] = byte(ms >> 40)
+	id[1] = byte(ms >> 32)
+	id[2] = byte(ms >> 24)
+	id[3] = byte(ms >> 16)
+	id[4] = byte(ms >> 8)
+	id[5] = byte(ms)
+	id[6] = 0x70 | byte(seq>>8)
+	id[7] = byte(seq)
+	id[8] = 0x80 | id[8]&0x3f
+	return id, nil
+}
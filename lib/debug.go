@@ -24,6 +24,7 @@ import (
 	"github.com/google/cel-go/cel"
 	"github.com/google/cel-go/common/types"
 	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
 )
 
 // Debug returns a cel.EnvOption to configure extended functions for allowing
@@ -43,6 +44,21 @@ import (
 // Examples:
 //
 //	debug("tag", expr) // return expr even if it is an error and logs with "tag".
+//
+// # Kind
+//
+// kind returns the name of the kind of its argument. The returned name is one
+// of "map", "list", "string", "bytes", "int", "uint", "double", "bool",
+// "null", "timestamp" and "duration", or the CEL type name for other kinds:
+//
+//	kind(<dyn>) -> <string>
+//
+// Examples:
+//
+//	kind({"a": 1})     // return "map"
+//	kind([1, 2, 3])    // return "list"
+//	kind(1.5)          // return "double"
+//	kind(null)         // return "null"
 func Debug(handler func(tag string, value any)) cel.EnvOption {
 	return cel.Lib(debug{handler: handler})
 }
@@ -62,6 +78,14 @@ func (l debug) CompileOptions() []cel.EnvOption {
 				cel.OverloadIsNonStrict(),
 			),
 		),
+		cel.Function("kind",
+			cel.Overload(
+				"kind_dyn",
+				[]*cel.Type{cel.DynType},
+				cel.StringType,
+				cel.UnaryBinding(kind),
+			),
+		),
 	}
 }
 
@@ -89,3 +113,32 @@ func (l debug) logDebug(arg0, arg1 ref.Val) ref.Val {
 	}
 	return arg1
 }
+
+func kind(arg ref.Val) ref.Val {
+	switch arg.(type) {
+	case traits.Mapper:
+		return types.String("map")
+	case traits.Lister:
+		return types.String("list")
+	case types.String:
+		return types.String("string")
+	case types.Bytes:
+		return types.String("bytes")
+	case types.Int:
+		return types.String("int")
+	case types.Uint:
+		return types.String("uint")
+	case types.Double:
+		return types.String("double")
+	case types.Bool:
+		return types.String("bool")
+	case types.Null:
+		return types.String("null")
+	case types.Timestamp:
+		return types.String("timestamp")
+	case types.Duration:
+		return types.String("duration")
+	default:
+		return types.String(arg.Type().TypeName())
+	}
+}
@@ -18,10 +18,16 @@
 package lib
 
 import (
+	"archive/zip"
 	"bytes"
+	"encoding/base64"
+	"errors"
 	"io"
+	"io/fs"
 	"os"
 	"sort"
+	"strings"
+	"sync"
 
 	"github.com/google/cel-go/cel"
 	"github.com/google/cel-go/checker/decls"
@@ -36,6 +42,7 @@ import (
 // type. The values in the map must be one of: func([]byte), func(io.Reader) io.Reader,
 // func(io.Reader) (io.Reader, error) or func(io.Reader) ref.Val. If the
 // transform is func([]byte) it is expected to mutate the bytes in place.
+// Parameterised transforms are accepted as described for MIME.
 //
 // # Dir
 //
@@ -102,6 +109,20 @@ import (
 //	string(file('hello.txt'))                 // return "world!\n"
 //	string(file('hello.txt', 'text/rot13'))   // return "jbeyq!\n"
 //	string(file('hello.txt', 'text/upper'))   // return "WORLD!\n"
+//
+// # Zip File
+//
+// zip_file returns the contents of a single entry in a zip archive without
+// reading the other entries into memory:
+//
+//	zip_file(<string>, <string>) -> <bytes>
+//
+// The first parameter is the path of the zip archive and the second is the
+// name of the entry within the archive.
+//
+// Examples:
+//
+//	string(zip_file('hello.zip', 'subdir/a.txt'))  // return "hello world!\n"
 func File(mimetypes map[string]interface{}) cel.EnvOption {
 	return cel.Lib(fileLib{transforms: mimetypes})
 }
@@ -132,6 +153,13 @@ func (fileLib) CompileOptions() []cel.EnvOption {
 					decls.Dyn,
 				),
 			),
+			decls.NewFunction("zip_file",
+				decls.NewOverload(
+					"zip_file_string_string",
+					[]*expr.Type{decls.String, decls.String},
+					decls.Bytes,
+				),
+			),
 		),
 	}
 }
@@ -154,6 +182,12 @@ func (l fileLib) ProgramOptions() []cel.ProgramOption {
 				Binary:   l.readMIMEFile,
 			},
 		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "zip_file_string_string",
+				Binary:   readZipEntry,
+			},
+		),
 	}
 }
 
@@ -209,9 +243,9 @@ func (l fileLib) readMIMEFile(arg0, arg1 ref.Val) ref.Val {
 	if !ok {
 		return types.ValOrErr(mimetype, "no such overload for mime type: %s", arg1.Type())
 	}
-	transform, ok := l.transforms[string(mimetype)]
-	if !ok {
-		return types.NewErr("unknown transform: %q", mimetype)
+	transform, err := lookupTransform(l.transforms, string(mimetype))
+	if err != nil {
+		return types.NewErr("%v", err)
 	}
 	f, err := os.Open(string(path))
 	if err != nil {
@@ -251,3 +285,209 @@ func (l fileLib) readMIMEFile(arg0, arg1 ref.Val) ref.Val {
 	}
 	return types.NewErr("invalid transform: %T", transform)
 }
+
+func readZipEntry(arg0, arg1 ref.Val) ref.Val {
+	path, ok := arg0.(types.String)
+	if !ok {
+		return types.ValOrErr(path, "no such overload for zip_file path: %s", arg0.Type())
+	}
+	name, ok := arg1.(types.String)
+	if !ok {
+		return types.ValOrErr(name, "no such overload for zip_file entry: %s", arg1.Type())
+	}
+	z, err := zip.OpenReader(string(path))
+	if err != nil {
+		return types.NewErr("zip_file: %v", err)
+	}
+	defer z.Close()
+	for _, f := range z.File {
+		if f.Name != string(name) {
+			continue
+		}
+		b, err := readZipFile(f)
+		if err != nil {
+			return types.NewErr("zip_file: %v", err)
+		}
+		return types.Bytes(b)
+	}
+	return types.NewErr("zip_file: no entry %q in %s", name, path)
+}
+
+// WriteFile returns a cel.EnvOption to configure extended functions for
+// writing files and a cleanup function that removes any temporary files
+// created by the program. The functions are provided separately from File
+// so that writing to the file system is an explicit opt-in. Temporary files
+// are created in dir, or in the default directory for temporary files if
+// dir is empty. The cleanup function should be called when the program is
+// no longer being evaluated.
+//
+// # Temp File
+//
+// temp_file writes the bytes or string to a new temporary file and returns
+// its path. The file is registered for removal by the cleanup function
+// returned by WriteFile:
+//
+//	temp_file(<bytes>) -> <string>
+//	temp_file(<string>) -> <string>
+//
+// Examples:
+//
+//	file(temp_file("hello world!"))  // return b"hello world!"
+//
+// # Base64 Decode To File
+//
+// base64_decode_to_file decodes the standard base64 encoded string and writes
+// the decoded bytes to the file at the provided path, returning the number of
+// bytes written. The decoded data is streamed to the file rather than being
+// held in memory. If the file exists it is truncated. If decoding fails the
+// partially written file is removed:
+//
+//	base64_decode_to_file(<string>, <string>) -> <int>
+//
+// Examples:
+//
+//	base64_decode_to_file("aGVsbG8gd29ybGQh", "hello.txt")  // return 12
+func WriteFile(dir string) (opt cel.EnvOption, cleanup func() error) {
+	tmp := &tempFiles{dir: dir}
+	return cel.Lib(writeFileLib{temp: tmp}), tmp.removeAll
+}
+
+type writeFileLib struct {
+	temp *tempFiles
+}
+
+func (writeFileLib) CompileOptions() []cel.EnvOption {
+	return []cel.EnvOption{
+		cel.Declarations(
+			decls.NewFunction("temp_file",
+				decls.NewOverload(
+					"temp_file_bytes",
+					[]*expr.Type{decls.Bytes},
+					decls.String,
+				),
+				decls.NewOverload(
+					"temp_file_string",
+					[]*expr.Type{decls.String},
+					decls.String,
+				),
+			),
+			decls.NewFunction("base64_decode_to_file",
+				decls.NewOverload(
+					"base64_decode_to_file_string_string",
+					[]*expr.Type{decls.String, decls.String},
+					decls.Int,
+				),
+			),
+		),
+	}
+}
+
+func (l writeFileLib) ProgramOptions() []cel.ProgramOption {
+	return []cel.ProgramOption{
+		cel.Functions(
+			&functions.Overload{
+				Operator: "temp_file_bytes",
+				Unary:    l.writeTempFile,
+			},
+			&functions.Overload{
+				Operator: "temp_file_string",
+				Unary:    l.writeTempFile,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "base64_decode_to_file_string_string",
+				Binary:   base64DecodeToFile,
+			},
+		),
+	}
+}
+
+func (l writeFileLib) writeTempFile(arg ref.Val) ref.Val {
+	var data []byte
+	switch arg := arg.(type) {
+	case types.Bytes:
+		data = []byte(arg)
+	case types.String:
+		data = []byte(arg)
+	default:
+		return types.NoSuchOverloadErr()
+	}
+	f, err := l.temp.create()
+	if err != nil {
+		return types.NewErr("temp_file: %v", err)
+	}
+	_, err = f.Write(data)
+	if err != nil {
+		f.Close()
+		return types.NewErr("temp_file: %v", err)
+	}
+	err = f.Close()
+	if err != nil {
+		return types.NewErr("temp_file: %v", err)
+	}
+	return types.String(f.Name())
+}
+
+func base64DecodeToFile(arg, path ref.Val) ref.Val {
+	data, ok := arg.(types.String)
+	if !ok {
+		return types.ValOrErr(data, "no such overload for base64_decode_to_file")
+	}
+	p, ok := path.(types.String)
+	if !ok {
+		return types.ValOrErr(p, "no such overload for base64_decode_to_file path")
+	}
+	f, err := os.Create(string(p))
+	if err != nil {
+		return types.NewErr("base64_decode_to_file: %v", err)
+	}
+	n, err := io.Copy(f, base64.NewDecoder(base64.StdEncoding, strings.NewReader(string(data))))
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return types.NewErr("base64_decode_to_file: %v", err)
+	}
+	err = f.Close()
+	if err != nil {
+		return types.NewErr("base64_decode_to_file: %v", err)
+	}
+	return types.Int(n)
+}
+
+// tempFiles is a registry of temporary files created during program
+// evaluation.
+type tempFiles struct {
+	dir string
+
+	mu    sync.Mutex
+	paths []string
+}
+
+// create returns a new temporary file and registers it for removal.
+func (t *tempFiles) create() (*os.File, error) {
+	f, err := os.CreateTemp(t.dir, "mito-*")
+	if err != nil {
+		return nil, err
+	}
+	t.mu.Lock()
+	t.paths = append(t.paths, f.Name())
+	t.mu.Unlock()
+	return f, nil
+}
+
+// removeAll removes all registered temporary files, returning the first
+// error encountered.
+func (t *tempFiles) removeAll() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var first error
+	for _, p := range t.paths {
+		err := os.Remove(p)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) && first == nil {
+			first = err
+		}
+	}
+	t.paths = nil
+	return first
+}
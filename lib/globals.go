@@ -23,6 +23,7 @@ import (
 
 	"github.com/google/cel-go/cel"
 	"github.com/google/cel-go/checker/decls"
+	"github.com/google/cel-go/interpreter"
 	expr "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
 )
 
@@ -38,8 +39,60 @@ func Globals(vars map[string]interface{}) cel.EnvOption {
 type globalsLib map[string]interface{}
 
 func (l globalsLib) CompileOptions() []cel.EnvOption {
-	globals := make([]*expr.Decl, 0, len(l))
-	for name, val := range l {
+	return []cel.EnvOption{cel.Declarations(globalDecls(l)...)}
+}
+
+func (l globalsLib) ProgramOptions() []cel.ProgramOption {
+	return []cel.ProgramOption{
+		cel.Globals(map[string]interface{}(l)),
+	}
+}
+
+// DynamicGlobals returns a cel.EnvOption to configure global variables for the
+// environment where the values of the variables are obtained from provider
+// during evaluation, allowing values to change between evaluations of a
+// program. The variables and their types are determined by the map returned
+// by provider when DynamicGlobals is called, as described for Globals, and
+// subsequent maps must hold the same set of variables with the same types.
+//
+// The provider is called each time a global variable is resolved during
+// evaluation, so a single evaluation may see values from more than one call.
+// The provider must be safe for concurrent use if programs are evaluated
+// concurrently, and the maps it returns must not be mutated after they have
+// been returned; to update values, the provider should return a new map.
+func DynamicGlobals(provider func() map[string]interface{}) cel.EnvOption {
+	return cel.Lib(dynamicGlobalsLib{provider: provider})
+}
+
+type dynamicGlobalsLib struct {
+	provider func() map[string]interface{}
+}
+
+func (l dynamicGlobalsLib) CompileOptions() []cel.EnvOption {
+	return []cel.EnvOption{cel.Declarations(globalDecls(l.provider())...)}
+}
+
+func (l dynamicGlobalsLib) ProgramOptions() []cel.ProgramOption {
+	return []cel.ProgramOption{
+		cel.Globals(dynamicActivation(l)),
+	}
+}
+
+// dynamicActivation is an interpreter.Activation that resolves names from
+// the map returned by its provider.
+type dynamicActivation dynamicGlobalsLib
+
+func (a dynamicActivation) ResolveName(name string) (interface{}, bool) {
+	v, ok := a.provider()[name]
+	return v, ok
+}
+
+func (dynamicActivation) Parent() interpreter.Activation { return nil }
+
+// globalDecls returns the declarations for the variables in vars.
+func globalDecls(vars map[string]interface{}) []*expr.Decl {
+	globals := make([]*expr.Decl, 0, len(vars))
+	for name, val := range vars {
 		var typ *expr.Type
 		// Do times and []byte first since otherwise duration gets expressed as an
 		// primitive:INT64 and []byte gets expressed as list_type:{elem_type:{dyn:{}}}.
@@ -72,14 +125,7 @@ func (l globalsLib) CompileOptions() []cel.EnvOption {
 		}
 		globals = append(globals, decls.NewVar(name, typ))
 	}
-
-	return []cel.EnvOption{cel.Declarations(globals...)}
-}
-
-func (l globalsLib) ProgramOptions() []cel.ProgramOption {
-	return []cel.ProgramOption{
-		cel.Globals(map[string]interface{}(l)),
-	}
+	return globals
 }
 
 func primativeTypeFor(kind reflect.Kind) (typ *expr.Type, definitive bool) {
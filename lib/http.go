@@ -19,14 +19,32 @@ package lib
 
 import (
 	"bytes"
+	"compress/gzip"
+	"compress/zlib"
 	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	mathrand "math/rand"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"net/http/cookiejar"
+	"net/textproto"
 	"net/url"
+	"os"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/google/cel-go/cel"
 	"github.com/google/cel-go/checker/decls"
@@ -40,12 +58,14 @@ import (
 
 // HTTP returns a cel.EnvOption to configure extended functions for HTTP
 // requests. Requests and responses are returned as maps corresponding to
-// the Go http.Request and http.Response structs. The client and limit parameters
+// the Go http.Request and http.Response structs, with responses also holding
+// the URL of the request that resulted in the response, after following any
+// redirects, in the FinalURL field. The client and limit parameters
 // will be used for the requests and API rate limiting. If client is nil
 // the http.DefaultClient will be used and if limit is nil an non-limiting
 // rate.Limiter will be used. If auth is not nil, the Authorization header
 // is populated for Basic Authentication in requests constructed for direct
-// HEAD, GET and POST method calls. Explicitly constructed requests used in
+// HEAD, GET, POST, PUT and DELETE method calls. Explicitly constructed requests used in
 // do_request are not affected by auth. In cases where Basic Authentication
 // is needed for these constructed requests, the basic_authentication method
 // can be used to add the necessary header.
@@ -70,6 +90,24 @@ import (
 //
 //	get('http://www.example.com/')  // returns {"Body": "PCFkb2N0e...
 //
+// # GET All
+//
+// get_all performs GET method requests starting from the given URL and
+// following RFC 8288 Link header URLs with the "next" relation type, and
+// returns the list of results. Requests stop when a response has no next
+// link, or when the page limit is reached. The page limit defaults to 100
+// and may be set with an optional second parameter. Each request is subject
+// to the rate limit. If a request fails, a map holding the URL and the
+// error message in Error is added to the list in place of the result and
+// no further requests are made:
+//
+//	get_all(<string>) -> <list<map<string,dyn>>>
+//	get_all(<string>, <int>) -> <list<map<string,dyn>>>
+//
+// Example:
+//
+//	get_all('http://www.example.com/items', 10)  // returns [{"Body": "W3siaWQiO...
+//
 // # GET Request
 //
 // get_request returns a GET method request:
@@ -105,6 +143,27 @@ import (
 //
 //	post("http://www.example.com/", "text/plain", "test")  // returns {"Body": "PCFkb2N0e...
 //
+// # PUT
+//
+// put performs a PUT method request and returns the result:
+//
+//	put(<string>, <string>, <bytes>) -> <map<string,dyn>>
+//	put(<string>, <string>, <string>) -> <map<string,dyn>>
+//
+// Example:
+//
+//	put("http://www.example.com/", "text/plain", "test")  // returns {"Body": "PCFkb2N0e...
+//
+// # DELETE
+//
+// delete performs a DELETE method request and returns the result:
+//
+//	delete(<string>) -> <map<string,dyn>>
+//
+// Example:
+//
+//	delete('http://www.example.com/')  // returns {"Body": "PCFkb2N0e...
+//
 // # POST Request
 //
 // post_request returns a POST method request:
@@ -135,6 +194,27 @@ import (
 //	    "URL": "http://www.example.com/"
 //	}
 //
+// # Multipart Request
+//
+// multipart_request returns a POST method request with a multipart/form-data
+// body constructed from a map of field names to values. Values may be strings
+// for simple form fields, or maps describing file parts with a filename and
+// content and an optional content_type, which defaults to
+// application/octet-stream. Parts are written in lexical order of field names:
+//
+//	multipart_request(<string>, <map<string,dyn>>) -> <map<string,dyn>>
+//
+// Example:
+//
+//	multipart_request("http://www.example.com/", {
+//	    "description": "test file",
+//	    "file": {
+//	        "filename": "test.txt",
+//	        "content": b"hello world!",
+//	        "content_type": "text/plain",
+//	    },
+//	}).do_request()
+//
 // # Request
 //
 // request returns a user-defined method request:
@@ -196,6 +276,63 @@ import (
 //	    "URL": "http://www.example.com/"
 //	}
 //
+// # Compress Body
+//
+// compress_body compresses the body of a request using the given content
+// coding, either "gzip" or "deflate", returning the modified request. The
+// Content-Encoding header and ContentLength field are set to match the
+// compressed body:
+//
+//	<map<string,dyn>>.compress_body(<string>) -> <map<string,dyn>>
+//
+// Example:
+//
+//	post_request("http://www.example.com/", "application/json", data.encode_json()).compress_body("gzip").do_request()
+//
+// # Body From File
+//
+// body_from_file sets the body of a request to be the contents of the file
+// at the provided path, returning the modified request. The file is read when
+// the request is executed, so that large bodies are streamed from disk rather
+// than held in memory. Any existing body is removed and the ContentLength
+// field is set to the current size of the file:
+//
+//	<map<string,dyn>>.body_from_file(<string>) -> <map<string,dyn>>
+//	body_from_file(<map<string,dyn>>, <string>) -> <map<string,dyn>>
+//
+// Example:
+//
+//	post_request("http://www.example.com/", "application/octet-stream", "").body_from_file("upload.bin").do_request()
+//
+// # Accept
+//
+// accept sets the Accept header of a request to the provided media type,
+// returning the modified request:
+//
+//	<map<string,dyn>>.accept(<string>) -> <map<string,dyn>>
+//	accept(<map<string,dyn>>, <string>) -> <map<string,dyn>>
+//
+// Example:
+//
+//	get_request("http://www.example.com/").accept("application/json").do_request()
+//
+// # Auto Decode
+//
+// auto_decode decodes the body of a response according to the media type in
+// its Content-Type header. JSON (application/json and +json types), NDJSON
+// (application/x-ndjson), XML (application/xml, text/xml and +xml types) and
+// CSV (text/csv) bodies are supported. JSON is decoded as for decode_json,
+// NDJSON as for the NDJSON file transform, XML as for decode_xml without an
+// XSD, and CSV as for the CSVHeader file transform if the header parameter
+// is "present" and as for the CSVNoHeader file transform otherwise:
+//
+//	<map<string,dyn>>.auto_decode() -> <dyn>
+//	auto_decode(<map<string,dyn>>) -> <dyn>
+//
+// Example:
+//
+//	get_request("http://www.example.com/").accept("application/json").do_request().auto_decode()
+//
 // # Do Request
 //
 // do_request executes an HTTP request:
@@ -206,6 +343,113 @@ import (
 //
 //	get_request("http://www.example.com/").do_request()  // returns {"Body": "PCFkb2N0e...
 //
+// If a second map parameter is provided, the request is retried on failure
+// according to the retry policy described by the map:
+//
+//	<map<string,dyn>>.do_request(<map<string,dyn>>) -> <map<string,dyn>>
+//
+// The policy map may hold the following fields:
+//
+//   - max_attempts: the maximum number of attempts (<int>, default 3)
+//   - initial_backoff: the wait before the first retry, doubling with each
+//     subsequent retry (<duration>, default 1s)
+//   - max_backoff: the maximum wait between attempts (<duration>, default 30s)
+//   - retry_on: the status codes that will be retried (<list<int>>, default
+//     [429, 500, 502, 503, 504])
+//
+// Requests that fail with a connection error are also retried. If a retried
+// response holds a Retry-After header, its value is used as the wait, limited
+// by max_backoff. Attempts are subject to the rate limit, and retrying stops
+// if the context is cancelled. The last response is returned with an Attempts
+// field holding the number of attempts made. If the last attempt failed with
+// an error, the error is returned.
+//
+// Example:
+//
+//	get_request("http://www.example.com/").do_request({
+//	    "max_attempts": 5,
+//	    "initial_backoff": duration("500ms"),
+//	})  // returns {"Attempts": 1, "Body": "PCFkb2N0e...
+//
+// # Cookies
+//
+// cookies returns the cookies held by the client's cookie jar that would be
+// sent in a request to the given URL. Each cookie is represented as a map
+// with Name and Value fields. It is an error to call cookies when the client
+// does not have a cookie jar; see HTTPCookieJar:
+//
+//	cookies(<string>) -> <list<map<string,dyn>>>
+//
+// Example:
+//
+//	cookies("http://www.example.com/")  // returns [{"Name": "session", "Value": "c2Vzc2lvbg"}]
+//
+// # Parse Cookie
+//
+// parse_cookie returns a map holding the details of a Set-Cookie header
+// value corresponding to the Go http.Cookie struct. The Expires field is
+// only present if the cookie has an Expires attribute, and the SameSite
+// field is only present if the cookie has a SameSite attribute, in which
+// case it is one of "Default", "Lax", "Strict" or "None":
+//
+//	parse_cookie(<string>) -> <map<string,dyn>>
+//
+// Example:
+//
+//	parse_cookie("session=c2Vzc2lvbg; Path=/; Expires=Wed, 21 Oct 2015 07:28:00 GMT; HttpOnly; SameSite=Lax")
+//
+//	will return:
+//
+//	{
+//	    "Domain": "",
+//	    "Expires": "2015-10-21T07:28:00Z",
+//	    "HttpOnly": true,
+//	    "MaxAge": 0,
+//	    "Name": "session",
+//	    "Path": "/",
+//	    "SameSite": "Lax",
+//	    "Secure": false,
+//	    "Value": "c2Vzc2lvbg"
+//	}
+//
+// # Format Cookie
+//
+// format_cookie returns the Set-Cookie header value corresponding to the
+// cookie map that is the receiver. The map must have a Name field and may
+// have any of the other fields returned by parse_cookie:
+//
+//	format_cookie(<map<string,dyn>>) -> <string>
+//
+// Example:
+//
+//	format_cookie({"Name": "session", "Value": "c2Vzc2lvbg", "SameSite": "Lax"})
+//
+//	will return:
+//
+//	"session=c2Vzc2lvbg; SameSite=Lax"
+//
+// # Parse Link Header
+//
+// parse_link_header returns a map of relation types to target URLs parsed
+// from an RFC 8288 Link header value or list of values, such as the Link
+// field of a response's Header. Links with multiple relation types are
+// included under each type, and the first link for each relation type is
+// used. Target URLs are returned as they appear in the header:
+//
+//	parse_link_header(<string>) -> <map<string,string>>
+//	parse_link_header(<list<string>>) -> <map<string,string>>
+//
+// Example:
+//
+//	parse_link_header('<https://api.example.com/items?page=3>; rel="next", <https://api.example.com/items?page=1>; rel="prev"')
+//
+//	will return:
+//
+//	{
+//	    "next": "https://api.example.com/items?page=3",
+//	    "prev": "https://api.example.com/items?page=1"
+//	}
+//
 // # Parse URL
 //
 // parse_url returns a map holding the details of the parsed URL corresponding
@@ -277,6 +521,37 @@ import (
 //	will return:
 //
 //	line=25&page=2"
+//
+// # URL Join
+//
+// url_join returns the URL resolved from a reference relative to a base URL
+// as described in RFC 3986. Absolute references replace the base URL, and
+// the fragment of the reference is retained:
+//
+//	url_join(<string>, <string>) -> <string>
+//	<string>.url_join(<string>) -> <string>
+//
+// Examples:
+//
+//	url_join("https://api.example.com/v1/", "../v2/users?active=true")  // return "https://api.example.com/v2/users?active=true"
+//	url_join("https://api.example.com/v1/", "users#top")                // return "https://api.example.com/v1/users#top"
+//
+// # Set Query
+//
+// set_query returns the URL with the query parameters in the map added to
+// its query, replacing any existing values for the same parameter names.
+// Other query parameters and the fragment are retained:
+//
+//	set_query(<string>, <map<string,<list<string>>>>) -> <string>
+//	<string>.set_query(<map<string,<list<string>>>>) -> <string>
+//
+// Example:
+//
+//	set_query("https://api.example.com/v1/users?page=1&limit=10", {"page": ["2"]})
+//
+//	will return:
+//
+//	"https://api.example.com/v1/users?limit=10&page=2"
 func HTTP(client *http.Client, limit *rate.Limiter, auth *BasicAuth) cel.EnvOption {
 	return HTTPWithContext(context.Background(), client, limit, auth)
 }
@@ -284,25 +559,203 @@ func HTTP(client *http.Client, limit *rate.Limiter, auth *BasicAuth) cel.EnvOpti
 // HTTPWithContext returns a cel.EnvOption to configure extended functions
 // for HTTP requests that include a context.Context in network requests.
 func HTTPWithContext(ctx context.Context, client *http.Client, limit *rate.Limiter, auth *BasicAuth) cel.EnvOption {
+	return HTTPWithOptions(ctx, client, limit, auth)
+}
+
+// HTTPWithOptions returns a cel.EnvOption to configure extended functions
+// for HTTP requests as described for HTTPWithContext, with additional
+// behaviour configured by opts. Options are applied in order. Options that
+// alter the client act on a copy, so the client is not mutated.
+func HTTPWithOptions(ctx context.Context, client *http.Client, limit *rate.Limiter, auth *BasicAuth, opts ...HTTPOption) cel.EnvOption {
 	if client == nil {
 		client = http.DefaultClient
 	}
 	if limit == nil {
 		limit = rate.NewLimiter(rate.Inf, 0)
 	}
-	return cel.Lib(httpLib{
+	l := httpLib{
 		client: client,
 		limit:  limit,
 		auth:   auth,
 		ctx:    ctx,
-	})
+	}
+	if len(opts) != 0 {
+		c := *client
+		l.client = &c
+		for _, opt := range opts {
+			opt(&l)
+		}
+	}
+	return cel.Lib(l)
+}
+
+// HTTPOption is an option for the HTTP lib constructed by HTTPWithOptions.
+type HTTPOption func(*httpLib)
+
+// HTTPMetrics returns an HTTPOption where responses from get, head, post,
+// put, delete and do_request include timing metrics. The time the request
+// was sent is added to the response map as a timestamp in the StartedAt
+// field, and the round-trip duration of the request, excluding the reading
+// of the response body, is added as a duration in the Duration field.
+func HTTPMetrics() HTTPOption {
+	return func(l *httpLib) {
+		l.metrics = true
+	}
+}
+
+// HTTPDigestAuth returns an HTTPOption where requests made by the client
+// respond to HTTP Digest Authentication challenges using the credentials in
+// auth. When a request receives a 401 Unauthorized response with a Digest
+// WWW-Authenticate challenge, the request is re-issued with the computed
+// Authorization header. The challenge is retained so that subsequent
+// requests are authenticated pre-emptively with an incremented nonce count.
+// This applies to direct method calls and to requests made with do_request.
+// If auth is nil, the option has no effect.
+func HTTPDigestAuth(auth *DigestAuth) HTTPOption {
+	return func(l *httpLib) {
+		if auth == nil {
+			return
+		}
+		l.client.Transport = &digestTransport{auth: *auth, transport: transportOf(l.client)}
+	}
+}
+
+// HTTPIdempotencyKeys returns an HTTPOption where POST, PUT and PATCH
+// requests made by the client are sent with an Idempotency-Key header if
+// they do not already have one. The key is the hex encoded SHA-256
+// fingerprint of the request's method, URL and body, so retries of the same
+// logical request, including retries made by do_request, are sent with the
+// same key.
+func HTTPIdempotencyKeys() HTTPOption {
+	return func(l *httpLib) {
+		l.client.Transport = idempotencyTransport{transport: transportOf(l.client)}
+	}
+}
+
+// HTTPCookieJar returns an HTTPOption where the client uses the provided
+// cookie jar. If jar is nil, a new in-memory jar is used. Cookies set by
+// responses are stored in the jar and sent on subsequent requests to
+// matching URLs, including requests made with do_request.
+func HTTPCookieJar(jar http.CookieJar) HTTPOption {
+	return func(l *httpLib) {
+		if jar == nil {
+			// cookiejar.New never returns a non-nil error.
+			jar, _ = cookiejar.New(nil)
+		}
+		l.client.Jar = jar
+	}
+}
+
+// HTTPRedirectLimit returns an HTTPOption where the client follows at most
+// max redirects for each request. When the limit is reached, the redirect
+// response is returned rather than being followed. If max is zero, redirects
+// are not followed.
+func HTTPRedirectLimit(max int) HTTPOption {
+	return func(l *httpLib) {
+		l.client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			if len(via) > max {
+				return http.ErrUseLastResponse
+			}
+			return nil
+		}
+	}
+}
+
+// HTTPJitter returns an HTTPOption where each request made by get, head,
+// post, put, delete and do_request is delayed by a random duration in the
+// range [0, max] after waiting for the rate limit. The delay is interrupted
+// if the lib's context is cancelled. If src is nil, a time-seeded source is
+// used for the random durations. If max is not positive, the option has no
+// effect.
+func HTTPJitter(max time.Duration, src mathrand.Source) HTTPOption {
+	return func(l *httpLib) {
+		if max <= 0 {
+			return
+		}
+		if src == nil {
+			src = mathrand.NewSource(time.Now().UnixNano())
+		}
+		l.jitter = &jitter{max: max, rnd: mathrand.New(src)}
+	}
+}
+
+// HTTPResponseHeaders returns an HTTPOption where the Header field of
+// response maps only holds the headers named in headers. Header names are
+// matched case-insensitively. All other response headers are dropped,
+// reducing the size of rendered responses. If headers is nil, all headers
+// are retained.
+func HTTPResponseHeaders(headers []string) HTTPOption {
+	return func(l *httpLib) {
+		if headers == nil {
+			l.respHeaders = nil
+			return
+		}
+		l.respHeaders = make(map[string]bool, len(headers))
+		for _, h := range headers {
+			l.respHeaders[textproto.CanonicalMIMEHeaderKey(h)] = true
+		}
+	}
+}
+
+// transportOf returns the transport used by client.
+func transportOf(client *http.Client) http.RoundTripper {
+	if client.Transport == nil {
+		return http.DefaultTransport
+	}
+	return client.Transport
 }
 
 type httpLib struct {
-	client *http.Client
-	limit  *rate.Limiter
-	auth   *BasicAuth
-	ctx    context.Context
+	client  *http.Client
+	limit   *rate.Limiter
+	auth    *BasicAuth
+	ctx     context.Context
+	metrics bool
+	jitter  *jitter
+
+	respHeaders map[string]bool // Nil retains all response headers.
+}
+
+// wait blocks until the rate limit allows a request and then for any
+// configured jitter.
+func (l httpLib) wait(ctx context.Context) error {
+	err := l.limit.Wait(ctx)
+	if err != nil || l.jitter == nil {
+		return err
+	}
+	return l.jitter.wait(l.ctx)
+}
+
+// jitter is a source of random request delays.
+type jitter struct {
+	max time.Duration
+
+	mu  sync.Mutex
+	rnd *mathrand.Rand
+}
+
+// wait blocks for a random duration in [0, j.max] or until ctx is cancelled.
+func (j *jitter) wait(ctx context.Context) error {
+	j.mu.Lock()
+	d := time.Duration(j.rnd.Int63n(int64(j.max) + 1))
+	j.mu.Unlock()
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// start returns the start time for a request if metrics are being
+// collected and the zero time otherwise.
+func (l httpLib) start() time.Time {
+	if !l.metrics {
+		return time.Time{}
+	}
+	return time.Now()
 }
 
 // BasicAuth is used to populate the Authorization header to use HTTP
@@ -312,6 +765,332 @@ type BasicAuth struct {
 	Username, Password string
 }
 
+// DigestAuth is used to respond to HTTP Digest Authentication challenges
+// with the provided username and password.
+type DigestAuth struct {
+	Username, Password string
+}
+
+// digestTransport is an http.RoundTripper that responds to HTTP Digest
+// Authentication challenges as described in RFC 7616.
+type digestTransport struct {
+	auth      DigestAuth
+	transport http.RoundTripper
+
+	mu        sync.Mutex
+	challenge *digestChallenge
+	count     int
+}
+
+func (t *digestTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		// Buffer the body so that it can be re-sent in response to a challenge.
+		b, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("digest auth: %w", err)
+		}
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(b))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(b)), nil
+		}
+	}
+	t.mu.Lock()
+	c := t.challenge
+	t.mu.Unlock()
+	if c != nil {
+		// Authenticate pre-emptively with the last challenge.
+		r, err := t.authenticate(req, c)
+		if err != nil {
+			return nil, err
+		}
+		if req.Body != nil {
+			// The clone holds a fresh copy of the body.
+			req.Body.Close()
+		}
+		req = r
+	}
+	resp, err := t.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	for stale := false; ; {
+		if resp.StatusCode != http.StatusUnauthorized {
+			return resp, nil
+		}
+		c, ok := parseDigestChallenge(resp.Header.Values("Www-Authenticate"))
+		if !ok {
+			return resp, nil
+		}
+		if req.Header.Get("Authorization") != "" && !c.stale {
+			// Our credentials were rejected.
+			return resp, nil
+		}
+		if c.stale {
+			if stale {
+				resp.Body.Close()
+				return nil, errors.New("digest auth: repeated stale nonce challenge")
+			}
+			stale = true
+		}
+		t.mu.Lock()
+		t.challenge = c
+		t.count = 0
+		t.mu.Unlock()
+		r, err := t.authenticate(req, c)
+		if err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		req = r
+		resp, err = t.transport.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// idempotencyTransport is an http.RoundTripper that adds an Idempotency-Key
+// header derived from the request to non-idempotent requests.
+type idempotencyTransport struct {
+	transport http.RoundTripper
+}
+
+func (t idempotencyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	switch req.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+	default:
+		return t.transport.RoundTrip(req)
+	}
+	if req.Header.Get("Idempotency-Key") != "" {
+		return t.transport.RoundTrip(req)
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n", req.Method, req.URL)
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody != nil {
+			// Hash a fresh copy of the body so that the
+			// original can be streamed to the server.
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("idempotency key: %w", err)
+			}
+			_, err = io.Copy(h, body)
+			body.Close()
+			if err != nil {
+				return nil, fmt.Errorf("idempotency key: %w", err)
+			}
+		} else {
+			b, err := io.ReadAll(req.Body)
+			req.Body.Close()
+			if err != nil {
+				return nil, fmt.Errorf("idempotency key: %w", err)
+			}
+			h.Write(b)
+			req = req.Clone(req.Context())
+			req.Body = io.NopCloser(bytes.NewReader(b))
+			req.GetBody = func() (io.ReadCloser, error) {
+				return io.NopCloser(bytes.NewReader(b)), nil
+			}
+		}
+	}
+	r := req.Clone(req.Context())
+	r.Header.Set("Idempotency-Key", hex.EncodeToString(h.Sum(nil)))
+	return t.transport.RoundTrip(r)
+}
+
+// authenticate returns a clone of req with an Authorization header computed
+// from the challenge c and the next nonce count.
+func (t *digestTransport) authenticate(req *http.Request, c *digestChallenge) (*http.Request, error) {
+	r := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("digest auth: %w", err)
+		}
+		r.Body = body
+	}
+	t.mu.Lock()
+	t.count++
+	nc := t.count
+	t.mu.Unlock()
+	cnonce := make([]byte, 16)
+	_, err := rand.Read(cnonce)
+	if err != nil {
+		return nil, fmt.Errorf("digest auth: %w", err)
+	}
+	auth, err := digestResponse(c, t.auth, r.Method, r.URL.RequestURI(), nc, hex.EncodeToString(cnonce))
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Set("Authorization", auth)
+	return r, nil
+}
+
+// digestChallenge is the set of parameters in a Digest WWW-Authenticate
+// challenge.
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       string
+	userhash  bool
+	stale     bool
+}
+
+// parseDigestChallenge returns the first Digest challenge in the provided
+// WWW-Authenticate header values.
+func parseDigestChallenge(headers []string) (*digestChallenge, bool) {
+	for _, h := range headers {
+		scheme, params, ok := strings.Cut(strings.TrimSpace(h), " ")
+		if !ok || !strings.EqualFold(scheme, "Digest") {
+			continue
+		}
+		c := digestChallenge{algorithm: "MD5"}
+		for k, v := range parseAuthParams(params) {
+			switch strings.ToLower(k) {
+			case "realm":
+				c.realm = v
+			case "nonce":
+				c.nonce = v
+			case "opaque":
+				c.opaque = v
+			case "algorithm":
+				c.algorithm = v
+			case "qop":
+				c.qop = v
+			case "userhash":
+				c.userhash = strings.EqualFold(v, "true")
+			case "stale":
+				c.stale = strings.EqualFold(v, "true")
+			}
+		}
+		if c.nonce == "" {
+			continue
+		}
+		return &c, true
+	}
+	return nil, false
+}
+
+// parseAuthParams parses a comma-separated list of auth-param key=value
+// pairs where values may be quoted strings.
+func parseAuthParams(s string) map[string]string {
+	params := make(map[string]string)
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		if s == "" {
+			return params
+		}
+		k, rest, ok := strings.Cut(s, "=")
+		if !ok {
+			return params
+		}
+		k = strings.TrimSpace(k)
+		rest = strings.TrimLeft(rest, " \t")
+		var v string
+		if strings.HasPrefix(rest, `"`) {
+			v, rest = cutQuoted(rest)
+		} else {
+			end := strings.IndexByte(rest, ',')
+			if end < 0 {
+				end = len(rest)
+			}
+			v = strings.TrimSpace(rest[:end])
+			rest = rest[end:]
+		}
+		params[k] = v
+		s = rest
+	}
+}
+
+// cutQuoted returns the unescaped content of the HTTP quoted-string at the
+// start of s and the remainder of s following the closing quote.
+func cutQuoted(s string) (val, rest string) {
+	var v strings.Builder
+	s = s[1:]
+	for len(s) != 0 && s[0] != '"' {
+		if s[0] == '\\' && len(s) > 1 {
+			s = s[1:]
+		}
+		v.WriteByte(s[0])
+		s = s[1:]
+	}
+	if len(s) != 0 {
+		s = s[1:]
+	}
+	return v.String(), s
+}
+
+// digestResponse returns the Authorization header value for a response to
+// the challenge c.
+func digestResponse(c *digestChallenge, auth DigestAuth, method, uri string, nc int, cnonce string) (string, error) {
+	alg := strings.ToUpper(c.algorithm)
+	var h func(string) string
+	switch strings.TrimSuffix(alg, "-SESS") {
+	case "MD5":
+		h = func(s string) string {
+			sum := md5.Sum([]byte(s))
+			return hex.EncodeToString(sum[:])
+		}
+	case "SHA-256":
+		h = func(s string) string {
+			sum := sha256.Sum256([]byte(s))
+			return hex.EncodeToString(sum[:])
+		}
+	default:
+		return "", fmt.Errorf("digest auth: unsupported algorithm: %s", c.algorithm)
+	}
+
+	var qop string
+	if c.qop != "" {
+		for _, q := range strings.Split(c.qop, ",") {
+			if strings.TrimSpace(q) == "auth" {
+				qop = "auth"
+				break
+			}
+		}
+		if qop == "" {
+			return "", fmt.Errorf("digest auth: unsupported qop: %s", c.qop)
+		}
+	}
+
+	ha1 := h(auth.Username + ":" + c.realm + ":" + auth.Password)
+	if strings.HasSuffix(alg, "-SESS") {
+		ha1 = h(ha1 + ":" + c.nonce + ":" + cnonce)
+	}
+	ha2 := h(method + ":" + uri)
+	ncv := fmt.Sprintf("%08x", nc)
+	var response string
+	if qop == "" {
+		response = h(ha1 + ":" + c.nonce + ":" + ha2)
+	} else {
+		response = h(ha1 + ":" + c.nonce + ":" + ncv + ":" + cnonce + ":" + qop + ":" + ha2)
+	}
+
+	username := auth.Username
+	if c.userhash {
+		username = h(auth.Username + ":" + c.realm)
+	}
+	var buf strings.Builder
+	fmt.Fprintf(&buf, `Digest username=%q, realm=%q, nonce=%q, uri=%q, algorithm=%s, response=%q`,
+		username, c.realm, c.nonce, uri, c.algorithm, response)
+	if c.opaque != "" {
+		fmt.Fprintf(&buf, `, opaque=%q`, c.opaque)
+	}
+	if qop != "" {
+		fmt.Fprintf(&buf, `, qop=%s, nc=%s, cnonce=%q`, qop, ncv, cnonce)
+	}
+	if c.userhash {
+		buf.WriteString(`, userhash=true`)
+	}
+	return buf.String(), nil
+}
+
 func (httpLib) CompileOptions() []cel.EnvOption {
 	return []cel.EnvOption{
 		cel.Declarations(
@@ -329,6 +1108,18 @@ func (httpLib) CompileOptions() []cel.EnvOption {
 					decls.NewMapType(decls.String, decls.Dyn),
 				),
 			),
+			decls.NewFunction("get_all",
+				decls.NewOverload(
+					"get_all_string",
+					[]*expr.Type{decls.String},
+					decls.NewListType(decls.NewMapType(decls.String, decls.Dyn)),
+				),
+				decls.NewOverload(
+					"get_all_string_int",
+					[]*expr.Type{decls.String, decls.Int},
+					decls.NewListType(decls.NewMapType(decls.String, decls.Dyn)),
+				),
+			),
 			decls.NewFunction("get_request",
 				decls.NewOverload(
 					"get_request_string",
@@ -348,6 +1139,25 @@ func (httpLib) CompileOptions() []cel.EnvOption {
 					decls.NewMapType(decls.String, decls.Dyn),
 				),
 			),
+			decls.NewFunction("put",
+				decls.NewOverload(
+					"put_string_string_bytes",
+					[]*expr.Type{decls.String, decls.String, decls.Bytes},
+					decls.NewMapType(decls.String, decls.Dyn),
+				),
+				decls.NewOverload(
+					"put_string_string_string",
+					[]*expr.Type{decls.String, decls.String, decls.String},
+					decls.NewMapType(decls.String, decls.Dyn),
+				),
+			),
+			decls.NewFunction("delete",
+				decls.NewOverload(
+					"delete_string",
+					[]*expr.Type{decls.String},
+					decls.NewMapType(decls.String, decls.Dyn),
+				),
+			),
 			decls.NewFunction("post_request",
 				decls.NewOverload(
 					"post_request_string_string_bytes",
@@ -360,6 +1170,13 @@ func (httpLib) CompileOptions() []cel.EnvOption {
 					decls.NewMapType(decls.String, decls.Dyn),
 				),
 			),
+			decls.NewFunction("multipart_request",
+				decls.NewOverload(
+					"multipart_request_string_map",
+					[]*expr.Type{decls.String, decls.NewMapType(decls.String, decls.Dyn)},
+					decls.NewMapType(decls.String, decls.Dyn),
+				),
+			),
 			decls.NewFunction("request",
 				decls.NewOverload(
 					"request_string_string",
@@ -384,12 +1201,93 @@ func (httpLib) CompileOptions() []cel.EnvOption {
 					decls.NewMapType(decls.String, decls.Dyn),
 				),
 			),
+			decls.NewFunction("compress_body",
+				decls.NewInstanceOverload(
+					"map_compress_body_string",
+					[]*expr.Type{decls.NewMapType(decls.String, decls.Dyn), decls.String},
+					decls.NewMapType(decls.String, decls.Dyn),
+				),
+			),
+			decls.NewFunction("body_from_file",
+				decls.NewInstanceOverload(
+					"map_body_from_file_string",
+					[]*expr.Type{decls.NewMapType(decls.String, decls.Dyn), decls.String},
+					decls.NewMapType(decls.String, decls.Dyn),
+				),
+				decls.NewOverload(
+					"body_from_file_map_string",
+					[]*expr.Type{decls.NewMapType(decls.String, decls.Dyn), decls.String},
+					decls.NewMapType(decls.String, decls.Dyn),
+				),
+			),
+			decls.NewFunction("accept",
+				decls.NewInstanceOverload(
+					"map_accept_string",
+					[]*expr.Type{decls.NewMapType(decls.String, decls.Dyn), decls.String},
+					decls.NewMapType(decls.String, decls.Dyn),
+				),
+				decls.NewOverload(
+					"accept_map_string",
+					[]*expr.Type{decls.NewMapType(decls.String, decls.Dyn), decls.String},
+					decls.NewMapType(decls.String, decls.Dyn),
+				),
+			),
+			decls.NewFunction("auto_decode",
+				decls.NewInstanceOverload(
+					"map_auto_decode",
+					[]*expr.Type{decls.NewMapType(decls.String, decls.Dyn)},
+					decls.Dyn,
+				),
+				decls.NewOverload(
+					"auto_decode_map",
+					[]*expr.Type{decls.NewMapType(decls.String, decls.Dyn)},
+					decls.Dyn,
+				),
+			),
 			decls.NewFunction("do_request",
 				decls.NewInstanceOverload(
 					"map_do_request",
 					[]*expr.Type{decls.NewMapType(decls.String, decls.Dyn)},
 					decls.NewMapType(decls.String, decls.Dyn),
 				),
+				decls.NewInstanceOverload(
+					"map_do_request_map",
+					[]*expr.Type{decls.NewMapType(decls.String, decls.Dyn), decls.NewMapType(decls.String, decls.Dyn)},
+					decls.NewMapType(decls.String, decls.Dyn),
+				),
+			),
+			decls.NewFunction("cookies",
+				decls.NewOverload(
+					"cookies_string",
+					[]*expr.Type{decls.String},
+					decls.NewListType(decls.NewMapType(decls.String, decls.Dyn)),
+				),
+			),
+			decls.NewFunction("parse_cookie",
+				decls.NewOverload(
+					"parse_cookie_string",
+					[]*expr.Type{decls.String},
+					decls.NewMapType(decls.String, decls.Dyn),
+				),
+			),
+			decls.NewFunction("format_cookie",
+				decls.NewOverload(
+					"format_cookie_map",
+					[]*expr.Type{decls.NewMapType(decls.String, decls.Dyn)},
+					decls.String,
+				),
+			),
+			decls.NewFunction("parse_link_header",
+				decls.NewOverload(
+					"parse_link_header_string",
+					[]*expr.Type{decls.String},
+					decls.NewMapType(decls.String, decls.String),
+				),
+				decls.NewOverload(
+					"parse_link_header_list_string",
+					[]*expr.Type{decls.NewListType(decls.String)},
+					decls.NewMapType(decls.String, decls.String),
+				),
 			),
 			decls.NewFunction("parse_url",
 				decls.NewInstanceOverload(
@@ -419,6 +1317,30 @@ func (httpLib) CompileOptions() []cel.EnvOption {
 					decls.String,
 				),
 			),
+			decls.NewFunction("url_join",
+				decls.NewOverload(
+					"url_join_string_string",
+					[]*expr.Type{decls.String, decls.String},
+					decls.String,
+				),
+				decls.NewInstanceOverload(
+					"string_url_join_string",
+					[]*expr.Type{decls.String, decls.String},
+					decls.String,
+				),
+			),
+			decls.NewFunction("set_query",
+				decls.NewOverload(
+					"set_query_string_map",
+					[]*expr.Type{decls.String, decls.NewMapType(decls.String, decls.NewListType(decls.String))},
+					decls.String,
+				),
+				decls.NewInstanceOverload(
+					"string_set_query_map",
+					[]*expr.Type{decls.String, decls.NewMapType(decls.String, decls.NewListType(decls.String))},
+					decls.String,
+				),
+			),
 		),
 	}
 }
@@ -437,6 +1359,16 @@ func (l httpLib) ProgramOptions() []cel.ProgramOption {
 				Unary:    l.doGet,
 			},
 		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "get_all_string",
+				Function: l.doGetAll,
+			},
+			&functions.Overload{
+				Operator: "get_all_string_int",
+				Function: l.doGetAll,
+			},
+		),
 		cel.Functions(
 			&functions.Overload{
 				Operator: "get_request_string",
@@ -453,6 +1385,22 @@ func (l httpLib) ProgramOptions() []cel.ProgramOption {
 				Function: l.doPost,
 			},
 		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "put_string_string_bytes",
+				Function: l.doPut,
+			},
+			&functions.Overload{
+				Operator: "put_string_string_string",
+				Function: l.doPut,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "delete_string",
+				Unary:    l.doDelete,
+			},
+		),
 		cel.Functions(
 			&functions.Overload{
 				Operator: "post_request_string_string_bytes",
@@ -463,6 +1411,12 @@ func (l httpLib) ProgramOptions() []cel.ProgramOption {
 				Function: newPostRequest,
 			},
 		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "multipart_request_string_map",
+				Binary:   newMultipartRequest,
+			},
+		),
 		cel.Functions(
 			&functions.Overload{
 				Operator: "request_string_string",
@@ -483,11 +1437,79 @@ func (l httpLib) ProgramOptions() []cel.ProgramOption {
 				Function: l.basicAuthentication,
 			},
 		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "map_compress_body_string",
+				Binary:   compressBody,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "map_body_from_file_string",
+				Binary:   bodyFromFile,
+			},
+			&functions.Overload{
+				Operator: "body_from_file_map_string",
+				Binary:   bodyFromFile,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "map_accept_string",
+				Binary:   accept,
+			},
+			&functions.Overload{
+				Operator: "accept_map_string",
+				Binary:   accept,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "map_auto_decode",
+				Unary:    autoDecode,
+			},
+			&functions.Overload{
+				Operator: "auto_decode_map",
+				Unary:    autoDecode,
+			},
+		),
 		cel.Functions(
 			&functions.Overload{
 				Operator: "map_do_request",
 				Unary:    l.doRequest,
 			},
+			&functions.Overload{
+				Operator: "map_do_request_map",
+				Binary:   l.doRequestWithRetry,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "cookies_string",
+				Unary:    l.cookies,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "parse_cookie_string",
+				Unary:    parseCookie,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "format_cookie_map",
+				Unary:    formatCookie,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "parse_link_header_string",
+				Unary:    parseLinks,
+			},
+			&functions.Overload{
+				Operator: "parse_link_header_list_string",
+				Unary:    parseLinks,
+			},
 		),
 		cel.Functions(
 			&functions.Overload{
@@ -513,6 +1535,26 @@ func (l httpLib) ProgramOptions() []cel.ProgramOption {
 				Unary:    formatQuery,
 			},
 		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "url_join_string_string",
+				Binary:   urlJoin,
+			},
+			&functions.Overload{
+				Operator: "string_url_join_string",
+				Binary:   urlJoin,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "set_query_string_map",
+				Binary:   setQuery,
+			},
+			&functions.Overload{
+				Operator: "string_set_query_map",
+				Binary:   setQuery,
+			},
+		),
 	}
 }
 
@@ -521,15 +1563,16 @@ func (l httpLib) doHead(arg ref.Val) ref.Val {
 	if !ok {
 		return types.ValOrErr(url, "no such overload for head")
 	}
-	err := l.limit.Wait(context.TODO())
+	err := l.wait(context.TODO())
 	if err != nil {
 		return types.NewErr("%s", err)
 	}
+	start := l.start()
 	resp, err := l.head(url)
 	if err != nil {
 		return types.NewErr("%s", err)
 	}
-	rm, err := respToMap(resp)
+	rm, err := l.respToMap(resp, start)
 	if err != nil {
 		return types.NewErr("%s", err)
 	}
@@ -552,15 +1595,16 @@ func (l httpLib) doGet(arg ref.Val) ref.Val {
 	if !ok {
 		return types.ValOrErr(url, "no such overload for get")
 	}
-	err := l.limit.Wait(context.TODO())
+	err := l.wait(context.TODO())
 	if err != nil {
 		return types.NewErr("%s", err)
 	}
+	start := l.start()
 	resp, err := l.get(url)
 	if err != nil {
 		return types.NewErr("%s", err)
 	}
-	rm, err := respToMap(resp)
+	rm, err := l.respToMap(resp, start)
 	if err != nil {
 		return types.NewErr("%s", err)
 	}
@@ -578,6 +1622,169 @@ func (l httpLib) get(url types.String) (*http.Response, error) {
 	return l.client.Do(req)
 }
 
+// defaultPageLimit is the default maximum number of pages requested by
+// get_all.
+const defaultPageLimit = 100
+
+func (l httpLib) doGetAll(args ...ref.Val) ref.Val {
+	if len(args) != 1 && len(args) != 2 {
+		return types.NewErr("no such overload for get_all")
+	}
+	next, ok := args[0].(types.String)
+	if !ok {
+		return types.ValOrErr(next, "no such overload for get_all")
+	}
+	limit := types.Int(defaultPageLimit)
+	if len(args) == 2 {
+		limit, ok = args[1].(types.Int)
+		if !ok {
+			return types.ValOrErr(limit, "no such overload for get_all")
+		}
+	}
+	var pages []ref.Val
+	for n := types.Int(0); next != "" && n < limit; n++ {
+		rm, link, err := l.getPage(next)
+		if err != nil {
+			pages = append(pages, types.DefaultTypeAdapter.NativeToValue(map[string]interface{}{
+				"URL":   string(next),
+				"Error": err.Error(),
+			}))
+			break
+		}
+		pages = append(pages, types.DefaultTypeAdapter.NativeToValue(rm))
+		next = link
+	}
+	return types.NewRefValList(types.DefaultTypeAdapter, pages)
+}
+
+// getPage performs a GET request for url and returns the response as a map
+// and the URL of the next page, if the response has one.
+func (l httpLib) getPage(url types.String) (map[string]interface{}, types.String, error) {
+	err := l.wait(l.ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	start := l.start()
+	resp, err := l.get(url)
+	if err != nil {
+		return nil, "", err
+	}
+	var next types.String
+	for _, link := range parseLinkHeader(resp.Header.Values("Link")) {
+		if !hasRel(link.params["rel"], "next") {
+			continue
+		}
+		u, err := resp.Request.URL.Parse(link.target)
+		if err != nil {
+			resp.Body.Close()
+			return nil, "", err
+		}
+		next = types.String(u.String())
+		break
+	}
+	rm, err := l.respToMap(resp, start)
+	if err != nil {
+		return nil, "", err
+	}
+	return rm, next, nil
+}
+
+// linkValue is an RFC 8288 link.
+type linkValue struct {
+	target string
+	params map[string]string
+}
+
+// parseLinkHeader returns the links in the provided Link header values.
+// Parameter names are lower-cased and only the first occurrence of each
+// parameter is retained.
+func parseLinkHeader(headers []string) []linkValue {
+	var links []linkValue
+	for _, h := range headers {
+		for {
+			h = strings.TrimLeft(h, " \t,")
+			if !strings.HasPrefix(h, "<") {
+				break
+			}
+			end := strings.IndexByte(h, '>')
+			if end < 0 {
+				break
+			}
+			link := linkValue{target: h[1:end], params: make(map[string]string)}
+			h = h[end+1:]
+			for {
+				h = strings.TrimLeft(h, " \t")
+				if !strings.HasPrefix(h, ";") {
+					break
+				}
+				h = strings.TrimLeft(h[1:], " \t")
+				end := strings.IndexAny(h, "=;,")
+				if end < 0 {
+					end = len(h)
+				}
+				k := strings.ToLower(strings.TrimSpace(h[:end]))
+				h = h[end:]
+				var v string
+				if strings.HasPrefix(h, "=") {
+					h = strings.TrimLeft(h[1:], " \t")
+					if strings.HasPrefix(h, `"`) {
+						v, h = cutQuoted(h)
+					} else {
+						end := strings.IndexAny(h, ";,")
+						if end < 0 {
+							end = len(h)
+						}
+						v = strings.TrimSpace(h[:end])
+						h = h[end:]
+					}
+				}
+				if _, ok := link.params[k]; !ok {
+					link.params[k] = v
+				}
+			}
+			links = append(links, link)
+		}
+	}
+	return links
+}
+
+func parseLinks(arg ref.Val) ref.Val {
+	var headers []string
+	switch arg := arg.(type) {
+	case types.String:
+		headers = []string{string(arg)}
+	case traits.Lister:
+		h, err := arg.ConvertToNative(reflectStringSliceType)
+		if err != nil {
+			return types.NewErr("parse_link_header: %v", err)
+		}
+		headers = h.([]string)
+	default:
+		return types.ValOrErr(arg, "no such overload for parse_link_header")
+	}
+	rels := make(map[string]string)
+	for _, link := range parseLinkHeader(headers) {
+		for _, r := range strings.Fields(link.params["rel"]) {
+			r = strings.ToLower(r)
+			if _, ok := rels[r]; !ok {
+				rels[r] = link.target
+			}
+		}
+	}
+	return types.DefaultTypeAdapter.NativeToValue(rels)
+}
+
+// hasRel returns whether the space-separated relation types in rels
+// include rel.
+func hasRel(rels, rel string) bool {
+	for _, r := range strings.Fields(rels) {
+		if strings.EqualFold(r, rel) {
+			return true
+		}
+	}
+	return false
+}
+
 func newGetRequest(url ref.Val) ref.Val {
 	return newRequestBody(types.String("GET"), url)
 }
@@ -607,15 +1814,16 @@ func (l httpLib) doPost(args ...ref.Val) ref.Val {
 	default:
 		return types.NewErr("invalid type for post body: %s", text.Type())
 	}
-	err := l.limit.Wait(context.TODO())
+	err := l.wait(context.TODO())
 	if err != nil {
 		return types.NewErr("%s", err)
 	}
+	start := l.start()
 	resp, err := l.post(url, content, body)
 	if err != nil {
 		return types.NewErr("%s", err)
 	}
-	rm, err := respToMap(resp)
+	rm, err := l.respToMap(resp, start)
 	if err != nil {
 		return types.NewErr("%s", err)
 	}
@@ -634,27 +1842,190 @@ func (l httpLib) post(url, content types.String, body io.Reader) (*http.Response
 	return l.client.Do(req)
 }
 
+func (l httpLib) doPut(args ...ref.Val) ref.Val {
+	if len(args) != 3 {
+		return types.NewErr("no such overload for put")
+	}
+	url, ok := args[0].(types.String)
+	if !ok {
+		return types.ValOrErr(url, "no such overload for request")
+	}
+	content, ok := args[1].(types.String)
+	if !ok {
+		return types.ValOrErr(content, "no such overload for request")
+	}
+	var body io.Reader
+	switch text := args[2].(type) {
+	case types.Bytes:
+		if len(text) != 0 {
+			body = bytes.NewReader(text)
+		}
+	case types.String:
+		if text != "" {
+			body = strings.NewReader(string(text))
+		}
+	default:
+		return types.NewErr("invalid type for put body: %s", text.Type())
+	}
+	err := l.wait(context.TODO())
+	if err != nil {
+		return types.NewErr("%s", err)
+	}
+	start := l.start()
+	resp, err := l.put(url, content, body)
+	if err != nil {
+		return types.NewErr("%s", err)
+	}
+	rm, err := l.respToMap(resp, start)
+	if err != nil {
+		return types.NewErr("%s", err)
+	}
+	return types.DefaultTypeAdapter.NativeToValue(rm)
+}
+
+func (l httpLib) put(url, content types.String, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(l.ctx, http.MethodPut, string(url), body)
+	if err != nil {
+		return nil, err
+	}
+	if l.auth != nil {
+		req.SetBasicAuth(l.auth.Username, l.auth.Password)
+	}
+	req.Header.Set("Content-Type", string(content))
+	return l.client.Do(req)
+}
+
+func (l httpLib) doDelete(arg ref.Val) ref.Val {
+	url, ok := arg.(types.String)
+	if !ok {
+		return types.ValOrErr(url, "no such overload for delete")
+	}
+	err := l.wait(context.TODO())
+	if err != nil {
+		return types.NewErr("%s", err)
+	}
+	start := l.start()
+	resp, err := l.delete(url)
+	if err != nil {
+		return types.NewErr("%s", err)
+	}
+	rm, err := l.respToMap(resp, start)
+	if err != nil {
+		return types.NewErr("%s", err)
+	}
+	return types.DefaultTypeAdapter.NativeToValue(rm)
+}
+
+func (l httpLib) delete(url types.String) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(l.ctx, http.MethodDelete, string(url), nil)
+	if err != nil {
+		return nil, err
+	}
+	if l.auth != nil {
+		req.SetBasicAuth(l.auth.Username, l.auth.Password)
+	}
+	return l.client.Do(req)
+}
+
 func newPostRequest(args ...ref.Val) ref.Val {
 	if len(args) != 3 {
 		return types.NewErr("no such overload for post request")
 	}
-	content, ok := args[1].(types.String)
+	content, ok := args[1].(types.String)
+	if !ok {
+		return types.ValOrErr(content, "no such overload for request")
+	}
+	url := args[0]
+	body := args[2]
+	req, err := makeRequestBody(types.String("POST"), url, body)
+	if err != nil {
+		return err
+	}
+	h, ok := req["Header"]
+	if !ok {
+		h = make(http.Header)
+		req["Header"] = h
+	}
+	h.(http.Header).Set("Content-Type", string(content))
+	return types.DefaultTypeAdapter.NativeToValue(req)
+}
+
+func newMultipartRequest(url, fields ref.Val) ref.Val {
+	m, ok := fields.(traits.Mapper)
+	if !ok {
+		return types.ValOrErr(m, "no such overload for multipart_request")
+	}
+	var names []string
+	it := m.Iterator()
+	for it.HasNext() == types.True {
+		k, ok := it.Next().(types.String)
+		if !ok {
+			return types.NewErr("multipart_request: invalid field name type")
+		}
+		names = append(names, string(k))
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	for _, name := range names {
+		var err error
+		switch v := m.Get(types.String(name)).(type) {
+		case types.String:
+			err = w.WriteField(name, string(v))
+		case traits.Mapper:
+			err = writeFilePart(w, name, v)
+		default:
+			return types.NewErr("multipart_request: invalid type for field %q: %s", name, v.Type())
+		}
+		if err != nil {
+			return types.NewErr("multipart_request: %v", err)
+		}
+	}
+	err := w.Close()
+	if err != nil {
+		return types.NewErr("multipart_request: %v", err)
+	}
+	return newPostRequest(url, types.String(w.FormDataContentType()), types.Bytes(buf.Bytes()))
+}
+
+func writeFilePart(w *multipart.Writer, name string, part traits.Mapper) error {
+	filename, ok := part.Find(types.String("filename"))
+	if !ok {
+		return fmt.Errorf("missing filename for field %q", name)
+	}
+	fn, ok := filename.(types.String)
 	if !ok {
-		return types.ValOrErr(content, "no such overload for request")
+		return fmt.Errorf("invalid type for filename for field %q: %s", name, filename.Type())
 	}
-	url := args[0]
-	body := args[2]
-	req, err := makeRequestBody(types.String("POST"), url, body)
+	var content []byte
+	if v, ok := part.Find(types.String("content")); ok {
+		switch v := v.(type) {
+		case types.Bytes:
+			content = v
+		case types.String:
+			content = []byte(v)
+		default:
+			return fmt.Errorf("invalid type for content for field %q: %s", name, v.Type())
+		}
+	}
+	contentType := "application/octet-stream"
+	if v, ok := part.Find(types.String("content_type")); ok {
+		ct, ok := v.(types.String)
+		if !ok {
+			return fmt.Errorf("invalid type for content_type for field %q: %s", name, v.Type())
+		}
+		contentType = string(ct)
+	}
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", mime.FormatMediaType("form-data", map[string]string{"name": name, "filename": string(fn)}))
+	h.Set("Content-Type", contentType)
+	pw, err := w.CreatePart(h)
 	if err != nil {
 		return err
 	}
-	h, ok := req["Header"]
-	if !ok {
-		h = make(http.Header)
-		req["Header"] = h
-	}
-	h.(http.Header).Set("Content-Type", string(content))
-	return types.DefaultTypeAdapter.NativeToValue(req)
+	_, err = pw.Write(content)
+	return err
 }
 
 func newRequest(method, url ref.Val) ref.Val {
@@ -736,7 +2107,12 @@ func reqToMap(req *http.Request, url, body ref.Val) (map[string]interface{}, err
 		rm["Trailer"] = req.Trailer
 	}
 	if req.Response != nil {
-		resp, err := respToMap(req.Response)
+		// The request's response is a redirect response and its body
+		// has been consumed and closed by the client, so the body
+		// is not included.
+		r := *req.Response
+		r.Body = http.NoBody
+		resp, err := respToMap(&r, time.Time{})
 		if err != nil {
 			return nil, err
 		}
@@ -745,7 +2121,46 @@ func reqToMap(req *http.Request, url, body ref.Val) (map[string]interface{}, err
 	return rm, nil
 }
 
-func respToMap(resp *http.Response) (map[string]interface{}, error) {
+// respToMap returns a map representation of resp as for the respToMap
+// function, retaining only the configured response headers.
+func (l httpLib) respToMap(resp *http.Response, start time.Time) (map[string]interface{}, error) {
+	rm, err := respToMap(resp, start)
+	if err != nil || l.respHeaders == nil {
+		return rm, err
+	}
+	filterRespHeaders(rm, l.respHeaders)
+	return rm, nil
+}
+
+// filterRespHeaders removes headers not in allow from the response map
+// rm and from any redirect responses it holds.
+func filterRespHeaders(rm map[string]interface{}, allow map[string]bool) {
+	if h, ok := rm["Header"].(http.Header); ok {
+		filtered := make(http.Header)
+		for k, v := range h {
+			if allow[k] {
+				filtered[k] = v
+			}
+		}
+		rm["Header"] = filtered
+	}
+	req, ok := rm["Request"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	if resp, ok := req["Response"].(map[string]interface{}); ok {
+		filterRespHeaders(resp, allow)
+	}
+}
+
+// respToMap returns a map representation of resp. If start is not the zero
+// time, the map includes the start time in the StartedAt field and the time
+// since start in the Duration field.
+func respToMap(resp *http.Response, start time.Time) (map[string]interface{}, error) {
+	var duration time.Duration
+	if !start.IsZero() {
+		duration = time.Since(start)
+	}
 	rm := map[string]interface{}{
 		"Status":        resp.Status,
 		"StatusCode":    resp.StatusCode,
@@ -764,6 +2179,10 @@ func respToMap(resp *http.Response) (map[string]interface{}, error) {
 		return nil, err
 	}
 	rm["Body"] = buf.Bytes()
+	if !start.IsZero() {
+		rm["StartedAt"] = start
+		rm["Duration"] = duration
+	}
 	if resp.TransferEncoding != nil {
 		rm["TransferEncoding"] = resp.TransferEncoding
 	}
@@ -776,6 +2195,9 @@ func respToMap(resp *http.Response) (map[string]interface{}, error) {
 			return nil, err
 		}
 		rm["Request"] = req
+		// The response's request is the last request made by the
+		// client, so its URL is the URL after any redirects.
+		rm["FinalURL"] = resp.Request.URL.String()
 	}
 	return rm, nil
 }
@@ -807,22 +2229,201 @@ func (l httpLib) basicAuthentication(args ...ref.Val) ref.Val {
 	// simplifies the case where a body has already been added
 	// to the request.
 	req := reqm.(map[string]interface{})
-	var header http.Header
+	header, err := requestHeader(req)
+	if err != nil {
+		return types.NewErr("%s", err)
+	}
+	header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(username+":"+password)))
+	return types.DefaultTypeAdapter.NativeToValue(req)
+}
+
+// requestHeader returns the header of the request map, req, adding
+// an empty header to req if it has none.
+func requestHeader(req map[string]interface{}) (http.Header, error) {
 	switch h := req["Header"].(type) {
 	case nil:
-		header = make(http.Header)
+		header := make(http.Header)
 		req["Header"] = header
+		return header, nil
 	case map[string][]string:
-		header = h
+		return h, nil
 	case http.Header:
-		header = h
+		return h, nil
 	default:
-		return types.NewErr("invalid type in header field: %T", h)
+		return nil, fmt.Errorf("invalid type in header field: %T", h)
 	}
-	header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(username+":"+password)))
+}
+
+func compressBody(arg0, arg1 ref.Val) ref.Val {
+	request, ok := arg0.(traits.Mapper)
+	if !ok {
+		return types.ValOrErr(request, "no such overload for compress_body")
+	}
+	coding, ok := arg1.(types.String)
+	if !ok {
+		return types.ValOrErr(coding, "no such overload for compress_body")
+	}
+	reqm, err := request.ConvertToNative(reflectMapStringAnyType)
+	if err != nil {
+		return types.NewErr("%s", err)
+	}
+	req := reqm.(map[string]interface{})
+	if _, ok := req["BodyFile"]; ok {
+		return types.NewErr("compress_body: cannot compress body from file")
+	}
+	var body []byte
+	switch b := req["Body"].(type) {
+	case nil:
+	case types.Bytes:
+		body = b
+	case types.String:
+		body = []byte(b)
+	case []byte:
+		body = b
+	case string:
+		body = []byte(b)
+	default:
+		return types.NewErr("compress_body: invalid type for request body: %T", b)
+	}
+
+	var (
+		buf bytes.Buffer
+		w   io.WriteCloser
+	)
+	switch coding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		// The HTTP deflate content coding is the zlib format.
+		w = zlib.NewWriter(&buf)
+	default:
+		return types.NewErr("compress_body: unsupported content coding: %q", coding)
+	}
+	_, err = w.Write(body)
+	if err != nil {
+		return types.NewErr("compress_body: %v", err)
+	}
+	err = w.Close()
+	if err != nil {
+		return types.NewErr("compress_body: %v", err)
+	}
+
+	header, err := requestHeader(req)
+	if err != nil {
+		return types.NewErr("compress_body: %v", err)
+	}
+	header.Set("Content-Encoding", string(coding))
+	req["Body"] = buf.Bytes()
+	req["ContentLength"] = int64(buf.Len())
+	return types.DefaultTypeAdapter.NativeToValue(req)
+}
+
+func bodyFromFile(arg0, arg1 ref.Val) ref.Val {
+	request, ok := arg0.(traits.Mapper)
+	if !ok {
+		return types.ValOrErr(request, "no such overload for body_from_file")
+	}
+	path, ok := arg1.(types.String)
+	if !ok {
+		return types.ValOrErr(path, "no such overload for body_from_file")
+	}
+	reqm, err := request.ConvertToNative(reflectMapStringAnyType)
+	if err != nil {
+		return types.NewErr("%s", err)
+	}
+	fi, err := os.Stat(string(path))
+	if err != nil {
+		return types.NewErr("body_from_file: %v", err)
+	}
+	if !fi.Mode().IsRegular() {
+		return types.NewErr("body_from_file: %s is not a regular file", path)
+	}
+	req := reqm.(map[string]interface{})
+	delete(req, "Body")
+	req["BodyFile"] = string(path)
+	req["ContentLength"] = fi.Size()
+	return types.DefaultTypeAdapter.NativeToValue(req)
+}
+
+func accept(arg0, arg1 ref.Val) ref.Val {
+	request, ok := arg0.(traits.Mapper)
+	if !ok {
+		return types.ValOrErr(request, "no such overload for accept")
+	}
+	mediaType, ok := arg1.(types.String)
+	if !ok {
+		return types.ValOrErr(mediaType, "no such overload for accept")
+	}
+	reqm, err := request.ConvertToNative(reflectMapStringAnyType)
+	if err != nil {
+		return types.NewErr("%s", err)
+	}
+	req := reqm.(map[string]interface{})
+	header, err := requestHeader(req)
+	if err != nil {
+		return types.NewErr("accept: %v", err)
+	}
+	header.Set("Accept", string(mediaType))
 	return types.DefaultTypeAdapter.NativeToValue(req)
 }
 
+func autoDecode(arg ref.Val) ref.Val {
+	response, ok := arg.(traits.Mapper)
+	if !ok {
+		return types.ValOrErr(response, "no such overload for auto_decode")
+	}
+	var contentType string
+	if h, ok := response.Find(types.String("Header")); ok {
+		header, err := h.ConvertToNative(reflectHTTPHeaderType)
+		if err != nil {
+			return types.NewErr("auto_decode: invalid header: %v", err)
+		}
+		contentType = header.(http.Header).Get("Content-Type")
+	}
+	if contentType == "" {
+		return types.NewErr("auto_decode: no content type")
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return types.NewErr("auto_decode: %v", err)
+	}
+	var body []byte
+	if b, ok := response.Find(types.String("Body")); ok {
+		switch b := b.(type) {
+		case types.Bytes:
+			body = b
+		case types.String:
+			body = []byte(b)
+		default:
+			return types.NewErr("auto_decode: invalid type for response body: %s", b.Type())
+		}
+	}
+	switch {
+	case mediaType == "application/json", strings.HasSuffix(mediaType, "+json"):
+		var v interface{}
+		err := json.Unmarshal(body, &v)
+		if err != nil {
+			return types.NewErr("auto_decode: failed to unmarshal JSON message: %v", err)
+		}
+		return types.DefaultTypeAdapter.NativeToValue(v)
+	case mediaType == "application/x-ndjson":
+		return NDJSON(bytes.NewReader(body))
+	case mediaType == "application/xml", mediaType == "text/xml", strings.HasSuffix(mediaType, "+xml"):
+		m, err := xmlDocument(bytes.NewReader(body), nil)
+		if err != nil {
+			return types.NewErr("auto_decode: %v", err)
+		}
+		return types.DefaultTypeAdapter.NativeToValue(m)
+	case mediaType == "text/csv":
+		if strings.EqualFold(params["header"], "present") {
+			return CSVHeader(bytes.NewReader(body))
+		}
+		return CSVNoHeader(bytes.NewReader(body))
+	default:
+		return types.NewErr("auto_decode: unsupported content type: %s", mediaType)
+	}
+}
+
 func (l httpLib) doRequest(arg ref.Val) ref.Val {
 	request, ok := arg.(traits.Mapper)
 	if !ok {
@@ -838,28 +2439,233 @@ func (l httpLib) doRequest(arg ref.Val) ref.Val {
 	}
 	// Recover the context lost during serialisation to JSON.
 	req = req.WithContext(l.ctx)
-	err = l.limit.Wait(l.ctx)
+	err = l.wait(l.ctx)
 	if err != nil {
+		closeBody(req)
 		return types.NewErr("%s", err)
 	}
+	start := l.start()
 	resp, err := l.client.Do(req)
 	if err != nil {
 		return types.NewErr("%s", err)
 	}
-	respm, err := respToMap(resp)
+	respm, err := l.respToMap(resp, start)
+	if err != nil {
+		return types.NewErr("%s", err)
+	}
+	return types.DefaultTypeAdapter.NativeToValue(respm)
+}
+
+func (l httpLib) doRequestWithRetry(arg0, arg1 ref.Val) ref.Val {
+	request, ok := arg0.(traits.Mapper)
+	if !ok {
+		return types.ValOrErr(request, "no such overload for do_request")
+	}
+	reqm, err := request.ConvertToNative(reflectMapStringAnyType)
+	if err != nil {
+		return types.NewErr("%s", err)
+	}
+	policy, err := makeRetryPolicy(arg1)
+	if err != nil {
+		return types.NewErr("do_request: %s", err)
+	}
+	var (
+		resp     *http.Response
+		start    time.Time
+		attempts int
+	)
+	for {
+		attempts++
+		var req *http.Request
+		req, err = mapToReq(reqm.(map[string]interface{}))
+		if err != nil {
+			return types.NewErr("%s", err)
+		}
+		// Recover the context lost during serialisation to JSON.
+		req = req.WithContext(l.ctx)
+		err = l.wait(l.ctx)
+		if err != nil {
+			closeBody(req)
+			return types.NewErr("%s", err)
+		}
+		start = l.start()
+		resp, err = l.client.Do(req)
+		if attempts >= policy.maxAttempts || !policy.shouldRetry(resp, err) {
+			break
+		}
+		wait := policy.backoff(attempts, resp)
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-l.ctx.Done():
+			t.Stop()
+			return types.NewErr("%s", l.ctx.Err())
+		case <-t.C:
+		}
+	}
+	if err != nil {
+		return types.NewErr("%s", err)
+	}
+	respm, err := l.respToMap(resp, start)
 	if err != nil {
 		return types.NewErr("%s", err)
 	}
+	respm["Attempts"] = attempts
 	return types.DefaultTypeAdapter.NativeToValue(respm)
 }
 
+// retryPolicy is the retry configuration for do_request.
+type retryPolicy struct {
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	retryOn        map[int]bool
+}
+
+func makeRetryPolicy(arg ref.Val) (*retryPolicy, error) {
+	m, ok := arg.(traits.Mapper)
+	if !ok {
+		return nil, fmt.Errorf("invalid type for retry policy: %s", arg.Type())
+	}
+	p := retryPolicy{
+		maxAttempts:    3,
+		initialBackoff: time.Second,
+		maxBackoff:     30 * time.Second,
+		retryOn: map[int]bool{
+			http.StatusTooManyRequests:     true,
+			http.StatusInternalServerError: true,
+			http.StatusBadGateway:          true,
+			http.StatusServiceUnavailable:  true,
+			http.StatusGatewayTimeout:      true,
+		},
+	}
+	if v, ok := m.Find(types.String("max_attempts")); ok {
+		n, ok := v.(types.Int)
+		if !ok || n < 1 {
+			return nil, fmt.Errorf("invalid max_attempts: %v", v)
+		}
+		p.maxAttempts = int(n)
+	}
+	if v, ok := m.Find(types.String("initial_backoff")); ok {
+		d, ok := v.(types.Duration)
+		if !ok || d.Duration < 0 {
+			return nil, fmt.Errorf("invalid initial_backoff: %v", v)
+		}
+		p.initialBackoff = d.Duration
+	}
+	if v, ok := m.Find(types.String("max_backoff")); ok {
+		d, ok := v.(types.Duration)
+		if !ok || d.Duration < 0 {
+			return nil, fmt.Errorf("invalid max_backoff: %v", v)
+		}
+		p.maxBackoff = d.Duration
+	}
+	if v, ok := m.Find(types.String("retry_on")); ok {
+		codes, ok := v.(traits.Lister)
+		if !ok {
+			return nil, fmt.Errorf("invalid retry_on: %v", v)
+		}
+		p.retryOn = make(map[int]bool)
+		it := codes.Iterator()
+		for it.HasNext() == types.True {
+			c, ok := it.Next().(types.Int)
+			if !ok {
+				return nil, fmt.Errorf("invalid retry_on: %v", v)
+			}
+			p.retryOn[int(c)] = true
+		}
+	}
+	return &p, nil
+}
+
+func (p *retryPolicy) shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return p.retryOn[resp.StatusCode]
+}
+
+// backoff returns the wait before the next attempt after the given number
+// of attempts, using the Retry-After header of resp if it is present.
+func (p *retryPolicy) backoff(attempts int, resp *http.Response) time.Duration {
+	wait := p.initialBackoff
+	for i := 1; i < attempts && wait < p.maxBackoff; i++ {
+		wait *= 2
+	}
+	if resp != nil {
+		if after, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+			wait = after
+		}
+	}
+	if wait > p.maxBackoff {
+		wait = p.maxBackoff
+	}
+	return wait
+}
+
+// retryAfter returns the wait described by a Retry-After header value.
+func retryAfter(h string) (time.Duration, bool) {
+	if h == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(h); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	t, err := http.ParseTime(h)
+	if err != nil {
+		return 0, false
+	}
+	wait := time.Until(t)
+	if wait < 0 {
+		wait = 0
+	}
+	return wait, true
+}
+
 func mapToReq(rm map[string]interface{}) (*http.Request, error) {
 	if rm == nil {
 		return nil, nil
 	}
 	req := &http.Request{}
 	err := mapConv(reflect.ValueOf(req).Elem(), rm)
-	return req, err
+	if err != nil {
+		return req, err
+	}
+	if path, ok := rm["BodyFile"]; ok {
+		// The body is streamed from the file named in the
+		// BodyFile field set by body_from_file.
+		var name string
+		switch path := path.(type) {
+		case string:
+			name = path
+		case types.String:
+			name = string(path)
+		default:
+			return nil, fmt.Errorf("invalid type for body file: %T", path)
+		}
+		req.GetBody = func() (io.ReadCloser, error) {
+			return os.Open(name)
+		}
+		req.Body, err = req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return req, nil
+}
+
+// closeBody closes the body of a request that will not be sent. The client
+// closes the body of requests that are sent.
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
 }
 
 func mapToResp(rm map[string]interface{}) (*http.Response, error) {
@@ -999,6 +2805,131 @@ func makeURL(val reflect.Value) (reflect.Value, error) {
 	return reflect.ValueOf(u), nil
 }
 
+func (l httpLib) cookies(arg ref.Val) ref.Val {
+	addr, ok := arg.(types.String)
+	if !ok {
+		return types.ValOrErr(addr, "no such overload for cookies")
+	}
+	if l.client.Jar == nil {
+		return types.NewErr("cookies: no cookie jar")
+	}
+	u, err := url.Parse(string(addr))
+	if err != nil {
+		return types.NewErr("cookies: %v", err)
+	}
+	cookies := l.client.Jar.Cookies(u)
+	vals := make([]ref.Val, len(cookies))
+	for i, c := range cookies {
+		vals[i] = types.DefaultTypeAdapter.NativeToValue(map[string]any{
+			"Name":  c.Name,
+			"Value": c.Value,
+		})
+	}
+	return types.NewRefValList(types.DefaultTypeAdapter, vals)
+}
+
+func parseCookie(arg ref.Val) ref.Val {
+	s, ok := arg.(types.String)
+	if !ok {
+		return types.ValOrErr(s, "no such overload for parse_cookie")
+	}
+	resp := http.Response{Header: http.Header{"Set-Cookie": {string(s)}}}
+	cookies := resp.Cookies()
+	if len(cookies) == 0 {
+		return types.NewErr("parse_cookie: invalid cookie: %q", s)
+	}
+	c := cookies[0]
+	m := map[string]interface{}{
+		"Name":     c.Name,
+		"Value":    c.Value,
+		"Path":     c.Path,
+		"Domain":   c.Domain,
+		"MaxAge":   c.MaxAge,
+		"Secure":   c.Secure,
+		"HttpOnly": c.HttpOnly,
+	}
+	if c.RawExpires != "" {
+		m["Expires"] = c.Expires
+	}
+	if name, ok := sameSiteNames[c.SameSite]; ok {
+		m["SameSite"] = name
+	}
+	return types.DefaultTypeAdapter.NativeToValue(m)
+}
+
+var sameSiteNames = map[http.SameSite]string{
+	http.SameSiteDefaultMode: "Default",
+	http.SameSiteLaxMode:     "Lax",
+	http.SameSiteStrictMode:  "Strict",
+	http.SameSiteNoneMode:    "None",
+}
+
+func formatCookie(arg ref.Val) ref.Val {
+	m, ok := arg.(traits.Mapper)
+	if !ok {
+		return types.ValOrErr(m, "no such overload for format_cookie")
+	}
+	var c http.Cookie
+	it := m.Iterator()
+	for it.HasNext() == types.True {
+		k := it.Next()
+		field, ok := k.(types.String)
+		if !ok {
+			return types.NewErr("format_cookie: invalid field name type: %s", k.Type())
+		}
+		switch v := m.Get(k).(type) {
+		case types.String:
+			switch field {
+			case "Name":
+				c.Name = string(v)
+			case "Value":
+				c.Value = string(v)
+			case "Path":
+				c.Path = string(v)
+			case "Domain":
+				c.Domain = string(v)
+			case "SameSite":
+				for mode, name := range sameSiteNames {
+					if strings.EqualFold(string(v), name) {
+						c.SameSite = mode
+						break
+					}
+				}
+				if c.SameSite == 0 {
+					return types.NewErr("format_cookie: invalid SameSite value: %q", v)
+				}
+			default:
+				return types.NewErr("format_cookie: invalid field %q for type %s", field, v.Type())
+			}
+		case types.Bool:
+			switch field {
+			case "Secure":
+				c.Secure = bool(v)
+			case "HttpOnly":
+				c.HttpOnly = bool(v)
+			default:
+				return types.NewErr("format_cookie: invalid field %q for type %s", field, v.Type())
+			}
+		case types.Int:
+			if field != "MaxAge" {
+				return types.NewErr("format_cookie: invalid field %q for type %s", field, v.Type())
+			}
+			c.MaxAge = int(v)
+		case types.Timestamp:
+			if field != "Expires" {
+				return types.NewErr("format_cookie: invalid field %q for type %s", field, v.Type())
+			}
+			c.Expires = v.Time
+		default:
+			return types.NewErr("format_cookie: invalid field %q for type %s", field, v.Type())
+		}
+	}
+	if c.Name == "" {
+		return types.NewErr("format_cookie: missing cookie name")
+	}
+	return types.String(c.String())
+}
+
 func parseURL(arg ref.Val) ref.Val {
 	addr, ok := arg.(types.String)
 	if !ok {
@@ -1141,3 +3072,57 @@ func formatQuery(arg ref.Val) ref.Val {
 		return types.NewErr("invalid type for format_url: %T", q)
 	}
 }
+
+func urlJoin(arg0, arg1 ref.Val) ref.Val {
+	base, ok := arg0.(types.String)
+	if !ok {
+		return types.ValOrErr(base, "no such overload for url_join")
+	}
+	rel, ok := arg1.(types.String)
+	if !ok {
+		return types.ValOrErr(rel, "no such overload for url_join")
+	}
+	b, err := url.Parse(string(base))
+	if err != nil {
+		return types.NewErr("url_join: %v", err)
+	}
+	r, err := url.Parse(string(rel))
+	if err != nil {
+		return types.NewErr("url_join: %v", err)
+	}
+	return types.String(b.ResolveReference(r).String())
+}
+
+func setQuery(arg0, arg1 ref.Val) ref.Val {
+	addr, ok := arg0.(types.String)
+	if !ok {
+		return types.ValOrErr(addr, "no such overload for set_query")
+	}
+	queryMap, ok := arg1.(traits.Mapper)
+	if !ok {
+		return types.ValOrErr(queryMap, "no such overload for set_query")
+	}
+	q, err := queryMap.ConvertToNative(reflectMapStringStringSliceType)
+	if err != nil {
+		return types.NewErr("set_query: %v", err)
+	}
+	u, err := url.Parse(string(addr))
+	if err != nil {
+		return types.NewErr("set_query: %v", err)
+	}
+	query := u.Query()
+	switch q := q.(type) {
+	case url.Values:
+		for k, v := range q {
+			query[k] = v
+		}
+	case map[string][]string:
+		for k, v := range q {
+			query[k] = v
+		}
+	default:
+		return types.NewErr("invalid type for set_query: %T", q)
+	}
+	u.RawQuery = query.Encode()
+	return types.String(u.String())
+}
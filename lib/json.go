@@ -20,6 +20,7 @@ package lib
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 
@@ -28,6 +29,7 @@ import (
 	"github.com/google/cel-go/checker/decls"
 	"github.com/google/cel-go/common/types"
 	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
 	"github.com/google/cel-go/interpreter/functions"
 	expr "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
 )
@@ -50,6 +52,34 @@ import (
 //	{"a":1, "b":[1, 2, 3]}.encode_json()  // return "{\"a\":1,\"b\":[1,2,3]}"
 //	encode_json({"a":1, "b":[1, 2, 3]})   // return "{\"a\":1,\"b\":[1,2,3]}"
 //
+// # Encode JSON Pretty
+//
+// encode_json_pretty returns a string of the indented JSON encoding of the
+// receiver or first parameter, using the provided indent string for each
+// level of nesting. HTML characters are not escaped:
+//
+//	encode_json_pretty(<dyn>, <string>) -> <string>
+//	<dyn>.encode_json_pretty(<string>) -> <string>
+//
+// Examples:
+//
+//	{"a":1, "b":[1, 2]}.encode_json_pretty("  ")  // return "{\n  \"a\": 1,\n  \"b\": [\n    1,\n    2\n  ]\n}"
+//	encode_json_pretty({"a":"<b>"}, "\t")          // return "{\n\t\"a\": \"<b>\"\n}"
+//
+// # Pretty
+//
+// pretty is a shorthand for encode_json_pretty. It allows programs to
+// control the indentation of embedded JSON strings independently of the
+// formatting of the program's output:
+//
+//	pretty(<dyn>, <string>) -> <string>
+//	<dyn>.pretty(<string>) -> <string>
+//
+// Examples:
+//
+//	{"a":[1]}.pretty("  ")  // return "{\n  \"a\": [\n    1\n  ]\n}"
+//	pretty({"a":1}, "\t")   // return "{\n\t\"a\": 1\n}"
+//
 // # Decode JSON
 //
 // decode_json returns the object described by the JSON encoding of the receiver
@@ -65,6 +95,24 @@ import (
 //	"{\"a\":1,\"b\":[1,2,3]}".decode_json()   // return {"a":1, "b":[1, 2, 3]}
 //	b"{\"a\":1,\"b\":[1,2,3]}".decode_json()  // return {"a":1, "b":[1, 2, 3]}
 //
+// # Decode JSON Number
+//
+// decode_json_number returns the object described by the JSON encoding of the
+// receiver or parameter as for decode_json, except that numbers are decoded
+// as ints when they are integers that fit in an int and as doubles otherwise.
+// This preserves the precision of large integers such as 64-bit identifiers:
+//
+//	<bytes>.decode_json_number() -> <dyn>
+//	<string>.decode_json_number() -> <dyn>
+//	decode_json_number(<bytes>) -> <dyn>
+//	decode_json_number(<string>) -> <dyn>
+//
+// Examples:
+//
+//	"{\"id\":1234567890123456789}".decode_json_number()  // return {"id":1234567890123456789}
+//	"{\"id\":1234567890123456789}".decode_json()         // return {"id":1.2345678901234568e+18}
+//	"[1, 1.5, 1e3]".decode_json_number()                 // return [1, 1.5, 1000.0]
+//
 // # Decode JSON Stream
 //
 // decode_json_stream returns a list of objects described by the JSON stream
@@ -79,15 +127,48 @@ import (
 //
 //	'{"a":1}{"b":2}'.decode_json_stream()   // return [{"a":1}, {"b":2}]
 //	b'{"a":1}{"b":2}'.decode_json_stream()  // return [{"a":1}, {"b":2}]
+//
+// # Elasticsearch Bulk
+//
+// es_bulk returns the NDJSON body of an Elasticsearch _bulk API request that
+// indexes the documents in the receiver or first parameter into the index
+// given by the second parameter. Each document is preceded by an index action
+// line. If the id field parameter is not empty, the value of the named
+// top-level field of each document is used as the document's _id; the field
+// must be present and hold a string or an integer. The returned string is
+// newline terminated as required by the _bulk API:
+//
+//	es_bulk(<list<map<string,dyn>>>, <string>, <string>) -> <string>
+//	<list<map<string,dyn>>>.es_bulk(<string>, <string>) -> <string>
+//
+// Examples:
+//
+//	[{"id":"a", "v":1}, {"id":"b", "v":2}].es_bulk("logs", "id")  // return "{\"index\":{\"_index\":\"logs\",\"_id\":\"a\"}}\n{\"id\":\"a\",\"v\":1}\n{\"index\":{\"_index\":\"logs\",\"_id\":\"b\"}}\n{\"id\":\"b\",\"v\":2}\n"
+//	es_bulk([{"v":1}], "logs", "")                                // return "{\"index\":{\"_index\":\"logs\"}}\n{\"v\":1}\n"
 func JSON(adapter ref.TypeAdapter) cel.EnvOption {
 	if adapter == nil {
 		adapter = types.DefaultTypeAdapter
 	}
-	return cel.Lib(jsonLib{adapter})
+	return cel.Lib(jsonLib{adapter: adapter})
+}
+
+// JSONWithStreamLimit returns a cel.EnvOption to configure extended functions
+// for JSON coding and decoding as described in JSON, with a limit on the number
+// of elements that will be decoded by decode_json_stream. If limit is greater
+// than zero and a stream holds more than limit elements, decode_json_stream
+// returns an error, or if truncate is true, the first limit elements.
+func JSONWithStreamLimit(adapter ref.TypeAdapter, limit int, truncate bool) cel.EnvOption {
+	if adapter == nil {
+		adapter = types.DefaultTypeAdapter
+	}
+	return cel.Lib(jsonLib{adapter: adapter, streamLimit: limit, truncate: truncate})
 }
 
 type jsonLib struct {
 	adapter ref.TypeAdapter
+
+	streamLimit int  // Zero is no limit.
+	truncate    bool // Truncate streams longer than streamLimit rather than failing.
 }
 
 func (jsonLib) CompileOptions() []cel.EnvOption {
@@ -105,6 +186,30 @@ func (jsonLib) CompileOptions() []cel.EnvOption {
 					decls.String,
 				),
 			),
+			decls.NewFunction("encode_json_pretty",
+				decls.NewOverload(
+					"encode_json_pretty_dyn_string",
+					[]*expr.Type{decls.Dyn, decls.String},
+					decls.String,
+				),
+				decls.NewInstanceOverload(
+					"dyn_encode_json_pretty_string",
+					[]*expr.Type{decls.Dyn, decls.String},
+					decls.String,
+				),
+			),
+			decls.NewFunction("pretty",
+				decls.NewOverload(
+					"pretty_dyn_string",
+					[]*expr.Type{decls.Dyn, decls.String},
+					decls.String,
+				),
+				decls.NewInstanceOverload(
+					"dyn_pretty_string",
+					[]*expr.Type{decls.Dyn, decls.String},
+					decls.String,
+				),
+			),
 			decls.NewFunction("decode_json",
 				decls.NewOverload(
 					"decode_json_string",
@@ -127,6 +232,28 @@ func (jsonLib) CompileOptions() []cel.EnvOption {
 					decls.Dyn,
 				),
 			),
+			decls.NewFunction("decode_json_number",
+				decls.NewOverload(
+					"decode_json_number_string",
+					[]*expr.Type{decls.String},
+					decls.Dyn,
+				),
+				decls.NewInstanceOverload(
+					"string_decode_json_number",
+					[]*expr.Type{decls.String},
+					decls.Dyn,
+				),
+				decls.NewOverload(
+					"decode_json_number_bytes",
+					[]*expr.Type{decls.Bytes},
+					decls.Dyn,
+				),
+				decls.NewInstanceOverload(
+					"bytes_decode_json_number",
+					[]*expr.Type{decls.Bytes},
+					decls.Dyn,
+				),
+			),
 			decls.NewFunction("decode_json_stream",
 				decls.NewOverload(
 					"decode_json_stream_string",
@@ -149,6 +276,18 @@ func (jsonLib) CompileOptions() []cel.EnvOption {
 					decls.NewListType(decls.Dyn),
 				),
 			),
+			decls.NewFunction("es_bulk",
+				decls.NewOverload(
+					"es_bulk_list_string_string",
+					[]*expr.Type{decls.NewListType(decls.NewMapType(decls.String, decls.Dyn)), decls.String, decls.String},
+					decls.String,
+				),
+				decls.NewInstanceOverload(
+					"list_es_bulk_string_string",
+					[]*expr.Type{decls.NewListType(decls.NewMapType(decls.String, decls.Dyn)), decls.String, decls.String},
+					decls.String,
+				),
+			),
 		),
 	}
 }
@@ -165,6 +304,26 @@ func (l jsonLib) ProgramOptions() []cel.ProgramOption {
 				Unary:    encodeJSON,
 			},
 		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "encode_json_pretty_dyn_string",
+				Binary:   encodeJSONPretty,
+			},
+			&functions.Overload{
+				Operator: "dyn_encode_json_pretty_string",
+				Binary:   encodeJSONPretty,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "pretty_dyn_string",
+				Binary:   encodeJSONPretty,
+			},
+			&functions.Overload{
+				Operator: "dyn_pretty_string",
+				Binary:   encodeJSONPretty,
+			},
+		),
 		cel.Functions(
 			&functions.Overload{
 				Operator: "decode_json_string",
@@ -183,6 +342,24 @@ func (l jsonLib) ProgramOptions() []cel.ProgramOption {
 				Unary:    l.decodeJSON,
 			},
 		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "decode_json_number_string",
+				Unary:    l.decodeJSONNumber,
+			},
+			&functions.Overload{
+				Operator: "decode_json_number_bytes",
+				Unary:    l.decodeJSONNumber,
+			},
+			&functions.Overload{
+				Operator: "string_decode_json_number",
+				Unary:    l.decodeJSONNumber,
+			},
+			&functions.Overload{
+				Operator: "bytes_decode_json_number",
+				Unary:    l.decodeJSONNumber,
+			},
+		),
 		cel.Functions(
 			&functions.Overload{
 				Operator: "decode_json_stream_string",
@@ -201,10 +378,120 @@ func (l jsonLib) ProgramOptions() []cel.ProgramOption {
 				Unary:    l.decodeJSONStream,
 			},
 		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "es_bulk_list_string_string",
+				Function: esBulk,
+			},
+			&functions.Overload{
+				Operator: "list_es_bulk_string_string",
+				Function: esBulk,
+			},
+		),
 	}
 }
 
 func encodeJSON(val ref.Val) ref.Val {
+	v, err := nativeJSON(val)
+	if err != nil {
+		return types.NewErr("%v", err)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return types.NewErr("failed to marshal value to JSON: %v", err)
+	}
+	return types.String(b)
+}
+
+func encodeJSONPretty(val, indent ref.Val) ref.Val {
+	ind, ok := indent.(types.String)
+	if !ok {
+		return types.ValOrErr(ind, "no such overload for encode_json_pretty")
+	}
+	v, err := nativeJSON(val)
+	if err != nil {
+		return types.NewErr("%v", err)
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", string(ind))
+	err = enc.Encode(v)
+	if err != nil {
+		return types.NewErr("failed to marshal value to JSON: %v", err)
+	}
+	return types.String(bytes.TrimSuffix(buf.Bytes(), []byte{'\n'}))
+}
+
+// esBulkAction is the action and metadata line of an Elasticsearch _bulk
+// API index operation.
+type esBulkAction struct {
+	Index esBulkMeta `json:"index"`
+}
+
+type esBulkMeta struct {
+	Index string `json:"_index"`
+	ID    string `json:"_id,omitempty"`
+}
+
+func esBulk(args ...ref.Val) ref.Val {
+	if len(args) != 3 {
+		return types.NewErr("no such overload for es_bulk")
+	}
+	docs, ok := args[0].(traits.Lister)
+	if !ok {
+		return types.ValOrErr(args[0], "no such overload for es_bulk")
+	}
+	index, ok := args[1].(types.String)
+	if !ok {
+		return types.ValOrErr(args[1], "no such overload for es_bulk")
+	}
+	idField, ok := args[2].(types.String)
+	if !ok {
+		return types.ValOrErr(args[2], "no such overload for es_bulk")
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	it := docs.Iterator()
+	for i := 0; it.HasNext() == types.True; i++ {
+		doc, ok := it.Next().(traits.Mapper)
+		if !ok {
+			return types.NewErr("es_bulk: document %d is not a map", i)
+		}
+		action := esBulkAction{Index: esBulkMeta{Index: string(index)}}
+		if idField != "" {
+			id, ok := doc.Find(idField)
+			if !ok {
+				return types.NewErr("es_bulk: document %d has no %s field", i, idField)
+			}
+			switch id := id.(type) {
+			case types.String:
+				action.Index.ID = string(id)
+			case types.Int, types.Uint:
+				action.Index.ID = fmt.Sprint(id.Value())
+			default:
+				return types.NewErr("es_bulk: invalid type for document %d id: %s", i, id.Type().TypeName())
+			}
+		}
+		err := enc.Encode(action)
+		if err != nil {
+			return types.NewErr("es_bulk: failed to marshal action: %v", err)
+		}
+		v, err := nativeJSON(doc)
+		if err != nil {
+			return types.NewErr("es_bulk: %v", err)
+		}
+		err = enc.Encode(v)
+		if err != nil {
+			return types.NewErr("es_bulk: failed to marshal document %d to JSON: %v", i, err)
+		}
+	}
+	return types.String(buf.String())
+}
+
+// nativeJSON returns a native Go value for val that can be marshaled
+// to JSON.
+func nativeJSON(val ref.Val) (interface{}, error) {
 	var v interface{}
 	// Avoid type conversions if possible.
 	switch under := val.Value().(type) {
@@ -213,7 +500,7 @@ func encodeJSON(val ref.Val) ref.Val {
 	case map[ref.Val]ref.Val:
 		pb, err := val.ConvertToNative(structpbValueType)
 		if err != nil {
-			return types.NewErr("failed proto conversion: %v", err)
+			return nil, fmt.Errorf("failed proto conversion: %w", err)
 		}
 		v = pb.(*structpb.Value).AsInterface()
 	default:
@@ -236,14 +523,10 @@ func encodeJSON(val ref.Val) ref.Val {
 			}
 		}
 		if v == nil {
-			return types.NewErr("failed to get native value for JSON")
+			return nil, errors.New("failed to get native value for JSON")
 		}
 	}
-	b, err := json.Marshal(v)
-	if err != nil {
-		return types.NewErr("failed to marshal value to JSON: %v", err)
-	}
-	return types.String(b)
+	return v, nil
 }
 
 func (l jsonLib) decodeJSON(val ref.Val) ref.Val {
@@ -265,6 +548,67 @@ func (l jsonLib) decodeJSON(val ref.Val) ref.Val {
 	return l.adapter.NativeToValue(v)
 }
 
+func (l jsonLib) decodeJSONNumber(val ref.Val) ref.Val {
+	var r io.Reader
+	switch msg := val.(type) {
+	case types.Bytes:
+		r = bytes.NewReader(msg)
+	case types.String:
+		r = bytes.NewReader([]byte(msg))
+	default:
+		return types.NoSuchOverloadErr()
+	}
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	var v interface{}
+	err := dec.Decode(&v)
+	if err == nil {
+		if _, err = dec.Token(); err != io.EOF {
+			err = errors.New("invalid data after top-level value")
+		} else {
+			err = nil
+		}
+	}
+	if err != nil {
+		return types.NewErr("failed to unmarshal JSON message: %v", err)
+	}
+	v, err = convertNumbers(v)
+	if err != nil {
+		return types.NewErr("failed to unmarshal JSON message: %v", err)
+	}
+	return l.adapter.NativeToValue(v)
+}
+
+// convertNumbers replaces the json.Number values in v with int64 values
+// when they are integers that fit in an int64, and float64 values otherwise.
+// Maps and slices in v are modified in place.
+func convertNumbers(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		return v.Float64()
+	case map[string]interface{}:
+		for k, e := range v {
+			e, err := convertNumbers(e)
+			if err != nil {
+				return nil, err
+			}
+			v[k] = e
+		}
+	case []interface{}:
+		for i, e := range v {
+			e, err := convertNumbers(e)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = e
+		}
+	}
+	return v, nil
+}
+
 func (l jsonLib) decodeJSONStream(val ref.Val) ref.Val {
 	var r io.Reader
 	switch msg := val.(type) {
@@ -278,6 +622,12 @@ func (l jsonLib) decodeJSONStream(val ref.Val) ref.Val {
 	var s []interface{}
 	dec := json.NewDecoder(r)
 	for dec.More() {
+		if l.streamLimit > 0 && len(s) == l.streamLimit {
+			if l.truncate {
+				break
+			}
+			return types.NewErr("failed to unmarshal JSON stream: exceeded element limit of %d", l.streamLimit)
+		}
 		var v interface{}
 		err := dec.Decode(&v)
 		if err != nil {
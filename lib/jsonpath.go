@@ -0,0 +1,357 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package lib
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+)
+
+func jsonPath(obj, path ref.Val) ref.Val {
+	p, ok := path.(types.String)
+	if !ok {
+		return types.ValOrErr(p, "no such overload for json_path")
+	}
+	steps, err := parseJSONPath(string(p))
+	if err != nil {
+		return types.NewErr("json_path: %v", err)
+	}
+	matches := []ref.Val{obj}
+	for _, s := range steps {
+		var next []ref.Val
+		for _, m := range matches {
+			next = s.apply(next, m)
+		}
+		matches = next
+	}
+	return types.NewRefValList(types.DefaultTypeAdapter, matches)
+}
+
+// jsonPathStep is a single step in a JSON path.
+type jsonPathStep interface {
+	// apply appends the results of applying the step to val to dst.
+	apply(dst []ref.Val, val ref.Val) []ref.Val
+}
+
+// fieldStep selects a named field of a map.
+type fieldStep string
+
+func (s fieldStep) apply(dst []ref.Val, val ref.Val) []ref.Val {
+	m, ok := val.(traits.Mapper)
+	if !ok {
+		return dst
+	}
+	v, ok := m.Find(types.String(s))
+	if !ok {
+		return dst
+	}
+	return append(dst, v)
+}
+
+// indexStep selects an element of a list. Negative indexes count back
+// from the end of the list.
+type indexStep int64
+
+func (s indexStep) apply(dst []ref.Val, val ref.Val) []ref.Val {
+	l, ok := val.(traits.Lister)
+	if !ok {
+		return dst
+	}
+	n := l.Size().(types.Int)
+	i := types.Int(s)
+	if i < 0 {
+		i += n
+	}
+	if i < 0 || n <= i {
+		return dst
+	}
+	return append(dst, l.Get(i))
+}
+
+// wildcardStep selects all elements of a list or all values of a map.
+// Map values are selected in key order.
+type wildcardStep struct{}
+
+func (wildcardStep) apply(dst []ref.Val, val ref.Val) []ref.Val {
+	return appendChildren(dst, val)
+}
+
+// filterStep selects the elements of a list or values of a map for
+// which the filter expression is true.
+type filterStep struct {
+	path []jsonPathStep
+	op   string  // Empty for an existence test.
+	val  ref.Val // Nil for an existence test.
+}
+
+func (s filterStep) apply(dst []ref.Val, val ref.Val) []ref.Val {
+	for _, c := range appendChildren(nil, val) {
+		if s.match(c) {
+			dst = append(dst, c)
+		}
+	}
+	return dst
+}
+
+func (s filterStep) match(val ref.Val) bool {
+	matches := []ref.Val{val}
+	for _, p := range s.path {
+		var next []ref.Val
+		for _, m := range matches {
+			next = p.apply(next, m)
+		}
+		matches = next
+	}
+	if s.op == "" {
+		return len(matches) != 0
+	}
+	for _, m := range matches {
+		switch s.op {
+		case "==":
+			if m.Equal(s.val) == types.True {
+				return true
+			}
+		case "!=":
+			if m.Equal(s.val) == types.False {
+				return true
+			}
+		default:
+			c, ok := m.(traits.Comparer)
+			if !ok {
+				continue
+			}
+			cmp, ok := c.Compare(s.val).(types.Int)
+			if !ok {
+				continue
+			}
+			switch {
+			case s.op == "<" && cmp < 0,
+				s.op == "<=" && cmp <= 0,
+				s.op == ">" && cmp > 0,
+				s.op == ">=" && cmp >= 0:
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// appendChildren appends the elements of a list or the values of a map
+// in key order to dst.
+func appendChildren(dst []ref.Val, val ref.Val) []ref.Val {
+	switch obj := val.(type) {
+	case traits.Lister:
+		it := obj.Iterator()
+		for it.HasNext() == types.True {
+			dst = append(dst, it.Next())
+		}
+	case traits.Mapper:
+		keys, ok := mapKeys(obj).(traits.Lister)
+		if !ok {
+			return dst
+		}
+		it := keys.Iterator()
+		for it.HasNext() == types.True {
+			dst = append(dst, obj.Get(it.Next()))
+		}
+	}
+	return dst
+}
+
+// parseJSONPath parses the supported subset of JSONPath. The path must
+// start with $ and may be followed by .name, .*, ['name'], [n], [*] and
+// [?(@.path op value)] steps, where op is one of ==, !=, <, <=, > and >=,
+// and value is a number, quoted string, true, false or null. The op and
+// value may be omitted to test for the existence of the path.
+func parseJSONPath(path string) ([]jsonPathStep, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("path must start with $: %q", path)
+	}
+	return parseJSONPathSteps(path[1:], path)
+}
+
+// parseJSONPathSteps parses the steps in s. The complete path is used for
+// error messages.
+func parseJSONPathSteps(s, path string) ([]jsonPathStep, error) {
+	var steps []jsonPathStep
+	for s != "" {
+		switch s[0] {
+		case '.':
+			s = s[1:]
+			end := strings.IndexAny(s, ".[")
+			if end < 0 {
+				end = len(s)
+			}
+			name := s[:end]
+			s = s[end:]
+			switch name {
+			case "":
+				return nil, fmt.Errorf("missing field name: %q", path)
+			case "*":
+				steps = append(steps, wildcardStep{})
+			default:
+				steps = append(steps, fieldStep(name))
+			}
+		case '[':
+			end := bracketEnd(s)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated bracket: %q", path)
+			}
+			sel := strings.TrimSpace(s[1:end])
+			s = s[end+1:]
+			switch {
+			case sel == "*":
+				steps = append(steps, wildcardStep{})
+			case strings.HasPrefix(sel, "?"):
+				f, err := parseJSONPathFilter(sel[1:], path)
+				if err != nil {
+					return nil, err
+				}
+				steps = append(steps, f)
+			case strings.HasPrefix(sel, "'"), strings.HasPrefix(sel, `"`):
+				name, err := unquoteJSONPath(sel)
+				if err != nil {
+					return nil, fmt.Errorf("invalid field name %s: %q", sel, path)
+				}
+				steps = append(steps, fieldStep(name))
+			default:
+				i, err := strconv.ParseInt(sel, 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid index %q: %q", sel, path)
+				}
+				steps = append(steps, indexStep(i))
+			}
+		default:
+			return nil, fmt.Errorf("unexpected character %q: %q", s[0], path)
+		}
+	}
+	return steps, nil
+}
+
+// bracketEnd returns the index of the bracket closing the bracket at the
+// start of s, ignoring brackets in quoted strings, or -1 if there is none.
+func bracketEnd(s string) int {
+	var (
+		depth int
+		quote byte
+	)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			switch c {
+			case '\\':
+				i++
+			case quote:
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// parseJSONPathFilter parses a filter expression of the form (@.path op value).
+func parseJSONPathFilter(s, path string) (jsonPathStep, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "(") || !strings.HasSuffix(s, ")") {
+		return nil, fmt.Errorf("invalid filter %q: %q", s, path)
+	}
+	s = strings.TrimSpace(s[1 : len(s)-1])
+	if !strings.HasPrefix(s, "@") {
+		return nil, fmt.Errorf("filter must start with @: %q", path)
+	}
+	var f filterStep
+	expr := s[1:]
+	if idx := strings.IndexAny(expr, "=!<>"); idx >= 0 {
+		op := expr[idx : idx+1]
+		if strings.HasPrefix(expr[idx+1:], "=") {
+			op = expr[idx : idx+2]
+		}
+		switch op {
+		case "==", "!=", "<", "<=", ">", ">=":
+		default:
+			return nil, fmt.Errorf("invalid filter operator %q: %q", op, path)
+		}
+		val, err := parseJSONPathValue(strings.TrimSpace(expr[idx+len(op):]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter value: %v: %q", err, path)
+		}
+		f.op = op
+		f.val = val
+		expr = strings.TrimSpace(expr[:idx])
+	}
+	var err error
+	f.path, err = parseJSONPathSteps(expr, path)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// parseJSONPathValue parses a filter comparison value.
+func parseJSONPathValue(s string) (ref.Val, error) {
+	switch s {
+	case "true":
+		return types.True, nil
+	case "false":
+		return types.False, nil
+	case "null":
+		return types.NullValue, nil
+	}
+	if strings.HasPrefix(s, "'") || strings.HasPrefix(s, `"`) {
+		v, err := unquoteJSONPath(s)
+		if err != nil {
+			return nil, err
+		}
+		return types.String(v), nil
+	}
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return types.Int(i), nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value %q", s)
+	}
+	return types.Double(f), nil
+}
+
+// unquoteJSONPath unquotes a single or double quoted string.
+func unquoteJSONPath(s string) (string, error) {
+	if len(s) < 2 || s[0] != s[len(s)-1] {
+		return "", fmt.Errorf("invalid quoted string %s", s)
+	}
+	if s[0] == '\'' {
+		// Convert to a double quoted string for strconv.Unquote.
+		s = `"` + strings.ReplaceAll(strings.ReplaceAll(s[1:len(s)-1], `\'`, `'`), `"`, `\"`) + `"`
+	}
+	return strconv.Unquote(s)
+}
@@ -41,8 +41,9 @@ import (
 // It takes a mapping of policy names to policy interpreters to allow implementing
 // specific rate limit policies. The map returned by the policy functions should
 // have "rate" and "next" fields with type rate.Limit or string with the value "inf",
-// a "burst" field with type int and a "reset" field with type time.Time in the UTC
-// location. The semantics of "rate" and "burst" are described in the documentation
+// a "burst" field with type int, a "reset" field with type time.Time in the UTC
+// location and a "reset_in" field with type time.Duration holding the time until
+// the reset. The semantics of "rate" and "burst" are described in the documentation
 // for the golang.org/x/time/rate package.
 //
 // The map may have other fields that can be logged. If a field named "error"
@@ -74,6 +75,8 @@ import (
 //
 //	rate_limit(h, 'okta', duration('1m'))
 //	rate_limit(h, 'draft', duration('1m'))
+//	rate_limit(h, 'draft8', duration('1m'))
+//	rate_limit(h, 'github', duration('1h'))
 //
 //	// Similar semantics to the okta policy.
 //	rate_limit(h, 'X-Rate-Limit', true, false, duration('1s'), 1)
@@ -207,7 +210,8 @@ func mapStrings(val ref.Val) (map[string][]string, error) {
 //	    "headers": "X-Rate-Limit-Limit=\"600\" X-Rate-Limit-Remaining=\"598\" X-Rate-Limit-Reset=\"1650094960\"",
 //	    "next": 10,
 //	    "rate": 0.9975873271836141,
-//	    "reset": "2022-04-16T07:48:40Z"
+//	    "reset": "2022-04-16T07:48:40Z",
+//	    "reset_in": "42s"
 //	},
 //
 // See https://developer.okta.com/docs/reference/rl-best-practices/
@@ -250,10 +254,11 @@ func OktaRateLimit(h http.Header, window time.Duration) map[string]interface{} {
 	return map[string]interface{}{
 		"headers": fmt.Sprintf("X-Rate-Limit-Limit=%q X-Rate-Limit-Remaining=%q X-Rate-Limit-Reset=%q",
 			limit, remaining, reset),
-		"rate":  rate.Limit(rem / per),
-		"next":  rate.Limit(lim / window.Seconds()),
-		"burst": 1, // Be conservative here; the docs don't exactly specify burst rates.
-		"reset": resetTime.UTC(),
+		"rate":     rate.Limit(rem / per),
+		"next":     rate.Limit(lim / window.Seconds()),
+		"burst":    1, // Be conservative here; the docs don't exactly specify burst rates.
+		"reset":    resetTime.UTC(),
+		"reset_in": time.Until(resetTime),
 	}
 }
 
@@ -278,7 +283,8 @@ func OktaRateLimit(h http.Header, window time.Duration) map[string]interface{} {
 //	    "headers": "Rate-Limit-Limit=\"5000\" Rate-Limit-Remaining=\"100\" Rate-Limit-Reset=\"Sat, 16 Apr 2022 07:48:40 GMT\"",
 //	    "next": 83.33333333333333,
 //	    "rate": 0.16689431007474315,
-//	    "reset": "2022-04-16T07:48:40Z"
+//	    "reset": "2022-04-16T07:48:40Z",
+//	    "reset_in": "42s"
 //	}
 //
 //	or
@@ -288,7 +294,8 @@ func OktaRateLimit(h http.Header, window time.Duration) map[string]interface{} {
 //	    "headers": "Rate-Limit-Limit=\"12, 12;window=1; burst=1000;policy=\\\"leaky bucket\\\"\" Rate-Limit-Remaining=\"100\" Rate-Limit-Reset=\"Sat, 16 Apr 2022 07:48:40 GMT\"",
 //	    "next": 12,
 //	    "rate": 100,
-//	    "reset": "2022-04-16T07:48:40Z"
+//	    "reset": "2022-04-16T07:48:40Z",
+//	    "reset_in": "42s"
 //	}
 //
 // See https://datatracker.ietf.org/doc/html/draft-polli-ratelimit-headers-00
@@ -374,13 +381,246 @@ func DraftRateLimit(h http.Header, window time.Duration) map[string]interface{}
 	return map[string]interface{}{
 		"headers": fmt.Sprintf("Rate-Limit-Limit=%q Rate-Limit-Remaining=%q Rate-Limit-Reset=%q",
 			limit, remaining, reset),
-		"rate":  rate.Limit(rem / per),
-		"next":  rate.Limit(float64(quota) / win),
-		"burst": burst,
-		"reset": resetTime.UTC(),
+		"rate":     rate.Limit(rem / per),
+		"next":     rate.Limit(float64(quota) / win),
+		"burst":    burst,
+		"reset":    resetTime.UTC(),
+		"reset_in": time.Until(resetTime),
 	}
 }
 
+// GitHubRateLimit implements the GitHub rate limit policy translation.
+// It should be handed to the Limit lib with
+//
+//	Limit(map[string]lib.LimitPolicy{
+//		"github": lib.GitHubRateLimit,
+//	})
+//
+// It will then be able to be used in a limit call with the window duration
+// of the primary rate limit given by the GitHub documentation, one hour for
+// most endpoints.
+//
+// If a Retry-After header is present, indicating that a secondary rate
+// limit has been exceeded, the rate is zero and the reset time is given by
+// the Retry-After header. In this case the next rate is calculated from the
+// X-RateLimit-Limit header if it is present, and is otherwise one request
+// per window.
+//
+// Example:
+//
+//	rate_limit(h, 'github', duration('1h'))
+//
+//	might return:
+//
+//	{
+//	    "burst": 1,
+//	    "headers": "X-RateLimit-Limit=\"5000\" X-RateLimit-Remaining=\"4987\" X-RateLimit-Reset=\"1650094960\" Retry-After=\"\"",
+//	    "next": 1.3888888888888888,
+//	    "rate": 2.770555555555556,
+//	    "reset": "2022-04-16T07:48:40Z",
+//	    "reset_in": "42s"
+//	}
+//
+// See https://docs.github.com/en/rest/using-the-rest-api/rate-limits-for-the-rest-api
+func GitHubRateLimit(h http.Header, window time.Duration) map[string]interface{} {
+	limit := h.Get("X-RateLimit-Limit")
+	remaining := h.Get("X-RateLimit-Remaining")
+	reset := h.Get("X-RateLimit-Reset")
+	retry := h.Get("Retry-After")
+	m := map[string]interface{}{
+		"headers": fmt.Sprintf("X-RateLimit-Limit=%q X-RateLimit-Remaining=%q X-RateLimit-Reset=%q Retry-After=%q",
+			limit, remaining, reset, retry),
+	}
+
+	if retry != "" {
+		// Secondary rate limit.
+		var resetTime time.Time
+		if d, err := strconv.ParseInt(retry, 10, 64); err == nil {
+			resetTime = time.Now().Add(time.Duration(d) * time.Second)
+		} else if t, err := time.Parse(http.TimeFormat, retry); err == nil {
+			resetTime = t
+		} else {
+			m["error"] = fmt.Sprintf("could not parse %q as number or timestamp", retry)
+			return m
+		}
+		next := rate.Limit(1 / window.Seconds())
+		if limit != "" {
+			lim, err := strconv.ParseFloat(limit, 64)
+			if err != nil {
+				m["error"] = err.Error()
+				return m
+			}
+			next = rate.Limit(lim / window.Seconds())
+		}
+		m["rate"] = rate.Limit(0)
+		m["next"] = next
+		m["burst"] = 1
+		m["reset"] = resetTime.UTC()
+		m["reset_in"] = time.Until(resetTime)
+		return m
+	}
+
+	if limit == "" || remaining == "" || reset == "" {
+		return m
+	}
+	lim, err := strconv.ParseFloat(limit, 64)
+	if err != nil {
+		m["error"] = err.Error()
+		return m
+	}
+	rem, err := strconv.ParseFloat(remaining, 64)
+	if err != nil {
+		m["error"] = err.Error()
+		return m
+	}
+	rst, err := strconv.ParseInt(reset, 10, 64)
+	if err != nil {
+		m["error"] = err.Error()
+		return m
+	}
+	resetTime := time.Unix(rst, 0)
+	per := time.Until(resetTime).Seconds()
+	m["rate"] = rate.Limit(rem / per)
+	m["next"] = rate.Limit(lim / window.Seconds())
+	m["burst"] = 1 // GitHub does not document burst behaviour.
+	m["reset"] = resetTime.UTC()
+	m["reset_in"] = time.Until(resetTime)
+	return m
+}
+
+// DraftV8RateLimit implements the draft-08 rate limit policy translation.
+// It should be handed to the Limit lib with
+//
+//	Limit(map[string]lib.LimitPolicy{
+//		"draft8": lib.DraftV8RateLimit,
+//	})
+//
+// It will then be able to be used in a limit call where the duration is
+// the default quota window.
+//
+// The policy reads the structured RateLimit header with its limit, remaining
+// and reset parameters, where reset is a delta in seconds. If a
+// RateLimit-Policy header is present, the window and burst of the first
+// policy item with a quota matching the limit are used.
+//
+// Example:
+//
+//	rate_limit(h, 'draft8', duration('60s'))
+//
+//	might return something like:
+//
+//	{
+//	    "burst": 1,
+//	    "headers": "RateLimit=\"limit=100, remaining=50, reset=30\" RateLimit-Policy=\"\"",
+//	    "next": 1.6666666666666667,
+//	    "rate": 1.6666666666666667,
+//	    "reset": "2022-04-16T07:48:40Z",
+//	    "reset_in": "42s"
+//	}
+//
+// See https://datatracker.ietf.org/doc/html/draft-ietf-httpapi-ratelimit-headers-08
+func DraftV8RateLimit(h http.Header, window time.Duration) map[string]interface{} {
+	header := h.Get("RateLimit")
+	policies := h.Get("RateLimit-Policy")
+	m := map[string]interface{}{
+		"headers": fmt.Sprintf("RateLimit=%q RateLimit-Policy=%q", header, policies),
+	}
+	if header == "" {
+		return m
+	}
+	params, err := parseDictionary(header)
+	if err != nil {
+		m["error"] = err.Error()
+		return m
+	}
+	limit, okLimit := params["limit"]
+	remaining, okRemaining := params["remaining"]
+	reset, okReset := params["reset"]
+	if !okLimit || !okRemaining || !okReset {
+		return m
+	}
+	lim, err := strconv.Atoi(limit)
+	if err != nil {
+		m["error"] = err.Error()
+		return m
+	}
+	rem, err := strconv.ParseFloat(remaining, 64)
+	if err != nil {
+		m["error"] = err.Error()
+		return m
+	}
+	per, err := strconv.ParseFloat(reset, 64)
+	if err != nil {
+		m["error"] = err.Error()
+		return m
+	}
+	resetTime := time.Now().Add(time.Duration(per) * time.Second)
+
+	win := window.Seconds()
+	burst := 1
+	if policies != "" {
+		for _, f := range strings.Split(policies, ",") {
+			p := policy(strings.TrimSpace(f))
+			q, err := p.quota()
+			if err != nil {
+				// Allow policy items with no parameters.
+				q, err = strconv.Atoi(string(p))
+			}
+			if err != nil {
+				m["error"] = err.Error()
+				return m
+			}
+			if q != lim {
+				continue
+			}
+			w, b, err := p.details(q)
+			if err != nil {
+				m["error"] = err.Error()
+				return m
+			}
+			if w >= 0 {
+				win = float64(w)
+			}
+			if b > 0 {
+				burst = b
+			}
+			break
+		}
+	}
+	m["rate"] = rate.Limit(rem / per)
+	m["next"] = rate.Limit(float64(lim) / win)
+	m["burst"] = burst
+	m["reset"] = resetTime.UTC()
+	m["reset_in"] = time.Until(resetTime)
+	return m
+}
+
+// parseDictionary parses the members of a structured field dictionary
+// into a map of member names to bare item values. Member parameters are
+// ignored and members without a value are given the value "?1".
+func parseDictionary(s string) (map[string]string, error) {
+	d := make(map[string]string)
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			return nil, fmt.Errorf("empty dictionary member: %q", s)
+		}
+		if idx := strings.Index(f, ";"); idx >= 0 {
+			f = f[:idx]
+		}
+		k, v, ok := strings.Cut(f, "=")
+		if !ok {
+			v = "?1"
+		}
+		k = strings.TrimSpace(k)
+		if k == "" {
+			return nil, fmt.Errorf("missing dictionary key: %q", s)
+		}
+		d[k] = strings.TrimSpace(v)
+	}
+	return d, nil
+}
+
 type policy string
 
 func (p policy) quota() (int, error) {
@@ -402,6 +642,12 @@ func (p policy) details(q int) (window, burst int, err error) {
 			if err != nil {
 				return window, burst, err
 			}
+		case strings.HasPrefix(f, "w="):
+			// Window parameter name used by later drafts.
+			window, err = strconv.Atoi(strings.TrimPrefix(f, "w="))
+			if err != nil {
+				return window, burst, err
+			}
 		case strings.HasPrefix(f, "burst="):
 			burst, err = strconv.Atoi(strings.TrimPrefix(f, "burst="))
 			if err != nil {
@@ -508,6 +754,7 @@ func limitPolicy(h http.Header, prefix string, canonical, delta bool, window tim
 	}
 	m["burst"] = burst
 	m["reset"] = resetTime.UTC()
+	m["reset_in"] = time.Until(resetTime)
 	return m
 }
 
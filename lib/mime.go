@@ -18,19 +18,27 @@
 package lib
 
 import (
+	"archive/tar"
 	"archive/zip"
 	"bufio"
 	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
 	"encoding/csv"
 	"encoding/json"
+	"fmt"
 	"io"
+	"mime"
 	"os"
+	"path"
+	"sync"
 
 	"github.com/google/cel-go/cel"
 	"github.com/google/cel-go/checker/decls"
 	"github.com/google/cel-go/common/types"
 	"github.com/google/cel-go/common/types/ref"
 	"github.com/google/cel-go/interpreter/functions"
+	"github.com/klauspost/compress/zstd"
 	expr "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
 )
 
@@ -40,6 +48,11 @@ import (
 // func(io.Reader) (io.Reader, error) or func(io.Reader) ref.Val. If the
 // transform is func([]byte) it is expected to mutate the bytes in place.
 //
+// Values registered with a key of the form "type/subtype; param" may also be
+// func(string) (func(io.Reader) ref.Val, error). These are parameterised
+// transforms that are constructed with the parameter's value when a mime type
+// with that single parameter is not otherwise registered. See ZipGlob.
+//
 // # MIME
 //
 // mime returns <dyn> interpreted through the registered MIME type:
@@ -96,11 +109,41 @@ func (l mimeLib) transformMIME(arg0, arg1 ref.Val) ref.Val {
 	if !ok {
 		return types.ValOrErr(mimetype, "no such overload for mime type: %s", arg1.Type())
 	}
-	transform, ok := l.transforms[string(mimetype)]
-	if !ok {
-		return types.NewErr("unknown transform: %q", mimetype)
+	transform, err := lookupTransform(l.transforms, string(mimetype))
+	if err != nil {
+		return types.NewErr("%v", err)
+	}
+	return applyTransform(transform, input)
+}
+
+// lookupTransform returns the transform registered for mimetype. If no
+// transform is registered for the complete mimetype and the mimetype has
+// a single parameter, a parameterised transform registered under the media
+// type and parameter name, for example "application/zip; glob", is called
+// with the parameter value to construct the transform.
+func lookupTransform(transforms map[string]interface{}, mimetype string) (interface{}, error) {
+	if transform, ok := transforms[mimetype]; ok {
+		return transform, nil
 	}
+	mediatype, params, err := mime.ParseMediaType(mimetype)
+	if err != nil || len(params) != 1 {
+		return nil, fmt.Errorf("unknown transform: %q", mimetype)
+	}
+	for name, val := range params {
+		switch newTransform := transforms[mediatype+"; "+name].(type) {
+		case func(string) (func(io.Reader) ref.Val, error):
+			transform, err := newTransform(val)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s parameter for %s: %v", name, mediatype, err)
+			}
+			return transform, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown transform: %q", mimetype)
+}
 
+// applyTransform applies transform to a copy of input.
+func applyTransform(transform interface{}, input []byte) ref.Val {
 	switch transform := transform.(type) {
 	case func([]byte):
 		c := make([]byte, len(input))
@@ -131,6 +174,45 @@ func (l mimeLib) transformMIME(arg0, arg1 ref.Val) ref.Val {
 	return types.NewErr("invalid transform: %T", transform)
 }
 
+// CachedTransform returns a transform that wraps the provided transform with
+// an in-memory cache keyed on the SHA-256 hash of the transform's input. The
+// wrapped transform must be one of the types accepted by File and MIME. The
+// returned transform may be registered with File or MIME in place of the
+// original. Results are retained for the lifetime of the returned transform,
+// so it should be constructed for each run. Error results are not cached.
+func CachedTransform(transform interface{}) func(io.Reader) ref.Val {
+	c := &transformCache{
+		transform: transform,
+		results:   make(map[[sha256.Size]byte]ref.Val),
+	}
+	return c.apply
+}
+
+type transformCache struct {
+	transform interface{}
+
+	mu      sync.Mutex
+	results map[[sha256.Size]byte]ref.Val
+}
+
+func (c *transformCache) apply(r io.Reader) ref.Val {
+	input, err := io.ReadAll(r)
+	if err != nil {
+		return types.NewErr("file: %v", err)
+	}
+	key := sha256.Sum256(input)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if v, ok := c.results[key]; ok {
+		return v
+	}
+	v := applyTransform(c.transform, input)
+	if !types.IsError(v) {
+		c.results[key] = v
+	}
+	return v
+}
+
 type transformReader struct {
 	r         io.Reader
 	transform func([]byte)
@@ -170,8 +252,49 @@ func (t transformReader) Read(p []byte) (int, error) {
 //
 //	[{"first": "1", "second": "2", "third": "3"}]
 func CSVHeader(r io.Reader) ref.Val {
+	return delimitedHeader(r, ',', "csv")
+}
+
+// TSVHeader provides a file transform that returns a <list<map<string,string>>>
+// from an io.Reader holding text/tab-separated-values data. It is the same as
+// CSVHeader except that fields are separated by tabs. It should be handed to
+// the File or MIME lib with
+//
+//	File(map[string]interface{}{
+//		"text/tab-separated-values; header=present": lib.TSVHeader,
+//	})
+//
+// or
+//
+//	MIME(map[string]interface{}{
+//		"text/tab-separated-values; header=present": lib.TSVHeader,
+//	})
+//
+// It will then be able to be used in a file or mime call.
+func TSVHeader(r io.Reader) ref.Val {
+	return delimitedHeader(r, '\t', "tsv")
+}
+
+// DelimitedHeader returns a file transform that is the same as CSVHeader
+// except that fields are separated by delim. The returned transform should
+// be handed to the File or MIME lib with a suitable MIME type, for example
+//
+//	File(map[string]interface{}{
+//		"text/x-semicolon-separated; header=present": lib.DelimitedHeader(';'),
+//	})
+//
+// The delimiter must be a valid rune that is not a quote, carriage return or
+// newline.
+func DelimitedHeader(delim rune) func(io.Reader) ref.Val {
+	return func(r io.Reader) ref.Val {
+		return delimitedHeader(r, delim, "delimited")
+	}
+}
+
+func delimitedHeader(r io.Reader, delim rune, name string) ref.Val {
 	var vals []map[string]string
 	cr := csv.NewReader(r)
+	cr.Comma = delim
 	var h []string
 	for i := 0; ; i++ {
 		rec, err := cr.Read()
@@ -179,7 +302,7 @@ func CSVHeader(r io.Reader) ref.Val {
 			if err == io.EOF {
 				break
 			}
-			return types.NewErr("csv: %v", err)
+			return types.NewErr("%s: %v", name, err)
 		}
 		if i == 0 {
 			h = rec
@@ -194,6 +317,25 @@ func CSVHeader(r io.Reader) ref.Val {
 	return types.NewDynamicList(types.DefaultTypeAdapter, vals)
 }
 
+// RawDataError is an error resulting from the processing of raw data. It
+// holds the data so that it can be recovered from the error.
+type RawDataError struct {
+	Err  error
+	Data []byte
+}
+
+func (e *RawDataError) Error() string { return fmt.Sprintf("%v: %s", e.Err, e.Data) }
+func (e *RawDataError) Unwrap() error { return e.Err }
+
+// repeatedError is an error that occurred n times.
+type repeatedError struct {
+	err error
+	n   int
+}
+
+func (e repeatedError) Error() string { return fmt.Sprintf("%v (%d occurrences)", e.err, e.n) }
+func (e repeatedError) Unwrap() error { return e.err }
+
 // CSVNoHeader provides a file transform that returns a <list<list<string>>> from an
 // io.Reader holding text/csv data. It should be handed to the File or MIME
 // lib with
@@ -222,9 +364,44 @@ func CSVHeader(r io.Reader) ref.Val {
 //
 //	[["first", "second", "third"], ["1", "2", "3"]]
 func CSVNoHeader(r io.Reader) ref.Val {
-	vals, err := csv.NewReader(r).ReadAll()
+	return delimitedNoHeader(r, ',', "csv")
+}
+
+// TSVNoHeader provides a file transform that returns a <list<list<string>>>
+// from an io.Reader holding text/tab-separated-values data. It is the same as
+// CSVNoHeader except that fields are separated by tabs. It should be handed
+// to the File or MIME lib with
+//
+//	File(map[string]interface{}{
+//		"text/tab-separated-values; header=absent": lib.TSVNoHeader,
+//	})
+//
+// or
+//
+//	MIME(map[string]interface{}{
+//		"text/tab-separated-values; header=absent": lib.TSVNoHeader,
+//	})
+//
+// It will then be able to be used in a file or mime call.
+func TSVNoHeader(r io.Reader) ref.Val {
+	return delimitedNoHeader(r, '\t', "tsv")
+}
+
+// DelimitedNoHeader returns a file transform that is the same as CSVNoHeader
+// except that fields are separated by delim. The delimiter must be a valid
+// rune that is not a quote, carriage return or newline.
+func DelimitedNoHeader(delim rune) func(io.Reader) ref.Val {
+	return func(r io.Reader) ref.Val {
+		return delimitedNoHeader(r, delim, "delimited")
+	}
+}
+
+func delimitedNoHeader(r io.Reader, delim rune, name string) ref.Val {
+	cr := csv.NewReader(r)
+	cr.Comma = delim
+	vals, err := cr.ReadAll()
 	if err != nil {
-		return types.NewErr("csv: %v", err)
+		return types.NewErr("%s: %v", name, err)
 	}
 	return types.NewDynamicList(types.DefaultTypeAdapter, vals)
 }
@@ -290,9 +467,137 @@ func CSVNoHeader(r io.Reader) ref.Val {
 //	    }
 //	]
 func NDJSON(r io.Reader) ref.Val {
+	return ndjson(r, false, false)
+}
+
+// NDJSONCompactErrors provides a file transform that returns a <list<dyn>>
+// from an io.Reader holding ND-JSON data in the same way as NDJSON, except
+// that repeated identical invalid messages are collapsed into a single CEL
+// error at the position of the first occurrence. If a message is repeated,
+// the error message includes the number of occurrences. It should be handed
+// to the File or MIME lib with
+//
+//	File(map[string]interface{}{
+//		"application/x-ndjson; errors=compact": lib.NDJSONCompactErrors,
+//	})
+//
+// or
+//
+//	MIME(map[string]interface{}{
+//		"application/x-ndjson; errors=compact": lib.NDJSONCompactErrors,
+//	})
+//
+// Example:
+//
+//	Given a file hello.ndjson:
+//	   {"message":"hello"}
+//	   {"message":"oops"
+//	   {"message":"world"}
+//	   {"message":"oops"
+//
+//	file('hello.ndjson', 'application/x-ndjson; errors=compact').map(e, try(e, "error.message"))
+//
+//	will return:
+//
+//	[
+//	    {
+//	        "message": "hello"
+//	    },
+//	    {
+//	        "error.message": "unexpected end of JSON input: {\"message\":\"oops\" (2 occurrences)"
+//	    },
+//	    {
+//	        "message": "world"
+//	    }
+//	]
+func NDJSONCompactErrors(r io.Reader) ref.Val {
+	return ndjson(r, true, false)
+}
+
+// NDJSONRawErrors provides a file transform that returns a <list<dyn>>
+// from an io.Reader holding ND-JSON data in the same way as NDJSON, except
+// that the errors for invalid messages hold the invalid message as a
+// RawDataError. This allows the message to be recovered for dead-letter
+// handling; the message is included as bytes in the "raw" field of the
+// object returned by try with a message field name. It should be handed to
+// the File or MIME lib with
+//
+//	File(map[string]interface{}{
+//		"application/x-ndjson; raw=present": lib.NDJSONRawErrors,
+//	})
+//
+// or
+//
+//	MIME(map[string]interface{}{
+//		"application/x-ndjson; raw=present": lib.NDJSONRawErrors,
+//	})
+//
+// Example:
+//
+//	Given a file hello.ndjson:
+//	   {"message":"hello"}
+//	   {"message":"oops"
+//	   {"message":"world"}
+//
+//	file('hello.ndjson', 'application/x-ndjson; raw=present').map(e, try(e, "error.message"))
+//
+//	will return:
+//
+//	[
+//	    {
+//	        "message": "hello"
+//	    },
+//	    {
+//	        "error.message": "unexpected end of JSON input: {\"message\":\"oops\"",
+//	        "raw": "eyJtZXNzYWdlIjoib29wcyI="
+//	    },
+//	    {
+//	        "message": "world"
+//	    }
+//	]
+func NDJSONRawErrors(r io.Reader) ref.Val {
+	return ndjson(r, false, true)
+}
+
+// NDJSONCompactRawErrors provides a file transform that returns a
+// <list<dyn>> from an io.Reader holding ND-JSON data, compacting repeated
+// invalid messages as described for NDJSONCompactErrors and holding the
+// invalid message in the errors as described for NDJSONRawErrors. It should
+// be handed to the File or MIME lib with
+//
+//	File(map[string]interface{}{
+//		"application/x-ndjson; errors=compact; raw=present": lib.NDJSONCompactRawErrors,
+//	})
+//
+// or
+//
+//	MIME(map[string]interface{}{
+//		"application/x-ndjson; errors=compact; raw=present": lib.NDJSONCompactRawErrors,
+//	})
+func NDJSONCompactRawErrors(r io.Reader) ref.Val {
+	return ndjson(r, true, true)
+}
+
+// ndjson returns the values held in the ND-JSON stream in r. If compact is
+// true, repeated invalid messages are collapsed and if raw is true, errors
+// for invalid messages hold the message as a RawDataError.
+func ndjson(r io.Reader, compact, raw bool) ref.Val {
 	// This is not real ndjson since it doesn't have the
 	// stupid requirement for newline line termination.
-	var vals []interface{}
+	var (
+		vals []interface{}
+
+		// seen and counts are used for compacting errors.
+		// seen holds the index into vals of the first occurrence
+		// of each error message and counts holds the number of
+		// occurrences of the message at each index.
+		seen   map[string]int
+		counts map[int]int
+	)
+	if compact {
+		seen = make(map[string]int)
+		counts = make(map[int]int)
+	}
 	sc := bufio.NewScanner(r)
 	for sc.Scan() {
 		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
@@ -301,7 +606,21 @@ func NDJSON(r io.Reader) ref.Val {
 		var v interface{}
 		err := json.Unmarshal(sc.Bytes(), &v)
 		if err != nil {
-			vals = append(vals, types.NewErr("%v: %s", err, sc.Bytes()))
+			if raw {
+				err = &RawDataError{Err: err, Data: append([]byte(nil), sc.Bytes()...)}
+			} else {
+				err = fmt.Errorf("%v: %s", err, sc.Bytes())
+			}
+			if compact {
+				msg := err.Error()
+				if i, ok := seen[msg]; ok {
+					counts[i]++
+					continue
+				}
+				seen[msg] = len(vals)
+				counts[len(vals)] = 1
+			}
+			vals = append(vals, types.WrapErr(err))
 			continue
 		}
 		vals = append(vals, v)
@@ -310,9 +629,243 @@ func NDJSON(r io.Reader) ref.Val {
 	if err != nil {
 		return types.NewErr("ndjson: %v", err)
 	}
+	for i, n := range counts {
+		if n > 1 {
+			vals[i] = types.WrapErr(repeatedError{err: vals[i].(*types.Err).Unwrap(), n: n})
+		}
+	}
 	return types.NewDynamicList(types.DefaultTypeAdapter, vals)
 }
 
+// Lines provides a file transform that returns a <list<string>> from an
+// io.Reader holding text data. The text is split on newlines and the line
+// endings, including any carriage return before the newline, are removed.
+// Empty lines are retained. It should be handed to the File or MIME lib with
+//
+//	File(map[string]interface{}{
+//		"text/lines": lib.Lines,
+//	})
+//
+// or
+//
+//	MIME(map[string]interface{}{
+//		"text/lines": lib.Lines,
+//	})
+//
+// It will then be able to be used in a file or mime call.
+//
+// Example:
+//
+//	Given a file app.log:
+//	   starting
+//
+//	   stopping
+//
+//	file('app.log', 'text/lines')
+//
+//	will return:
+//
+//	[
+//	    "starting",
+//	    "",
+//	    "stopping"
+//	]
+func Lines(r io.Reader) ref.Val {
+	return lines(r, true)
+}
+
+// LinesNonEmpty provides a file transform that returns a <list<string>> from
+// an io.Reader holding text data in the same way as Lines, except that empty
+// lines are omitted. It should be handed to the File or MIME lib with
+//
+//	File(map[string]interface{}{
+//		"text/lines; empty=absent": lib.LinesNonEmpty,
+//	})
+//
+// or
+//
+//	MIME(map[string]interface{}{
+//		"text/lines; empty=absent": lib.LinesNonEmpty,
+//	})
+//
+// It will then be able to be used in a file or mime call.
+func LinesNonEmpty(r io.Reader) ref.Val {
+	return lines(r, false)
+}
+
+// maxLineLength is the maximum length of a line accepted by lines.
+const maxLineLength = 64 << 20
+
+func lines(r io.Reader, keepEmpty bool) ref.Val {
+	var vals []string
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, maxLineLength)
+	for sc.Scan() {
+		if !keepEmpty && len(sc.Bytes()) == 0 {
+			continue
+		}
+		vals = append(vals, sc.Text())
+	}
+	err := sc.Err()
+	if err != nil {
+		return types.NewErr("lines: %v", err)
+	}
+	return types.NewStringList(types.DefaultTypeAdapter, vals)
+}
+
+// GzipEncode provides a file transform that returns an io.Reader holding the
+// gzip compressed data read from r. It is the encoding counterpart of a gzip
+// decompression transform and should be handed to the File or MIME lib with
+//
+//	File(map[string]interface{}{
+//		"application/gzip; dir=encode": lib.GzipEncode,
+//	})
+//
+// or
+//
+//	MIME(map[string]interface{}{
+//		"application/gzip; dir=encode": lib.GzipEncode,
+//	})
+//
+// It will then be able to be used in a file or mime call.
+//
+// Example:
+//
+//	b"hello world!".mime("application/gzip; dir=encode")
+//
+//	will return the gzip compressed bytes of "hello world!".
+func GzipEncode(r io.Reader) (io.Reader, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err := io.Copy(w, r)
+	if err != nil {
+		return nil, err
+	}
+	err = w.Close()
+	if err != nil {
+		return nil, err
+	}
+	return &buf, nil
+}
+
+// ZstdEncode provides a file transform that returns an io.Reader holding the
+// zstd compressed data read from r. It is the encoding counterpart of
+// ZstdDecode and should be handed to the File or MIME lib with
+//
+//	File(map[string]interface{}{
+//		"application/zstd; dir=encode": lib.ZstdEncode,
+//	})
+//
+// or
+//
+//	MIME(map[string]interface{}{
+//		"application/zstd; dir=encode": lib.ZstdEncode,
+//	})
+//
+// It will then be able to be used in a file or mime call.
+//
+// Example:
+//
+//	b"hello world!".mime("application/zstd; dir=encode")
+//
+//	will return the zstd compressed bytes of "hello world!".
+func ZstdEncode(r io.Reader) (io.Reader, error) {
+	var buf bytes.Buffer
+	w, err := zstd.NewWriter(&buf)
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.Copy(w, r)
+	if err != nil {
+		w.Close()
+		return nil, err
+	}
+	err = w.Close()
+	if err != nil {
+		return nil, err
+	}
+	return &buf, nil
+}
+
+// ZstdDecode provides a file transform that returns an io.Reader holding the
+// decompressed data of the zstd compressed data read from r. It should be
+// handed to the File or MIME lib with
+//
+//	File(map[string]interface{}{
+//		"application/zstd": lib.ZstdDecode,
+//	})
+//
+// or
+//
+//	MIME(map[string]interface{}{
+//		"application/zstd": lib.ZstdDecode,
+//	})
+//
+// It will then be able to be used in a file or mime call.
+//
+// Example:
+//
+//	b"hello world!".mime("application/zstd; dir=encode").mime("application/zstd")
+//
+//	will return the bytes of "hello world!".
+func ZstdDecode(r io.Reader) (io.Reader, error) {
+	// The decoder is closed here rather than by the caller, so the data
+	// is decompressed eagerly.
+	d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	defer d.Close()
+	var buf bytes.Buffer
+	_, err = io.Copy(&buf, d)
+	if err != nil {
+		return nil, err
+	}
+	return &buf, nil
+}
+
+// CBOR provides a file transform that returns a <dyn> from an io.Reader
+// holding a single CBOR (RFC 8949) data item. It should be handed to the
+// File or MIME lib with
+//
+//	File(map[string]interface{}{
+//		"application/cbor": lib.CBOR,
+//	})
+//
+// or
+//
+//	MIME(map[string]interface{}{
+//		"application/cbor": lib.CBOR,
+//	})
+//
+// It will then be able to be used in a file or mime call.
+//
+// CBOR maps are returned as <map<string,dyn>>, with integer and boolean keys
+// rendered as strings; other key types result in an error. Byte strings are
+// returned as <bytes>, text strings as <string>, and tagged standard and epoch
+// date/time items as <timestamp>. Other tags are ignored.
+//
+// Example:
+//
+//	b"\xa2\x61a\x01\x61b\x82\x02\x43\x01\x02\x03".mime("application/cbor")
+//
+//	will return:
+//
+//	{
+//	    "a": 1,
+//	    "b": [
+//	        2,
+//	        "AQID"
+//	    ]
+//	}
+func CBOR(r io.Reader) ref.Val {
+	v, err := decodeCBOR(r)
+	if err != nil {
+		return types.NewErr("cbor: %v", err)
+	}
+	return types.DefaultTypeAdapter.NativeToValue(v)
+}
+
 // Zip provides a file transform that returns a <map<dyn>> from an io.Reader
 // holding a zip archive data. It should be handed to the File or MIME lib with
 //
@@ -364,8 +917,83 @@ func NDJSON(r io.Reader) ref.Val {
 //	    ]
 //	}
 //
-// Note that the entire contents of the zip file is expanded into memory.
+// Note that the entire contents of the zip file is expanded into memory. For
+// large archives, ZipMetadata and the File lib's zip_file function may be used
+// to list the archive's entries and then read only the required entry.
 func Zip(r io.Reader) ref.Val {
+	return readZip(r, allZipData)
+}
+
+// ZipMetadata provides a file transform that returns a <map<dyn>> from an
+// io.Reader holding a zip archive data. It is the same as Zip except that
+// the returned File elements do not include the Data field, so the contents
+// of the archive's entries are not read into memory. It should be handed to
+// the File or MIME lib with
+//
+//	File(map[string]interface{}{
+//		"application/zip; data=absent": lib.ZipMetadata,
+//	})
+//
+// or
+//
+//	MIME(map[string]interface{}{
+//		"application/zip; data=absent": lib.ZipMetadata,
+//	})
+//
+// It will then be able to be used in a file or mime call.
+func ZipMetadata(r io.Reader) ref.Val {
+	return readZip(r, noZipData)
+}
+
+// ZipGlob returns a file transform that returns a <map<dyn>> from an
+// io.Reader holding a zip archive data. It is the same as Zip except that
+// the Data field is only included for entries with names matching the
+// provided glob pattern. The pattern syntax is that of path.Match. This
+// allows a single entry of a large archive to be read without holding the
+// contents of all the entries in memory. ZipGlob is a parameterised
+// transform and should be handed to the File or MIME lib with
+//
+//	File(map[string]interface{}{
+//		"application/zip; glob": lib.ZipGlob,
+//	})
+//
+// or
+//
+//	MIME(map[string]interface{}{
+//		"application/zip; glob": lib.ZipGlob,
+//	})
+//
+// It will then be able to be used in a file or mime call with the pattern
+// given as the glob parameter. Patterns holding characters that are not
+// valid in a MIME parameter token, such as /, ? and [, must be quoted. An
+// error is returned if the pattern is malformed.
+//
+// Example:
+//
+//	file('archive.zip', 'application/zip; glob=*.txt')
+//	file('archive.zip', 'application/zip; glob="dir/?.txt"')
+func ZipGlob(pattern string) (func(io.Reader) ref.Val, error) {
+	_, err := path.Match(pattern, "")
+	if err != nil {
+		return nil, err
+	}
+	match := func(name string) bool {
+		ok, _ := path.Match(pattern, name)
+		return ok
+	}
+	return func(r io.Reader) ref.Val {
+		return readZip(r, match)
+	}, nil
+}
+
+// allZipData and noZipData are readZip data selectors that include the
+// data of all and of no entries respectively.
+func allZipData(string) bool { return true }
+func noZipData(string) bool  { return false }
+
+// readZip returns the expansion of the zip archive held in r, including
+// the data of entries with names for which withData returns true.
+func readZip(r io.Reader, withData func(name string) bool) ref.Val {
 	var z *zip.Reader
 	switch r := r.(type) {
 	case *os.File:
@@ -389,28 +1017,15 @@ func Zip(r io.Reader) ref.Val {
 			return types.NewErr("zip: %s", err)
 		}
 	}
-	return expandZip(z)
+	return expandZip(z, withData)
 }
 
-func expandZip(z *zip.Reader) ref.Val {
+func expandZip(z *zip.Reader, withData func(name string) bool) ref.Val {
 	var files []map[string]interface{}
 	for _, f := range z.File {
-		rc, err := f.Open()
-		if err != nil {
-			return types.NewErr("zip: %s", err)
-		}
-		var buf bytes.Buffer
-		_, err = io.Copy(&buf, rc)
-		if err != nil {
-			return types.NewErr("zip: %s", err)
-		}
-		err = rc.Close()
-		if err != nil {
-			return types.NewErr("zip: %s", err)
-		}
 		fh := f.FileHeader
 		fi := fh.FileInfo()
-		files = append(files, map[string]interface{}{
+		file := map[string]interface{}{
 			"Name":     fh.Name,
 			"Comment":  fh.Comment,
 			"IsDir":    fi.IsDir(),
@@ -419,11 +1034,124 @@ func expandZip(z *zip.Reader) ref.Val {
 			"Modified": fh.Modified,
 			"CRC32":    fh.CRC32,
 			"Extra":    fh.Extra,
-			"Data":     buf.Bytes(),
-		})
+		}
+		if withData(fh.Name) {
+			data, err := readZipFile(f)
+			if err != nil {
+				return types.NewErr("zip: %s", err)
+			}
+			file["Data"] = data
+		}
+		files = append(files, file)
 	}
 	return types.DefaultTypeAdapter.NativeToValue(map[string]interface{}{
 		"File":    files,
 		"Comment": z.Comment,
 	})
 }
+
+// Tar provides a file transform that returns a <map<dyn>> from an io.Reader
+// holding tar archive data, optionally gzip compressed. It should be handed
+// to the File or MIME lib with
+//
+//	File(map[string]interface{}{
+//		"application/x-tar": lib.Tar,
+//	})
+//
+// or
+//
+//	MIME(map[string]interface{}{
+//		"application/x-tar": lib.Tar,
+//	})
+//
+// It will then be able to be used in a file or mime call.
+//
+// The returned map has the same shape as the map returned by Zip, with the
+// File elements reflecting the structure of the Go tar.Header struct.
+//
+// Example:
+//
+//	file('hello.tar.gz', 'application/x-tar')
+//
+//	might return:
+//
+//	{
+//	    "File": [
+//	        {
+//	            "Data": "",
+//	            "IsDir": true,
+//	            "Mode": 493,
+//	            "ModTime": "2022-04-14T21:09:46+09:30",
+//	            "Name": "subdir/",
+//	            "Size": 0
+//	        },
+//	        {
+//	            "Data": "aGVsbG8gd29ybGQhCg==",
+//	            "IsDir": false,
+//	            "Mode": 420,
+//	            "ModTime": "2022-04-14T21:09:32+09:30",
+//	            "Name": "subdir/a.txt",
+//	            "Size": 13
+//	        }
+//	    ]
+//	}
+//
+// Note that the entire contents of the tar archive is expanded into memory.
+func Tar(r io.Reader) ref.Val {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(2)
+	if err != nil && err != io.EOF {
+		return types.NewErr("tar: %s", err)
+	}
+	r = br
+	if bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return types.NewErr("tar: %s", err)
+		}
+		defer gr.Close()
+		r = gr
+	}
+	files := []map[string]interface{}{}
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return types.NewErr("tar: %s", err)
+		}
+		fi := h.FileInfo()
+		var buf bytes.Buffer
+		_, err = io.Copy(&buf, tr)
+		if err != nil {
+			return types.NewErr("tar: %s", err)
+		}
+		files = append(files, map[string]interface{}{
+			"Name":    h.Name,
+			"Size":    h.Size,
+			"Mode":    h.Mode,
+			"ModTime": h.ModTime,
+			"IsDir":   fi.IsDir(),
+			"Data":    buf.Bytes(),
+		})
+	}
+	return types.DefaultTypeAdapter.NativeToValue(map[string]interface{}{
+		"File": files,
+	})
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	_, err = io.Copy(&buf, rc)
+	if err != nil {
+		rc.Close()
+		return nil, err
+	}
+	return buf.Bytes(), rc.Close()
+}
@@ -0,0 +1,370 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package lib
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker/decls"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/interpreter/functions"
+	expr "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+)
+
+// Number returns a cel.EnvOption to configure extended functions for
+// formatting and parsing numbers.
+//
+// # To Base
+//
+// Returns a string representation of an int in the given base. The base
+// must be between 2 and 36 inclusive, and digits above 9 are represented
+// by lower case letters:
+//
+//	to_base(<int>, <int>) -> <string>
+//	<int>.to_base(<int>) -> <string>
+//
+// Examples:
+//
+//	255.to_base(2)   // return "11111111"
+//	255.to_base(16)  // return "ff"
+//	-255.to_base(8)  // return "-377"
+//
+// # From Base
+//
+// Returns an int parsed from a string representation in the given base. The
+// base must be between 2 and 36 inclusive, and letter digits may be upper or
+// lower case:
+//
+//	from_base(<string>, <int>) -> <int>
+//	<string>.from_base(<int>) -> <int>
+//
+// Examples:
+//
+//	"11111111".from_base(2)  // return 255
+//	"FF".from_base(16)       // return 255
+//	"zz".from_base(36)       // return 1295
+//
+// # Parse Number Locale
+//
+// Returns a double parsed from a string using the provided decimal and group
+// separators. Group separators are removed and the decimal separator is
+// replaced with a "." before parsing. The decimal separator must not be
+// empty and must differ from the group separator:
+//
+//	parse_number_locale(<string>, <string>, <string>) -> <double>
+//	<string>.parse_number_locale(<string>, <string>) -> <double>
+//
+// Examples:
+//
+//	"1.234,56".parse_number_locale(",", ".")  // return 1234.56
+//	"1,234.56".parse_number_locale(".", ",")  // return 1234.56
+//	"1 234,56".parse_number_locale(",", " ")  // return 1234.56
+//
+// # As Number
+//
+// Returns a double from an int, uint, double or a string holding a decimal
+// number. Surrounding white space in strings is ignored. It is an error for
+// the value to be any other type, or a string that is not a finite number:
+//
+//	as_number(<dyn>) -> <double>
+//	<dyn>.as_number() -> <double>
+//
+// Examples:
+//
+//	as_number(1)         // return 1.0
+//	as_number(1.5)       // return 1.5
+//	as_number(" 1e3 ")   // return 1000.0
+//	as_number("one")     // return error
+//
+// # As Int
+//
+// Returns an int from an int, uint, double or a string holding a decimal
+// number. It is an error for the value to be any other type, a string that
+// is not a number, or a number that is not integral or does not fit in an
+// int:
+//
+//	as_int(<dyn>) -> <int>
+//	<dyn>.as_int() -> <int>
+//
+// Examples:
+//
+//	as_int(1u)      // return 1
+//	as_int(2.0)     // return 2
+//	as_int("42")    // return 42
+//	as_int(2.5)     // return error
+func Number() cel.EnvOption {
+	return cel.Lib(numberLib{})
+}
+
+type numberLib struct{}
+
+func (numberLib) CompileOptions() []cel.EnvOption {
+	return []cel.EnvOption{
+		cel.Declarations(
+			decls.NewFunction("to_base",
+				decls.NewOverload(
+					"to_base_int_int",
+					[]*expr.Type{decls.Int, decls.Int},
+					decls.String,
+				),
+				decls.NewInstanceOverload(
+					"int_to_base_int",
+					[]*expr.Type{decls.Int, decls.Int},
+					decls.String,
+				),
+			),
+			decls.NewFunction("from_base",
+				decls.NewOverload(
+					"from_base_string_int",
+					[]*expr.Type{decls.String, decls.Int},
+					decls.Int,
+				),
+				decls.NewInstanceOverload(
+					"string_from_base_int",
+					[]*expr.Type{decls.String, decls.Int},
+					decls.Int,
+				),
+			),
+			decls.NewFunction("parse_number_locale",
+				decls.NewOverload(
+					"parse_number_locale_string_string_string",
+					[]*expr.Type{decls.String, decls.String, decls.String},
+					decls.Double,
+				),
+				decls.NewInstanceOverload(
+					"string_parse_number_locale_string_string",
+					[]*expr.Type{decls.String, decls.String, decls.String},
+					decls.Double,
+				),
+			),
+			decls.NewFunction("as_number",
+				decls.NewOverload(
+					"as_number_dyn",
+					[]*expr.Type{decls.Dyn},
+					decls.Double,
+				),
+				decls.NewInstanceOverload(
+					"dyn_as_number",
+					[]*expr.Type{decls.Dyn},
+					decls.Double,
+				),
+			),
+			decls.NewFunction("as_int",
+				decls.NewOverload(
+					"as_int_dyn",
+					[]*expr.Type{decls.Dyn},
+					decls.Int,
+				),
+				decls.NewInstanceOverload(
+					"dyn_as_int",
+					[]*expr.Type{decls.Dyn},
+					decls.Int,
+				),
+			),
+		),
+	}
+}
+
+func (numberLib) ProgramOptions() []cel.ProgramOption {
+	return []cel.ProgramOption{
+		cel.Functions(
+			&functions.Overload{
+				Operator: "to_base_int_int",
+				Binary:   toBase,
+			},
+			&functions.Overload{
+				Operator: "int_to_base_int",
+				Binary:   toBase,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "from_base_string_int",
+				Binary:   fromBase,
+			},
+			&functions.Overload{
+				Operator: "string_from_base_int",
+				Binary:   fromBase,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "parse_number_locale_string_string_string",
+				Function: parseNumberLocale,
+			},
+			&functions.Overload{
+				Operator: "string_parse_number_locale_string_string",
+				Function: parseNumberLocale,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "as_number_dyn",
+				Unary:    asNumber,
+			},
+			&functions.Overload{
+				Operator: "dyn_as_number",
+				Unary:    asNumber,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "as_int_dyn",
+				Unary:    asInt,
+			},
+			&functions.Overload{
+				Operator: "dyn_as_int",
+				Unary:    asInt,
+			},
+		),
+	}
+}
+
+func toBase(arg0, arg1 ref.Val) ref.Val {
+	n, ok := arg0.(types.Int)
+	if !ok {
+		return types.ValOrErr(n, "no such overload for to_base: %s", arg0.Type())
+	}
+	base, ok := arg1.(types.Int)
+	if !ok {
+		return types.ValOrErr(base, "no such overload for to_base base: %s", arg1.Type())
+	}
+	if base < 2 || 36 < base {
+		return types.NewErr("to_base: invalid base: %d", base)
+	}
+	return types.String(strconv.FormatInt(int64(n), int(base)))
+}
+
+func fromBase(arg0, arg1 ref.Val) ref.Val {
+	s, ok := arg0.(types.String)
+	if !ok {
+		return types.ValOrErr(s, "no such overload for from_base: %s", arg0.Type())
+	}
+	base, ok := arg1.(types.Int)
+	if !ok {
+		return types.ValOrErr(base, "no such overload for from_base base: %s", arg1.Type())
+	}
+	if base < 2 || 36 < base {
+		return types.NewErr("from_base: invalid base: %d", base)
+	}
+	n, err := strconv.ParseInt(string(s), int(base), 64)
+	if err != nil {
+		if err, ok := err.(*strconv.NumError); ok {
+			return types.NewErr("from_base: invalid base %d number %q: %v", base, s, err.Err)
+		}
+		return types.NewErr("from_base: %v", err)
+	}
+	return types.Int(n)
+}
+
+func parseNumberLocale(args ...ref.Val) ref.Val {
+	if len(args) != 3 {
+		return types.NewErr("no such overload for parse_number_locale")
+	}
+	s, ok := args[0].(types.String)
+	if !ok {
+		return types.ValOrErr(s, "no such overload for parse_number_locale: %s", args[0].Type())
+	}
+	decimal, ok := args[1].(types.String)
+	if !ok {
+		return types.ValOrErr(decimal, "no such overload for parse_number_locale decimal separator: %s", args[1].Type())
+	}
+	group, ok := args[2].(types.String)
+	if !ok {
+		return types.ValOrErr(group, "no such overload for parse_number_locale group separator: %s", args[2].Type())
+	}
+	if decimal == "" {
+		return types.NewErr("parse_number_locale: empty decimal separator")
+	}
+	if decimal == group {
+		return types.NewErr("parse_number_locale: decimal and group separators are the same: %q", decimal)
+	}
+	n := strings.TrimSpace(string(s))
+	if group != "" {
+		n = strings.ReplaceAll(n, string(group), "")
+	}
+	n = strings.ReplaceAll(n, string(decimal), ".")
+	f, err := strconv.ParseFloat(n, 64)
+	if err != nil {
+		return types.NewErr("parse_number_locale: invalid number %q", s)
+	}
+	return types.Double(f)
+}
+
+func asNumber(arg ref.Val) ref.Val {
+	switch n := arg.(type) {
+	case types.Int:
+		return types.Double(n)
+	case types.Uint:
+		return types.Double(n)
+	case types.Double:
+		return n
+	case types.String:
+		f, err := strconv.ParseFloat(strings.TrimSpace(string(n)), 64)
+		if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+			return types.NewErr("as_number: invalid number %q", n)
+		}
+		return types.Double(f)
+	default:
+		return types.ValOrErr(arg, "no such overload for as_number: %s", arg.Type())
+	}
+}
+
+func asInt(arg ref.Val) ref.Val {
+	switch n := arg.(type) {
+	case types.Int:
+		return n
+	case types.Uint:
+		if n > math.MaxInt64 {
+			return types.NewErr("as_int: %d overflows int", n)
+		}
+		return types.Int(n)
+	case types.Double:
+		return doubleToInt(float64(n), arg)
+	case types.String:
+		s := strings.TrimSpace(string(n))
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err == nil {
+			return types.Int(i)
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil || math.IsNaN(f) {
+			return types.NewErr("as_int: invalid number %q", n)
+		}
+		return doubleToInt(f, arg)
+	default:
+		return types.ValOrErr(arg, "no such overload for as_int: %s", arg.Type())
+	}
+}
+
+// doubleToInt returns f as an int if it is integral and within the range
+// of an int. The original value is used for error messages.
+func doubleToInt(f float64, orig ref.Val) ref.Val {
+	if f != math.Trunc(f) {
+		return types.NewErr("as_int: %v is not integral", orig)
+	}
+	// float64(math.MaxInt64) rounds up to 2^63, so the upper bound is exclusive.
+	if f < math.MinInt64 || f >= math.MaxInt64 {
+		return types.NewErr("as_int: %v overflows int", orig)
+	}
+	return types.Int(f)
+}
@@ -18,13 +18,20 @@
 package lib
 
 import (
+	"container/list"
 	"regexp"
+	"sync"
 
 	"github.com/google/cel-go/cel"
 	"github.com/google/cel-go/checker/decls"
+	"github.com/google/cel-go/common"
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/common/overloads"
 	"github.com/google/cel-go/common/types"
 	"github.com/google/cel-go/common/types/ref"
 	"github.com/google/cel-go/interpreter/functions"
+	"github.com/google/cel-go/parser"
 	expr "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
 )
 
@@ -41,6 +48,8 @@ import (
 //	map[string]*regexp.Regexp{
 //	    "foo":     regexp.MustCompile("foo(.)"),
 //	    "foo_rep": regexp.MustCompile("(f)oo([ld])"),
+//	    "date":    regexp.MustCompile(`(?P<year>\d{4})-(?P<month>\d{2})`),
+//	    "comma":   regexp.MustCompile(`\s*,\s*`),
 //	}
 //
 // # RE Match
@@ -102,6 +111,20 @@ import (
 //
 //	'food fool'.re_find_all_submatch('foo')  // return [["food", "d"], ["fool", "l"]]
 //
+// # RE Find Named
+//
+// Returns a map of the named pattern's named capture groups to the strings or
+// bytes matched by the groups. Unnamed groups are omitted and an empty map is
+// returned if there is no match:
+//
+//	<bytes>.re_find_named(<string>) -> <map<string,bytes>>
+//	<string>.re_find_named(<string>) -> <map<string,string>>
+//
+// Examples:
+//
+//	'2023-06'.re_find_named('date')   // return {"month": "06", "year": "2023"}
+//	b'2023-06'.re_find_named('date')  // return {"month": "MDY=", "year": "MjAyMw=="}
+//
 // # RE Replace All
 //
 // Returns a strings or bytes applying a replacement to all matches of the named
@@ -114,6 +137,79 @@ import (
 //
 //	'food fool'.re_replace_all('foo_rep', '${1}u${2}')    // return "fud ful"
 //	b'food fool'.re_replace_all('foo_rep', b'${1}u${2}')  // return "ZnVkIGZ1bA=="
+//
+// # RE Replace All Func
+//
+// Returns a string applying a replacement computed by an expression to all
+// matches of the named pattern. The match is bound to the identifier given
+// as the second parameter and the expression must evaluate to a string:
+//
+//	<string>.re_replace_all_func(<string>, <ident>, <expr>) -> <string>
+//
+// Examples:
+//
+//	'food fool'.re_replace_all_func('foo', m, m.to_upper())  // return "FOOD FOOL"
+//
+// # RE Expand
+//
+// Returns a string from expanding a template with the submatches of the
+// first match of the named pattern. Submatches are referenced in the template
+// with the $name or ${name} syntax, where name is a group name or index.
+// An empty string is returned if there is no match:
+//
+//	<string>.re_expand(<string>, <string>) -> <string>
+//
+// Examples:
+//
+//	'2023-06'.re_expand('date', '${month}/${year}')  // return "06/2023"
+//
+// # RE Split
+//
+// Returns a list of strings from splitting a string on matches of the named
+// pattern. If a count is provided, at most that number of substrings will be
+// returned with the last holding the unsplit remainder. A negative count
+// returns all substrings:
+//
+//	<string>.re_split(<string>) -> <list<string>>
+//	<string>.re_split(<string>, <int>) -> <list<string>>
+//
+// Examples:
+//
+//	'a , b,c'.re_split('comma')     // return ["a", "b", "c"]
+//	'a , b,c'.re_split('comma', 2)  // return ["a", "b,c"]
+//
+// # Inline Patterns
+//
+// Each of the functions above has a companion function with a _pattern
+// suffix that takes a Go regular expression instead of a pattern name. The
+// expression is compiled when the function is called, and recently used
+// expressions are cached to avoid recompilation when a pattern is used
+// repeatedly. Named patterns should be preferred where the expression is
+// known in advance:
+//
+//	<bytes>.re_match_pattern(<string>) -> <bool>
+//	<string>.re_match_pattern(<string>) -> <bool>
+//	<bytes>.re_find_pattern(<string>) -> <bytes>
+//	<string>.re_find_pattern(<string>) -> <string>
+//	<bytes>.re_find_all_pattern(<string>) -> <list<bytes>>
+//	<string>.re_find_all_pattern(<string>) -> <list<string>>
+//	<bytes>.re_find_submatch_pattern(<string>) -> <list<bytes>>
+//	<string>.re_find_submatch_pattern(<string>) -> <list<string>>
+//	<bytes>.re_find_all_submatch_pattern(<string>) -> <list<list<bytes>>>
+//	<string>.re_find_all_submatch_pattern(<string>) -> <list<list<string>>>
+//	<bytes>.re_find_named_pattern(<string>) -> <map<string,bytes>>
+//	<string>.re_find_named_pattern(<string>) -> <map<string,string>>
+//	<bytes>.re_replace_all_pattern(<string>, <bytes>) -> <bytes>
+//	<string>.re_replace_all_pattern(<string>, <string>) -> <string>
+//	<string>.re_replace_all_func_pattern(<string>, <ident>, <expr>) -> <string>
+//	<string>.re_expand_pattern(<string>, <string>) -> <string>
+//	<string>.re_split_pattern(<string>) -> <list<string>>
+//	<string>.re_split_pattern(<string>, <int>) -> <list<string>>
+//
+// Examples:
+//
+//	'food'.re_match_pattern('^foo')                              // return true
+//	'food fool'.re_replace_all_pattern('(f)oo([ld])', '${1}u${2}')  // return "fud ful"
 func Regexp(patterns map[string]*regexp.Regexp) cel.EnvOption {
 	return cel.Lib(regexpLib(patterns))
 }
@@ -122,6 +218,10 @@ type regexpLib map[string]*regexp.Regexp
 
 func (l regexpLib) CompileOptions() []cel.EnvOption {
 	return []cel.EnvOption{
+		cel.Macros(
+			parser.NewReceiverMacro("re_replace_all_func", 3, makeReplaceAllFunc("@re_match_parts")),
+			parser.NewReceiverMacro("re_replace_all_func_pattern", 3, makeReplaceAllFunc("@re_match_parts_pattern")),
+		),
 		cel.Declarations(
 			decls.NewFunction("re_match",
 				decls.NewInstanceOverload(
@@ -162,6 +262,14 @@ func (l regexpLib) CompileOptions() []cel.EnvOption {
 					[]string{"V"},
 				),
 			),
+			decls.NewFunction("re_find_named",
+				decls.NewParameterizedInstanceOverload(
+					"typeV_re_find_named_string",
+					[]*expr.Type{typeV, decls.String},
+					decls.NewMapType(decls.String, typeV),
+					[]string{"V"},
+				),
+			),
 			decls.NewFunction("re_replace_all",
 				decls.NewParameterizedInstanceOverload(
 					"typeV_re_replace_all_string_dyn",
@@ -170,6 +278,113 @@ func (l regexpLib) CompileOptions() []cel.EnvOption {
 					[]string{"V"},
 				),
 			),
+			decls.NewFunction("re_expand",
+				decls.NewInstanceOverload(
+					"string_re_expand_string_string",
+					[]*expr.Type{decls.String, decls.String, decls.String},
+					decls.String,
+				),
+			),
+			decls.NewFunction("re_split",
+				decls.NewInstanceOverload(
+					"string_re_split_string",
+					[]*expr.Type{decls.String, decls.String},
+					decls.NewListType(decls.String),
+				),
+				decls.NewInstanceOverload(
+					"string_re_split_string_int",
+					[]*expr.Type{decls.String, decls.String, decls.Int},
+					decls.NewListType(decls.String),
+				),
+			),
+			decls.NewFunction("@re_match_parts",
+				decls.NewOverload(
+					"@re_match_parts_string_string",
+					[]*expr.Type{decls.String, decls.String},
+					decls.NewListType(decls.NewListType(decls.String)),
+				),
+			),
+			decls.NewFunction("re_match_pattern",
+				decls.NewInstanceOverload(
+					"typeV_re_match_pattern_string",
+					[]*expr.Type{decls.Dyn, decls.String},
+					decls.Bool,
+				),
+			),
+			decls.NewFunction("re_find_pattern",
+				decls.NewParameterizedInstanceOverload(
+					"typeV_re_find_pattern_string",
+					[]*expr.Type{typeV, decls.String},
+					typeV,
+					[]string{"V"},
+				),
+			),
+			decls.NewFunction("re_find_all_pattern",
+				decls.NewParameterizedInstanceOverload(
+					"typeV_re_find_all_pattern_string",
+					[]*expr.Type{typeV, decls.String},
+					decls.NewListType(typeV),
+					[]string{"V"},
+				),
+			),
+			decls.NewFunction("re_find_submatch_pattern",
+				decls.NewParameterizedInstanceOverload(
+					"typeV_re_find_submatch_pattern_string",
+					[]*expr.Type{typeV, decls.String},
+					decls.NewListType(typeV),
+					[]string{"V"},
+				),
+			),
+			decls.NewFunction("re_find_all_submatch_pattern",
+				decls.NewParameterizedInstanceOverload(
+					"typeV_re_find_all_submatch_pattern_string",
+					[]*expr.Type{typeV, decls.String},
+					decls.NewListType(decls.NewListType(typeV)),
+					[]string{"V"},
+				),
+			),
+			decls.NewFunction("re_find_named_pattern",
+				decls.NewParameterizedInstanceOverload(
+					"typeV_re_find_named_pattern_string",
+					[]*expr.Type{typeV, decls.String},
+					decls.NewMapType(decls.String, typeV),
+					[]string{"V"},
+				),
+			),
+			decls.NewFunction("re_replace_all_pattern",
+				decls.NewParameterizedInstanceOverload(
+					"typeV_re_replace_all_pattern_string_dyn",
+					[]*expr.Type{typeV, decls.String, typeV},
+					typeV,
+					[]string{"V"},
+				),
+			),
+			decls.NewFunction("re_expand_pattern",
+				decls.NewInstanceOverload(
+					"string_re_expand_pattern_string_string",
+					[]*expr.Type{decls.String, decls.String, decls.String},
+					decls.String,
+				),
+			),
+			decls.NewFunction("re_split_pattern",
+				decls.NewInstanceOverload(
+					"string_re_split_pattern_string",
+					[]*expr.Type{decls.String, decls.String},
+					decls.NewListType(decls.String),
+				),
+				decls.NewInstanceOverload(
+					"string_re_split_pattern_string_int",
+					[]*expr.Type{decls.String, decls.String, decls.Int},
+					decls.NewListType(decls.String),
+				),
+			),
+			decls.NewFunction("@re_match_parts_pattern",
+				decls.NewOverload(
+					"@re_match_parts_pattern_string_string",
+					[]*expr.Type{decls.String, decls.String},
+					decls.NewListType(decls.NewListType(decls.String)),
+				),
+			),
 		),
 	}
 }
@@ -206,136 +421,569 @@ func (l regexpLib) ProgramOptions() []cel.ProgramOption {
 				Binary:   l.findAllSubmatch,
 			},
 		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "typeV_re_find_named_string",
+				Binary:   l.findNamed,
+			},
+		),
 		cel.Functions(
 			&functions.Overload{
 				Operator: "typeV_re_replace_all_string_dyn",
 				Function: l.replaceAll,
 			},
 		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "string_re_expand_string_string",
+				Function: l.expand,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "string_re_split_string",
+				Binary:   l.split,
+			},
+			&functions.Overload{
+				Operator: "string_re_split_string_int",
+				Function: l.splitN,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "@re_match_parts_string_string",
+				Binary:   l.matchParts,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "typeV_re_match_pattern_string",
+				Binary:   matchPattern,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "typeV_re_find_pattern_string",
+				Binary:   findPattern,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "typeV_re_find_all_pattern_string",
+				Binary:   findAllPattern,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "typeV_re_find_submatch_pattern_string",
+				Binary:   findSubmatchPattern,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "typeV_re_find_all_submatch_pattern_string",
+				Binary:   findAllSubmatchPattern,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "typeV_re_find_named_pattern_string",
+				Binary:   findNamedPattern,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "typeV_re_replace_all_pattern_string_dyn",
+				Function: replaceAllPattern,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "string_re_expand_pattern_string_string",
+				Function: expandPattern,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "string_re_split_pattern_string",
+				Binary:   splitPattern,
+			},
+			&functions.Overload{
+				Operator: "string_re_split_pattern_string_int",
+				Function: splitNPattern,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "@re_match_parts_pattern_string_string",
+				Binary:   matchPartsPattern,
+			},
+		),
 	}
 }
 
 func (l regexpLib) match(arg1, arg2 ref.Val) ref.Val {
-	patName, ok := arg2.(types.String)
+	re, err := l.regexp(arg2)
+	if err != nil {
+		return err
+	}
+	return match(re, arg1)
+}
+
+func (l regexpLib) find(arg1, arg2 ref.Val) ref.Val {
+	re, err := l.regexp(arg2)
+	if err != nil {
+		return err
+	}
+	return find(re, arg1)
+}
+
+func (l regexpLib) findAll(arg1, arg2 ref.Val) ref.Val {
+	re, err := l.regexp(arg2)
+	if err != nil {
+		return err
+	}
+	return findAll(re, arg1)
+}
+
+func (l regexpLib) findSubmatch(arg1, arg2 ref.Val) ref.Val {
+	re, err := l.regexp(arg2)
+	if err != nil {
+		return err
+	}
+	return findSubmatch(re, arg1)
+}
+
+func (l regexpLib) findAllSubmatch(arg1, arg2 ref.Val) ref.Val {
+	re, err := l.regexp(arg2)
+	if err != nil {
+		return err
+	}
+	return findAllSubmatch(re, arg1)
+}
+
+func (l regexpLib) findNamed(arg1, arg2 ref.Val) ref.Val {
+	re, err := l.regexp(arg2)
+	if err != nil {
+		return err
+	}
+	return findNamed(re, arg1)
+}
+
+func (l regexpLib) replaceAll(args ...ref.Val) ref.Val {
+	if len(args) != 3 {
+		return types.NoSuchOverloadErr()
+	}
+	re, err := l.regexp(args[1])
+	if err != nil {
+		return err
+	}
+	return replaceAll(re, args[0], args[2])
+}
+
+func (l regexpLib) expand(args ...ref.Val) ref.Val {
+	if len(args) != 3 {
+		return types.NoSuchOverloadErr()
+	}
+	re, err := l.regexp(args[1])
+	if err != nil {
+		return err
+	}
+	return expand(re, args[0], args[2])
+}
+
+func (l regexpLib) split(arg1, arg2 ref.Val) ref.Val {
+	re, err := l.regexp(arg2)
+	if err != nil {
+		return err
+	}
+	return split(re, arg1, types.Int(-1))
+}
+
+func (l regexpLib) splitN(args ...ref.Val) ref.Val {
+	if len(args) != 3 {
+		return types.NoSuchOverloadErr()
+	}
+	re, err := l.regexp(args[1])
+	if err != nil {
+		return err
+	}
+	return split(re, args[0], args[2])
+}
+
+func (l regexpLib) matchParts(arg1, arg2 ref.Val) ref.Val {
+	re, err := l.regexp(arg2)
+	if err != nil {
+		return err
+	}
+	return matchParts(re, arg1)
+}
+
+// regexp returns the regular expression named by name.
+func (l regexpLib) regexp(name ref.Val) (*regexp.Regexp, ref.Val) {
+	patName, ok := name.(types.String)
 	if !ok {
-		return types.ValOrErr(patName, "no such overload")
+		return nil, types.ValOrErr(patName, "no such overload")
 	}
 	re, ok := l[string(patName)]
 	if !ok {
-		return types.NewErr("no regexp %s", patName)
+		return nil, types.NewErr("no regexp %s", patName)
+	}
+	return re, nil
+}
+
+func matchPattern(arg1, arg2 ref.Val) ref.Val {
+	re, err := compilePattern(arg2)
+	if err != nil {
+		return err
 	}
-	switch src := arg1.(type) {
+	return match(re, arg1)
+}
+
+func findPattern(arg1, arg2 ref.Val) ref.Val {
+	re, err := compilePattern(arg2)
+	if err != nil {
+		return err
+	}
+	return find(re, arg1)
+}
+
+func findAllPattern(arg1, arg2 ref.Val) ref.Val {
+	re, err := compilePattern(arg2)
+	if err != nil {
+		return err
+	}
+	return findAll(re, arg1)
+}
+
+func findSubmatchPattern(arg1, arg2 ref.Val) ref.Val {
+	re, err := compilePattern(arg2)
+	if err != nil {
+		return err
+	}
+	return findSubmatch(re, arg1)
+}
+
+func findAllSubmatchPattern(arg1, arg2 ref.Val) ref.Val {
+	re, err := compilePattern(arg2)
+	if err != nil {
+		return err
+	}
+	return findAllSubmatch(re, arg1)
+}
+
+func findNamedPattern(arg1, arg2 ref.Val) ref.Val {
+	re, err := compilePattern(arg2)
+	if err != nil {
+		return err
+	}
+	return findNamed(re, arg1)
+}
+
+func replaceAllPattern(args ...ref.Val) ref.Val {
+	if len(args) != 3 {
+		return types.NoSuchOverloadErr()
+	}
+	re, err := compilePattern(args[1])
+	if err != nil {
+		return err
+	}
+	return replaceAll(re, args[0], args[2])
+}
+
+func expandPattern(args ...ref.Val) ref.Val {
+	if len(args) != 3 {
+		return types.NoSuchOverloadErr()
+	}
+	re, err := compilePattern(args[1])
+	if err != nil {
+		return err
+	}
+	return expand(re, args[0], args[2])
+}
+
+func splitPattern(arg1, arg2 ref.Val) ref.Val {
+	re, err := compilePattern(arg2)
+	if err != nil {
+		return err
+	}
+	return split(re, arg1, types.Int(-1))
+}
+
+func splitNPattern(args ...ref.Val) ref.Val {
+	if len(args) != 3 {
+		return types.NoSuchOverloadErr()
+	}
+	re, err := compilePattern(args[1])
+	if err != nil {
+		return err
+	}
+	return split(re, args[0], args[2])
+}
+
+func matchPartsPattern(arg1, arg2 ref.Val) ref.Val {
+	re, err := compilePattern(arg2)
+	if err != nil {
+		return err
+	}
+	return matchParts(re, arg1)
+}
+
+// compilePattern returns the compiled regular expression for the pattern,
+// using a previously compiled expression if one is held in patternCache.
+func compilePattern(pattern ref.Val) (*regexp.Regexp, ref.Val) {
+	pat, ok := pattern.(types.String)
+	if !ok {
+		return nil, types.ValOrErr(pat, "no such overload")
+	}
+	re, err := patternCache.compile(string(pat))
+	if err != nil {
+		return nil, types.NewErr("invalid regexp %q: %v", pat, err)
+	}
+	return re, nil
+}
+
+// patternCache holds the most recently used inline patterns.
+var patternCache = newRegexpCache(256)
+
+// regexpCache is a least recently used cache of compiled regular
+// expressions keyed by their pattern.
+type regexpCache struct {
+	mu      sync.Mutex
+	max     int
+	entries map[string]*list.Element
+	lru     *list.List // Values are *regexpCacheEntry with most recent at the front.
+}
+
+type regexpCacheEntry struct {
+	pattern string
+	re      *regexp.Regexp
+}
+
+func newRegexpCache(max int) *regexpCache {
+	return &regexpCache{
+		max:     max,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// compile returns the compiled regular expression for pattern, compiling
+// it and adding it to the cache if it is not already held.
+func (c *regexpCache) compile(pattern string) (*regexp.Regexp, error) {
+	c.mu.Lock()
+	e, ok := c.entries[pattern]
+	if ok {
+		c.lru.MoveToFront(e)
+		c.mu.Unlock()
+		return e.Value.(*regexpCacheEntry).re, nil
+	}
+	c.mu.Unlock()
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[pattern]; ok {
+		// Another goroutine added the pattern while we were compiling.
+		c.lru.MoveToFront(e)
+		return e.Value.(*regexpCacheEntry).re, nil
+	}
+	c.entries[pattern] = c.lru.PushFront(&regexpCacheEntry{pattern: pattern, re: re})
+	if c.lru.Len() > c.max {
+		oldest := c.lru.Remove(c.lru.Back()).(*regexpCacheEntry)
+		delete(c.entries, oldest.pattern)
+	}
+	return re, nil
+}
+
+// len returns the number of patterns held by the cache.
+func (c *regexpCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+func match(re *regexp.Regexp, arg ref.Val) ref.Val {
+	switch src := arg.(type) {
 	case types.Bytes:
 		return types.Bool(re.Match(src))
 	case types.String:
 		return types.Bool(re.MatchString(string(src)))
 	default:
-		return types.NewErr("invalid type for match: %s", arg1.Type())
+		return types.NewErr("invalid type for match: %s", arg.Type())
 	}
 }
 
-func (l regexpLib) find(arg1, arg2 ref.Val) ref.Val {
-	patName, ok := arg2.(types.String)
-	if !ok {
-		return types.ValOrErr(patName, "no such overload")
-	}
-	re, ok := l[string(patName)]
-	if !ok {
-		return types.NewErr("no regexp %s", patName)
-	}
-	switch src := arg1.(type) {
+func find(re *regexp.Regexp, arg ref.Val) ref.Val {
+	switch src := arg.(type) {
 	case types.Bytes:
 		return types.Bytes(re.Find(src))
 	case types.String:
 		return types.String(re.FindString(string(src)))
 	default:
-		return types.NewErr("invalid type for find: %s", arg1.Type())
+		return types.NewErr("invalid type for find: %s", arg.Type())
 	}
 }
 
-func (l regexpLib) findAll(arg1, arg2 ref.Val) ref.Val {
-	patName, ok := arg2.(types.String)
-	if !ok {
-		return types.ValOrErr(patName, "no such overload")
-	}
-	re, ok := l[string(patName)]
-	if !ok {
-		return types.NewErr("no regexp %s", patName)
-	}
-	switch src := arg1.(type) {
+func findAll(re *regexp.Regexp, arg ref.Val) ref.Val {
+	switch src := arg.(type) {
 	case types.Bytes:
 		return types.DefaultTypeAdapter.NativeToValue(re.FindAll(src, -1))
 	case types.String:
 		return types.DefaultTypeAdapter.NativeToValue(re.FindAllString(string(src), -1))
 	default:
-		return types.NewErr("invalid type for find_all: %s", arg1.Type())
+		return types.NewErr("invalid type for find_all: %s", arg.Type())
 	}
 }
 
-func (l regexpLib) findSubmatch(arg1, arg2 ref.Val) ref.Val {
-	patName, ok := arg2.(types.String)
-	if !ok {
-		return types.ValOrErr(patName, "no such overload")
-	}
-	re, ok := l[string(patName)]
-	if !ok {
-		return types.NewErr("no regexp %s", patName)
-	}
-	switch src := arg1.(type) {
+func findSubmatch(re *regexp.Regexp, arg ref.Val) ref.Val {
+	switch src := arg.(type) {
 	case types.Bytes:
 		return types.DefaultTypeAdapter.NativeToValue(re.FindSubmatch(src))
 	case types.String:
 		return types.DefaultTypeAdapter.NativeToValue(re.FindStringSubmatch(string(src)))
 	default:
-		return types.NewErr("invalid type for find_submatch: %s", arg1.Type())
+		return types.NewErr("invalid type for find_submatch: %s", arg.Type())
 	}
 }
 
-func (l regexpLib) findAllSubmatch(arg1, arg2 ref.Val) ref.Val {
-	patName, ok := arg2.(types.String)
-	if !ok {
-		return types.ValOrErr(patName, "no such overload")
-	}
-	re, ok := l[string(patName)]
-	if !ok {
-		return types.NewErr("no regexp %s", patName)
-	}
-	switch src := arg1.(type) {
+func findAllSubmatch(re *regexp.Regexp, arg ref.Val) ref.Val {
+	switch src := arg.(type) {
 	case types.Bytes:
 		return types.DefaultTypeAdapter.NativeToValue(re.FindAllSubmatch(src, -1))
 	case types.String:
 		return types.DefaultTypeAdapter.NativeToValue(re.FindAllStringSubmatch(string(src), -1))
 	default:
-		return types.NewErr("invalid type for find_all_submatch: %s", arg1.Type())
+		return types.NewErr("invalid type for find_all_submatch: %s", arg.Type())
 	}
 }
 
-func (l regexpLib) replaceAll(args ...ref.Val) ref.Val {
-	if len(args) != 3 {
-		return types.NoSuchOverloadErr()
-	}
-	patName, ok := args[1].(types.String)
-	if !ok {
-		return types.ValOrErr(patName, "no such overload")
-	}
-	re, ok := l[string(patName)]
-	if !ok {
-		return types.NewErr("no regexp %s", patName)
+func findNamed(re *regexp.Regexp, arg ref.Val) ref.Val {
+	names := re.SubexpNames()
+	switch src := arg.(type) {
+	case types.Bytes:
+		named := make(map[string][]byte)
+		for i, m := range re.FindSubmatch(src) {
+			if names[i] != "" {
+				named[names[i]] = m
+			}
+		}
+		return types.DefaultTypeAdapter.NativeToValue(named)
+	case types.String:
+		named := make(map[string]string)
+		for i, m := range re.FindStringSubmatch(string(src)) {
+			if names[i] != "" {
+				named[names[i]] = m
+			}
+		}
+		return types.DefaultTypeAdapter.NativeToValue(named)
+	default:
+		return types.NewErr("invalid type for find_named: %s", arg.Type())
 	}
-	switch src := args[0].(type) {
+}
+
+func replaceAll(re *regexp.Regexp, arg, replacement ref.Val) ref.Val {
+	switch src := arg.(type) {
 	case types.Bytes:
-		repl, ok := args[2].(types.Bytes)
+		repl, ok := replacement.(types.Bytes)
 		if !ok {
 			return types.ValOrErr(repl, "no such overload")
 		}
 		return types.Bytes(re.ReplaceAll(src, repl))
 	case types.String:
-		repl, ok := args[2].(types.String)
+		repl, ok := replacement.(types.String)
 		if !ok {
 			return types.ValOrErr(repl, "no such overload")
 		}
 		return types.String(re.ReplaceAllString(string(src), string(repl)))
 	default:
-		return types.NewErr("invalid type for replace_all: %s", args[0].Type())
+		return types.NewErr("invalid type for replace_all: %s", arg.Type())
+	}
+}
+
+func expand(re *regexp.Regexp, arg, template ref.Val) ref.Val {
+	src, ok := arg.(types.String)
+	if !ok {
+		return types.ValOrErr(src, "no such overload")
+	}
+	tmpl, ok := template.(types.String)
+	if !ok {
+		return types.ValOrErr(tmpl, "no such overload")
+	}
+	match := re.FindStringSubmatchIndex(string(src))
+	if match == nil {
+		return types.String("")
+	}
+	return types.String(re.ExpandString(nil, string(tmpl), string(src), match))
+}
+
+func split(re *regexp.Regexp, arg, count ref.Val) ref.Val {
+	src, ok := arg.(types.String)
+	if !ok {
+		return types.ValOrErr(src, "no such overload")
+	}
+	n, ok := count.(types.Int)
+	if !ok {
+		return types.ValOrErr(n, "no such overload")
+	}
+	return types.NewStringList(types.DefaultTypeAdapter, re.Split(string(src), int(n)))
+}
+
+// makeReplaceAllFunc returns a macro expander for re_replace_all_func that
+// uses the named function to obtain the match parts of the target. The
+// function must return a list of [<prefix>, <match>] pairs followed by a
+// single element list holding the text after the last match, as returned
+// by matchParts. The expansion concatenates the parts, replacing each match
+// with the result of evaluating the macro's expression with the match bound
+// to the macro's identifier.
+func makeReplaceAllFunc(parts string) parser.MacroExpander {
+	return func(eh parser.ExprHelper, target ast.Expr, args []ast.Expr) (ast.Expr, *common.Error) {
+		pattern := args[0]
+		ident := args[1]
+		if ident.Kind() != ast.IdentKind {
+			return nil, &common.Error{Message: "argument is not an identifier"}
+		}
+		fn := args[2]
+
+		const partVar = "@part"
+		part := eh.NewIdent(partVar)
+		repl, err := makeAs(eh, eh.NewCall(operators.Index, part, eh.NewLiteral(types.IntOne)), []ast.Expr{ident, fn})
+		if err != nil {
+			return nil, err
+		}
+		isMatch := eh.NewCall(operators.Equals, eh.NewCall(overloads.Size, part), eh.NewLiteral(types.Int(2)))
+		accuExpr := eh.NewAccuIdent()
+		init := eh.NewLiteral(types.String(""))
+		condition := eh.NewLiteral(types.True)
+		step := eh.NewCall(operators.Add,
+			eh.NewCall(operators.Add, accuExpr, eh.NewCall(operators.Index, part, eh.NewLiteral(types.IntZero))),
+			eh.NewCall(operators.Conditional, isMatch, repl, eh.NewLiteral(types.String(""))),
+		)
+		return eh.NewComprehension(eh.NewCall(parts, target, pattern), partVar, parser.AccumulatorName, init, condition, step, accuExpr), nil
+	}
+}
+
+// matchParts returns the text of arg split into a list of [<prefix>, <match>]
+// pairs for each match of re, followed by a single element list holding the
+// text after the last match.
+func matchParts(re *regexp.Regexp, arg ref.Val) ref.Val {
+	src, ok := arg.(types.String)
+	if !ok {
+		return types.ValOrErr(src, "no such overload")
+	}
+	s := string(src)
+	var (
+		parts [][]string
+		last  int
+	)
+	for _, loc := range re.FindAllStringIndex(s, -1) {
+		parts = append(parts, []string{s[last:loc[0]], s[loc[0]:loc[1]]})
+		last = loc[1]
 	}
+	parts = append(parts, []string{s[last:]})
+	return types.DefaultTypeAdapter.NativeToValue(parts)
 }
@@ -0,0 +1,69 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package lib
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRegexpCache(t *testing.T) {
+	c := newRegexpCache(2)
+
+	first, err := c.compile("foo(.)")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		re, err := c.compile("foo(.)")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if re != first {
+			t.Fatalf("unexpected recompilation of cached pattern on iteration %d", i)
+		}
+	}
+	if got := c.len(); got != 1 {
+		t.Errorf("unexpected cache size after repeated compilation: got:%d want:1", got)
+	}
+
+	for i := 0; i < 10; i++ {
+		_, err := c.compile(fmt.Sprintf("bar%d", i))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if got := c.len(); got != 2 {
+		t.Errorf("unexpected cache size after distinct compilations: got:%d want:2", got)
+	}
+	re, err := c.compile("foo(.)")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if re == first {
+		t.Error("expected evicted pattern to be recompiled")
+	}
+
+	_, err = c.compile("(")
+	if err == nil {
+		t.Error("expected error for invalid pattern")
+	}
+	if got := c.len(); got != 2 {
+		t.Errorf("unexpected cache size after invalid pattern: got:%d want:2", got)
+	}
+}
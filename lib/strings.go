@@ -18,14 +18,19 @@
 package lib
 
 import (
+	"strconv"
 	"strings"
+	"unicode"
 	"unicode/utf8"
 
 	"github.com/google/cel-go/cel"
 	"github.com/google/cel-go/checker/decls"
 	"github.com/google/cel-go/common/types"
 	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
 	"github.com/google/cel-go/interpreter/functions"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
 	expr "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
 )
 
@@ -33,7 +38,8 @@ import (
 // handling strings.
 //
 // All functions provided by Strings are methods on the string type or list<string> type
-// with the exception of to_valid_utf8 and valid_utf8 which are methods on the bytes type.
+// with the exception of slice, to_valid_utf8 and valid_utf8 which are methods on the bytes
+// type.
 //
 // Relevant documentation for the methods can obtained from the Go standard library. In all
 // cases the first parameter in the Go function corresponds to the CEL method receiver.
@@ -52,6 +58,7 @@ import (
 //   - index_any: strings.IndexAny(s, chars string) int
 //   - last_index: strings.LastIndex(s, substr string) int
 //   - last_index_any: strings.LastIndexAny(s, chars string) int
+//   - quote: strconv.Quote(s string) string
 //   - repeat: strings.Repeat(s string, count int) string
 //   - replace: strings.Replace(s, old, new string, n int) string
 //   - replace_all: strings.ReplaceAll(s, old, new string) string
@@ -68,6 +75,7 @@ import (
 //   - trim_right: strings.TrimRight(s, cutset string) string
 //   - trim_space: strings.TrimSpace(s string) string
 //   - trim_suffix: strings.TrimSuffix(s, suffix string) string
+//   - unquote: strconv.Unquote(s string) (string, error)
 //
 // In addition to the strings package functions, a sub-string method is provided that allows
 // string slicing at unicode code point boundaries. It differs from Go's string slicing
@@ -77,12 +85,70 @@ import (
 //
 //   - substring: s[start:end]
 //
+// Padding methods are provided that pad the receiver to a width counted in unicode code
+// points. The pad string is repeated as many times as needed and truncated to fit the
+// width. The receiver is returned unaltered if it is already at least as wide as width.
+//
+//   - pad_left: "42".pad_left(5, "0") returns "00042"
+//   - pad_right: "42".pad_right(5, "ab") returns "42aba"
+//
+// Edit distance methods are provided for fuzzy matching. Distances are counted in
+// unicode code points.
+//
+//   - levenshtein: levenshtein(t string) int returns the Levenshtein edit distance
+//     between the receiver and t, "kitten".levenshtein("sitting") returns 3
+//   - similarity: similarity(t string) double returns one minus the Levenshtein
+//     distance divided by the length of the longer string, so identical strings
+//     have a similarity of 1.0
+//
+// Line layout methods are provided for formatting multi-line text. Widths are counted
+// in unicode code points.
+//
+//   - dedent: removes the longest common leading whitespace from the non-blank lines,
+//     whitespace-only lines are made empty
+//   - indent: indent(prefix string) adds prefix to the start of each non-empty line
+//   - wrap: wrap(width int) wraps each line at word boundaries so that lines are no
+//     wider than width where possible, words wider than width are placed on their own line
+//
+// A template method is provided that interpolates values from a map into {key}
+// placeholders in the receiver. Literal braces are written as {{ and }}. Values are
+// converted to strings as they would be by the CEL string conversion function. A
+// placeholder with a key that is not in the map is an error unless a default value
+// is provided as the second parameter, in which case the default is used.
+//
+//   - template: "user {name} from {country}".template({"name": "jo", "country": "AU"})
+//     returns "user jo from AU"
+//
+// A title_case method is provided that capitalizes the first letter of each word and
+// converts the remainder of each word to lower case using the word boundary rules of
+// golang.org/x/text/cases. This differs from to_title which maps all letters to their
+// title case, "hello world".to_title() returns "HELLO WORLD".
+//
+//   - title_case: "hello world".title_case() returns "Hello World"
+//
+// Identifier case conversion methods are also provided. The receiver is split into
+// words at non-alphanumeric separators, at transitions from lower case letters or
+// digits to upper case letters and at the end of runs of upper case letters such as
+// acronyms, so "HTTPStatusCode" and "http-status code" both have the words "http",
+// "status" and "code".
+//
+//   - to_camel: words joined with all but the first capitalized, "httpStatusCode"
+//   - to_kebab: lower case words joined with hyphens, "http-status-code"
+//   - to_pascal: words joined with each capitalized, "HttpStatusCode"
+//   - to_snake: lower case words joined with underscores, "http_status_code"
+//
 // # String List Methods
 //
 //   - join: strings.Join(elems []string, sep string) string
 //
 // # Bytes Methods
 //
+// The slice method returns the bytes between the start and end byte offsets. It is the
+// bytes analogue of substring and returns an error if the offsets are out of bounds or
+// invalid.
+//
+//   - slice: b[start:end]
+//
 // The to_valid_utf8 method is equivalent to strings.ToValidUTF8 with the receiver first
 // converted to a Go string. This special case is required as CEL does not permit invalid
 // UTF-8 string conversions.
@@ -133,6 +199,15 @@ func (stringLib) CompileOptions() []cel.EnvOption {
 				),
 			),
 		),
+		cel.Declarations(
+			decls.NewFunction("dedent",
+				decls.NewInstanceOverload(
+					"string_dedent_string",
+					[]*expr.Type{decls.String},
+					decls.String,
+				),
+			),
+		),
 		cel.Declarations(
 			decls.NewFunction("equal_fold",
 				decls.NewInstanceOverload(
@@ -169,6 +244,15 @@ func (stringLib) CompileOptions() []cel.EnvOption {
 				),
 			),
 		),
+		cel.Declarations(
+			decls.NewFunction("indent",
+				decls.NewInstanceOverload(
+					"string_indent_string_string",
+					[]*expr.Type{decls.String, decls.String},
+					decls.String,
+				),
+			),
+		),
 		cel.Declarations(
 			decls.NewFunction("index",
 				decls.NewInstanceOverload(
@@ -214,6 +298,42 @@ func (stringLib) CompileOptions() []cel.EnvOption {
 				),
 			),
 		),
+		cel.Declarations(
+			decls.NewFunction("levenshtein",
+				decls.NewInstanceOverload(
+					"string_levenshtein_string_int",
+					[]*expr.Type{decls.String, decls.String},
+					decls.Int,
+				),
+			),
+		),
+		cel.Declarations(
+			decls.NewFunction("pad_left",
+				decls.NewInstanceOverload(
+					"string_pad_left_int_string_string",
+					[]*expr.Type{decls.String, decls.Int, decls.String},
+					decls.String,
+				),
+			),
+		),
+		cel.Declarations(
+			decls.NewFunction("pad_right",
+				decls.NewInstanceOverload(
+					"string_pad_right_int_string_string",
+					[]*expr.Type{decls.String, decls.Int, decls.String},
+					decls.String,
+				),
+			),
+		),
+		cel.Declarations(
+			decls.NewFunction("quote",
+				decls.NewInstanceOverload(
+					"string_quote_string",
+					[]*expr.Type{decls.String},
+					decls.String,
+				),
+			),
+		),
 		cel.Declarations(
 			decls.NewFunction("repeat",
 				decls.NewInstanceOverload(
@@ -241,6 +361,24 @@ func (stringLib) CompileOptions() []cel.EnvOption {
 				),
 			),
 		),
+		cel.Declarations(
+			decls.NewFunction("similarity",
+				decls.NewInstanceOverload(
+					"string_similarity_string_double",
+					[]*expr.Type{decls.String, decls.String},
+					decls.Double,
+				),
+			),
+		),
+		cel.Declarations(
+			decls.NewFunction("slice",
+				decls.NewInstanceOverload(
+					"bytes_slice_int_int_bytes",
+					[]*expr.Type{decls.Bytes, decls.Int, decls.Int},
+					decls.Bytes,
+				),
+			),
+		),
 		cel.Declarations(
 			decls.NewFunction("split",
 				decls.NewInstanceOverload(
@@ -286,6 +424,47 @@ func (stringLib) CompileOptions() []cel.EnvOption {
 				),
 			),
 		),
+		cel.Declarations(
+			decls.NewFunction("template",
+				decls.NewInstanceOverload(
+					"string_template_map_string",
+					[]*expr.Type{decls.String, decls.NewMapType(decls.String, decls.Dyn)},
+					decls.String,
+				),
+				decls.NewInstanceOverload(
+					"string_template_map_string_string",
+					[]*expr.Type{decls.String, decls.NewMapType(decls.String, decls.Dyn), decls.String},
+					decls.String,
+				),
+			),
+		),
+		cel.Declarations(
+			decls.NewFunction("title_case",
+				decls.NewInstanceOverload(
+					"string_title_case_string",
+					[]*expr.Type{decls.String},
+					decls.String,
+				),
+			),
+		),
+		cel.Declarations(
+			decls.NewFunction("to_camel",
+				decls.NewInstanceOverload(
+					"string_to_camel_string",
+					[]*expr.Type{decls.String},
+					decls.String,
+				),
+			),
+		),
+		cel.Declarations(
+			decls.NewFunction("to_kebab",
+				decls.NewInstanceOverload(
+					"string_to_kebab_string",
+					[]*expr.Type{decls.String},
+					decls.String,
+				),
+			),
+		),
 		cel.Declarations(
 			decls.NewFunction("to_lower",
 				decls.NewInstanceOverload(
@@ -295,6 +474,24 @@ func (stringLib) CompileOptions() []cel.EnvOption {
 				),
 			),
 		),
+		cel.Declarations(
+			decls.NewFunction("to_pascal",
+				decls.NewInstanceOverload(
+					"string_to_pascal_string",
+					[]*expr.Type{decls.String},
+					decls.String,
+				),
+			),
+		),
+		cel.Declarations(
+			decls.NewFunction("to_snake",
+				decls.NewInstanceOverload(
+					"string_to_snake_string",
+					[]*expr.Type{decls.String},
+					decls.String,
+				),
+			),
+		),
 		cel.Declarations(
 			decls.NewFunction("to_title",
 				decls.NewInstanceOverload(
@@ -376,6 +573,15 @@ func (stringLib) CompileOptions() []cel.EnvOption {
 				),
 			),
 		),
+		cel.Declarations(
+			decls.NewFunction("unquote",
+				decls.NewInstanceOverload(
+					"string_unquote_string",
+					[]*expr.Type{decls.String},
+					decls.String,
+				),
+			),
+		),
 		cel.Declarations(
 			decls.NewFunction("valid_utf8",
 				decls.NewInstanceOverload(
@@ -385,6 +591,15 @@ func (stringLib) CompileOptions() []cel.EnvOption {
 				),
 			),
 		),
+		cel.Declarations(
+			decls.NewFunction("wrap",
+				decls.NewInstanceOverload(
+					"string_wrap_int_string",
+					[]*expr.Type{decls.String, decls.Int},
+					decls.String,
+				),
+			),
+		),
 	}
 }
 
@@ -414,6 +629,12 @@ func (l stringLib) ProgramOptions() []cel.ProgramOption {
 				Binary:   l.count,
 			},
 		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "string_dedent_string",
+				Unary:    l.dedent,
+			},
+		),
 		cel.Functions(
 			&functions.Overload{
 				Operator: "string_equal_fold_string_bool",
@@ -438,6 +659,12 @@ func (l stringLib) ProgramOptions() []cel.ProgramOption {
 				Binary:   l.hasSuffix,
 			},
 		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "string_indent_string_string",
+				Binary:   l.indent,
+			},
+		),
 		cel.Functions(
 			&functions.Overload{
 				Operator: "string_index_string_int",
@@ -468,6 +695,30 @@ func (l stringLib) ProgramOptions() []cel.ProgramOption {
 				Binary:   l.lastIndexAny,
 			},
 		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "string_levenshtein_string_int",
+				Binary:   l.levenshtein,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "string_pad_left_int_string_string",
+				Function: l.padLeft,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "string_pad_right_int_string_string",
+				Function: l.padRight,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "string_quote_string",
+				Unary:    l.quote,
+			},
+		),
 		cel.Functions(
 			&functions.Overload{
 				Operator: "string_repeat_int_string",
@@ -486,6 +737,18 @@ func (l stringLib) ProgramOptions() []cel.ProgramOption {
 				Function: l.replaceAll,
 			},
 		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "string_similarity_string_double",
+				Binary:   l.similarity,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "bytes_slice_int_int_bytes",
+				Function: l.slice,
+			},
+		),
 		cel.Functions(
 			&functions.Overload{
 				Operator: "string_split_string_list_string",
@@ -516,12 +779,52 @@ func (l stringLib) ProgramOptions() []cel.ProgramOption {
 				Function: l.substring,
 			},
 		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "string_template_map_string",
+				Binary:   l.template,
+			},
+			&functions.Overload{
+				Operator: "string_template_map_string_string",
+				Function: l.templateWithDefault,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "string_title_case_string",
+				Unary:    l.titleCase,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "string_to_camel_string",
+				Unary:    l.toCamel,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "string_to_kebab_string",
+				Unary:    l.toKebab,
+			},
+		),
 		cel.Functions(
 			&functions.Overload{
 				Operator: "string_to_lower_string",
 				Unary:    l.toLower,
 			},
 		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "string_to_pascal_string",
+				Unary:    l.toPascal,
+			},
+		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "string_to_snake_string",
+				Unary:    l.toSnake,
+			},
+		),
 		cel.Functions(
 			&functions.Overload{
 				Operator: "string_to_title_string",
@@ -576,12 +879,24 @@ func (l stringLib) ProgramOptions() []cel.ProgramOption {
 				Binary:   l.trimSuffix,
 			},
 		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "string_unquote_string",
+				Unary:    l.unquote,
+			},
+		),
 		cel.Functions(
 			&functions.Overload{
 				Operator: "bytes_valid_utf8_bool",
 				Unary:    l.validString,
 			},
 		),
+		cel.Functions(
+			&functions.Overload{
+				Operator: "string_wrap_int_string",
+				Binary:   l.wrap,
+			},
+		),
 	}
 }
 
@@ -633,6 +948,40 @@ func (l stringLib) count(arg0, arg1 ref.Val) ref.Val {
 	return types.DefaultTypeAdapter.NativeToValue(strings.Count(string(s), string(substr)))
 }
 
+func (l stringLib) dedent(arg ref.Val) ref.Val {
+	s, ok := arg.(types.String)
+	if !ok {
+		return types.ValOrErr(s, "no such overload for dedent")
+	}
+	lines := strings.Split(string(s), "\n")
+	var (
+		margin string
+		found  bool
+	)
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if !found {
+			margin = indent
+			found = true
+			continue
+		}
+		for !strings.HasPrefix(indent, margin) {
+			margin = margin[:len(margin)-1]
+		}
+	}
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			lines[i] = ""
+			continue
+		}
+		lines[i] = strings.TrimPrefix(line, margin)
+	}
+	return types.String(strings.Join(lines, "\n"))
+}
+
 func (l stringLib) equalFold(arg0, arg1 ref.Val) ref.Val {
 	s, ok := arg0.(types.String)
 	if !ok {
@@ -677,6 +1026,24 @@ func (l stringLib) hasSuffix(arg0, arg1 ref.Val) ref.Val {
 	return types.DefaultTypeAdapter.NativeToValue(strings.HasSuffix(string(s), string(suffix)))
 }
 
+func (l stringLib) indent(arg0, arg1 ref.Val) ref.Val {
+	s, ok := arg0.(types.String)
+	if !ok {
+		return types.ValOrErr(s, "no such overload for indent")
+	}
+	prefix, ok := arg1.(types.String)
+	if !ok {
+		return types.ValOrErr(prefix, "no such overload for indent")
+	}
+	lines := strings.Split(string(s), "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = string(prefix) + line
+		}
+	}
+	return types.String(strings.Join(lines, "\n"))
+}
+
 func (l stringLib) index(arg0, arg1 ref.Val) ref.Val {
 	s, ok := arg0.(types.String)
 	if !ok {
@@ -737,6 +1104,67 @@ func (l stringLib) lastIndexAny(arg0, arg1 ref.Val) ref.Val {
 	return types.DefaultTypeAdapter.NativeToValue(strings.LastIndexAny(string(s), string(chars)))
 }
 
+func (l stringLib) levenshtein(arg0, arg1 ref.Val) ref.Val {
+	a, ok := arg0.(types.String)
+	if !ok {
+		return types.ValOrErr(a, "no such overload for levenshtein")
+	}
+	b, ok := arg1.(types.String)
+	if !ok {
+		return types.ValOrErr(b, "no such overload for levenshtein")
+	}
+	return types.Int(levenshtein([]rune(a), []rune(b)))
+}
+
+func (l stringLib) padLeft(args ...ref.Val) ref.Val {
+	return pad("pad_left", true, args)
+}
+
+func (l stringLib) padRight(args ...ref.Val) ref.Val {
+	return pad("pad_right", false, args)
+}
+
+// pad returns the string in args[0] padded to the width in args[1] with
+// the pad string in args[2], on the left if left is true and otherwise on
+// the right. The name is used in error messages.
+func pad(name string, left bool, args []ref.Val) ref.Val {
+	if len(args) != 3 {
+		return types.NewErr("no such overload for %s", name)
+	}
+	s, ok := args[0].(types.String)
+	if !ok {
+		return types.ValOrErr(s, "no such overload for %s", name)
+	}
+	width, ok := args[1].(types.Int)
+	if !ok {
+		return types.ValOrErr(width, "no such overload for %s", name)
+	}
+	padding, ok := args[2].(types.String)
+	if !ok {
+		return types.ValOrErr(padding, "no such overload for %s", name)
+	}
+	n := int64(width) - int64(utf8.RuneCountInString(string(s)))
+	if n <= 0 {
+		return s
+	}
+	if padding == "" {
+		return types.NewErr("%s: empty pad string", name)
+	}
+	p := []rune(strings.Repeat(string(padding), int(n)/utf8.RuneCountInString(string(padding))+1))[:n]
+	if left {
+		return types.String(string(p) + string(s))
+	}
+	return types.String(string(s) + string(p))
+}
+
+func (l stringLib) quote(arg ref.Val) ref.Val {
+	s, ok := arg.(types.String)
+	if !ok {
+		return types.ValOrErr(s, "no such overload for quote")
+	}
+	return types.String(strconv.Quote(string(s)))
+}
+
 func (l stringLib) repeat(arg0, arg1 ref.Val) ref.Val {
 	s, ok := arg0.(types.String)
 	if !ok {
@@ -791,6 +1219,54 @@ func (l stringLib) replaceAll(args ...ref.Val) ref.Val {
 	return types.DefaultTypeAdapter.NativeToValue(strings.ReplaceAll(string(s), string(old), string(new)))
 }
 
+func (l stringLib) similarity(arg0, arg1 ref.Val) ref.Val {
+	a, ok := arg0.(types.String)
+	if !ok {
+		return types.ValOrErr(a, "no such overload for similarity")
+	}
+	b, ok := arg1.(types.String)
+	if !ok {
+		return types.ValOrErr(b, "no such overload for similarity")
+	}
+	ra, rb := []rune(a), []rune(b)
+	n := len(ra)
+	if len(rb) > n {
+		n = len(rb)
+	}
+	if n == 0 {
+		return types.Double(1)
+	}
+	return types.Double(1 - float64(levenshtein(ra, rb))/float64(n))
+}
+
+func (l stringLib) slice(args ...ref.Val) ref.Val {
+	if len(args) != 3 {
+		return types.NewErr("no such overload for slice")
+	}
+	b, ok := args[0].(types.Bytes)
+	if !ok {
+		return types.ValOrErr(b, "no such overload for slice")
+	}
+	start, ok := args[1].(types.Int)
+	if !ok {
+		return types.ValOrErr(start, "no such overload for slice")
+	}
+	if start < 0 {
+		return types.NewErr("slice: start out of range: %d < 0", start)
+	}
+	end, ok := args[2].(types.Int)
+	if !ok {
+		return types.ValOrErr(end, "no such overload for slice")
+	}
+	if end < start {
+		return types.NewErr("slice: end out of range: %d < %d", end, start)
+	}
+	if end > types.Int(len(b)) {
+		return types.NewErr("slice: end out of range: %d > %d", end, len(b))
+	}
+	return b[start:end]
+}
+
 func (l stringLib) split(arg0, arg1 ref.Val) ref.Val {
 	s, ok := arg0.(types.String)
 	if !ok {
@@ -901,6 +1377,112 @@ func (l stringLib) substring(args ...ref.Val) ref.Val {
 	return types.NewErr("substring: end out of range: %d > %d", end, i)
 }
 
+func (l stringLib) template(arg0, arg1 ref.Val) ref.Val {
+	s, ok := arg0.(types.String)
+	if !ok {
+		return types.ValOrErr(s, "no such overload for template")
+	}
+	m, ok := arg1.(traits.Mapper)
+	if !ok {
+		return types.ValOrErr(arg1, "no such overload for template")
+	}
+	return interpolate(string(s), m, nil)
+}
+
+func (l stringLib) templateWithDefault(args ...ref.Val) ref.Val {
+	if len(args) != 3 {
+		return types.NewErr("no such overload for template")
+	}
+	s, ok := args[0].(types.String)
+	if !ok {
+		return types.ValOrErr(s, "no such overload for template")
+	}
+	m, ok := args[1].(traits.Mapper)
+	if !ok {
+		return types.ValOrErr(args[1], "no such overload for template")
+	}
+	def, ok := args[2].(types.String)
+	if !ok {
+		return types.ValOrErr(def, "no such overload for template")
+	}
+	return interpolate(string(s), m, &def)
+}
+
+// interpolate returns s with {key} placeholders replaced with the string
+// conversion of the value of key in m. If def is not nil, it is used for
+// keys that are not in m.
+func interpolate(s string, m traits.Mapper, def *types.String) ref.Val {
+	var buf strings.Builder
+	for {
+		idx := strings.IndexAny(s, "{}")
+		if idx < 0 {
+			buf.WriteString(s)
+			break
+		}
+		buf.WriteString(s[:idx])
+		if idx+1 < len(s) && s[idx+1] == s[idx] {
+			// Escaped brace.
+			buf.WriteByte(s[idx])
+			s = s[idx+2:]
+			continue
+		}
+		if s[idx] == '}' {
+			return types.NewErr("template: unmatched '}': %q", s[idx:])
+		}
+		end := strings.IndexByte(s[idx:], '}')
+		if end < 0 {
+			return types.NewErr("template: unterminated placeholder: %q", s[idx:])
+		}
+		key := s[idx+1 : idx+end]
+		val, ok := m.Find(types.String(key))
+		if !ok {
+			if def == nil {
+				return types.NewErr("template: no value for %q", key)
+			}
+			val = *def
+		}
+		str, ok := val.ConvertToType(types.StringType).(types.String)
+		if !ok {
+			return types.NewErr("template: cannot convert value for %q to string: %s", key, val.Type().TypeName())
+		}
+		buf.WriteString(string(str))
+		s = s[idx+end+1:]
+	}
+	return types.String(buf.String())
+}
+
+func (l stringLib) titleCase(arg ref.Val) ref.Val {
+	s, ok := arg.(types.String)
+	if !ok {
+		return types.ValOrErr(s, "no such overload for title_case")
+	}
+	return types.String(cases.Title(language.Und).String(string(s)))
+}
+
+func (l stringLib) toCamel(arg ref.Val) ref.Val {
+	s, ok := arg.(types.String)
+	if !ok {
+		return types.ValOrErr(s, "no such overload for to_camel")
+	}
+	words := identWords(string(s))
+	for i, w := range words {
+		if i == 0 {
+			words[i] = strings.ToLower(w)
+		} else {
+			words[i] = capitalize(w)
+		}
+	}
+	return types.String(strings.Join(words, ""))
+}
+
+func (l stringLib) toKebab(arg ref.Val) ref.Val {
+	s, ok := arg.(types.String)
+	if !ok {
+		return types.ValOrErr(s, "no such overload for to_kebab")
+	}
+	return types.String(strings.ToLower(strings.Join(identWords(string(s)), "-")))
+}
+
 func (l stringLib) toLower(arg ref.Val) ref.Val {
 	s, ok := arg.(types.String)
 	if !ok {
@@ -909,6 +1491,26 @@ func (l stringLib) toLower(arg ref.Val) ref.Val {
 	return types.DefaultTypeAdapter.NativeToValue(strings.ToLower(string(s)))
 }
 
+func (l stringLib) toPascal(arg ref.Val) ref.Val {
+	s, ok := arg.(types.String)
+	if !ok {
+		return types.ValOrErr(s, "no such overload for to_pascal")
+	}
+	words := identWords(string(s))
+	for i, w := range words {
+		words[i] = capitalize(w)
+	}
+	return types.String(strings.Join(words, ""))
+}
+
+func (l stringLib) toSnake(arg ref.Val) ref.Val {
+	s, ok := arg.(types.String)
+	if !ok {
+		return types.ValOrErr(s, "no such overload for to_snake")
+	}
+	return types.String(strings.ToLower(strings.Join(identWords(string(s)), "_")))
+}
+
 func (l stringLib) toTitle(arg ref.Val) ref.Val {
 	s, ok := arg.(types.String)
 	if !ok {
@@ -1005,6 +1607,18 @@ func (l stringLib) trimSuffix(arg0, arg1 ref.Val) ref.Val {
 	return types.DefaultTypeAdapter.NativeToValue(strings.TrimSuffix(string(s), string(suffix)))
 }
 
+func (l stringLib) unquote(arg ref.Val) ref.Val {
+	s, ok := arg.(types.String)
+	if !ok {
+		return types.ValOrErr(s, "no such overload for unquote")
+	}
+	u, err := strconv.Unquote(string(s))
+	if err != nil {
+		return types.NewErr("unquote: %v: %s", err, s)
+	}
+	return types.String(u)
+}
+
 func (l stringLib) validString(arg ref.Val) ref.Val {
 	s, ok := arg.(types.Bytes)
 	if !ok {
@@ -1012,3 +1626,122 @@ func (l stringLib) validString(arg ref.Val) ref.Val {
 	}
 	return types.DefaultTypeAdapter.NativeToValue(utf8.Valid([]byte(s)))
 }
+
+func (l stringLib) wrap(arg0, arg1 ref.Val) ref.Val {
+	s, ok := arg0.(types.String)
+	if !ok {
+		return types.ValOrErr(s, "no such overload for wrap")
+	}
+	width, ok := arg1.(types.Int)
+	if !ok {
+		return types.ValOrErr(width, "no such overload for wrap")
+	}
+	if width < 1 {
+		return types.NewErr("wrap: invalid width: %d", width)
+	}
+	lines := strings.Split(string(s), "\n")
+	for i, line := range lines {
+		lines[i] = wrapLine(line, int(width))
+	}
+	return types.String(strings.Join(lines, "\n"))
+}
+
+// wrapLine returns line with its words greedily wrapped so that each line
+// is no wider than width runes. Words wider than width are placed on their
+// own line.
+func wrapLine(line string, width int) string {
+	var (
+		buf strings.Builder
+		n   int // Width of the current line.
+	)
+	for _, w := range strings.Fields(line) {
+		wn := utf8.RuneCountInString(w)
+		switch {
+		case n == 0:
+		case n+1+wn <= width:
+			buf.WriteByte(' ')
+			n++
+		default:
+			buf.WriteByte('\n')
+			n = 0
+		}
+		buf.WriteString(w)
+		n += wn
+	}
+	return buf.String()
+}
+
+// levenshtein returns the Levenshtein edit distance between a and b.
+func levenshtein(a, b []rune) int {
+	if len(a) < len(b) {
+		// Keep the rows as short as possible.
+		a, b = b, a
+	}
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := range a {
+		curr[0] = i + 1
+		for j := range b {
+			cost := 1
+			if a[i] == b[j] {
+				cost = 0
+			}
+			curr[j+1] = minInt(prev[j+1]+1, curr[j]+1, prev[j]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// minInt returns the smallest of its arguments.
+func minInt(a int, b ...int) int {
+	for _, v := range b {
+		if v < a {
+			a = v
+		}
+	}
+	return a
+}
+
+// identWords returns the words of an identifier. Words are separated by
+// non-alphanumeric characters, by a transition from a lower case letter or
+// digit to an upper case letter, and before the last upper case letter of
+// a run of upper case letters that is followed by a lower case letter.
+func identWords(s string) []string {
+	var (
+		words []string
+		word  []rune
+	)
+	runes := []rune(s)
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if len(word) != 0 {
+				words = append(words, string(word))
+				word = word[:0]
+			}
+			continue
+		}
+		if len(word) != 0 && unicode.IsUpper(r) {
+			prev := word[len(word)-1]
+			if !unicode.IsUpper(prev) || (i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+				words = append(words, string(word))
+				word = word[:0]
+			}
+		}
+		word = append(word, r)
+	}
+	if len(word) != 0 {
+		words = append(words, string(word))
+	}
+	return words
+}
+
+// capitalize returns w with its first character in upper case and the
+// remainder in lower case.
+func capitalize(w string) string {
+	r, n := utf8.DecodeRuneInString(w)
+	return string(unicode.ToUpper(r)) + strings.ToLower(w[n:])
+}
@@ -18,7 +18,10 @@
 package lib
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/google/cel-go/cel"
@@ -79,6 +82,141 @@ import (
 //	"11:17AM".parse_time([time_layout.RFC3339,time_layout.Kitchen]) // return <timestamp>
 //	"11:17AM".parse_time(time_layout.RFC3339)                       // return error
 //
+// # Strftime and Strptime
+//
+// format_strftime and parse_time_strptime format and parse timestamps as
+// format and parse_time, but using C-style strftime directives instead of Go
+// time layouts. The supported directives are %Y (year), %y (two-digit year),
+// %m (month), %b (abbreviated month name), %B (month name), %d (day of month),
+// %e (space-padded day of month), %a (abbreviated weekday name), %A (weekday
+// name), %H (24-hour hour), %I (12-hour hour), %p (AM/PM), %M (minute),
+// %S (second), %z (numeric zone offset), %Z (zone abbreviation), %F (%Y-%m-%d),
+// %T (%H:%M:%S), %D (%m/%d/%y), %R (%H:%M) and %% (a literal %). Unknown
+// directives result in an error. Literal text in the format must not contain
+// digits or text that is significant in a Go time layout, such as month and
+// weekday names:
+//
+//	<string>.parse_time_strptime(<string>) -> <timestamp>
+//	<timestamp>.format_strftime(<string>) -> <string>
+//
+// Examples:
+//
+//	"2023-10-30 22:32:15".parse_time_strptime("%Y-%m-%d %H:%M:%S")               // return "2023-10-30T22:32:15Z"
+//	timestamp("2023-10-30T22:32:15Z").format_strftime("%a, %d %b %Y %I:%M %p")   // return "Mon, 30 Oct 2023 10:32 PM"
+//	"2023-10-30".parse_time_strptime("%Y-%m-%d %Q")                              // return error
+//
+// # Time Bucket Key
+//
+// Returns a string key identifying the UTC time bucket containing the
+// timestamp at the provided granularity. Valid granularities are "year",
+// "month", "day", "hour" and "minute". The key is suitable for use in
+// index names and partition keys:
+//
+//	time_bucket_key(<timestamp>, <string>) -> <string>
+//
+// Examples:
+//
+//	time_bucket_key(timestamp("2024-06-15T13:45:00Z"), "month")   // return "2024-06"
+//	time_bucket_key(timestamp("2024-06-15T13:45:00Z"), "day")     // return "2024-06-15"
+//	time_bucket_key(timestamp("2024-06-15T13:45:00Z"), "hour")    // return "2024-06-15T13"
+//	time_bucket_key(timestamp("2024-06-15T13:45:00Z"), "week")    // return error
+//
+// # Parse Unix
+//
+// Returns a UTC timestamp from an integer number of seconds, milliseconds
+// or nanoseconds since the Unix epoch:
+//
+//	parse_unix(<int>) -> <timestamp>
+//	parse_unix_milli(<int>) -> <timestamp>
+//	parse_unix_nano(<int>) -> <timestamp>
+//	<int>.parse_unix() -> <timestamp>
+//	<int>.parse_unix_milli() -> <timestamp>
+//	<int>.parse_unix_nano() -> <timestamp>
+//
+// Examples:
+//
+//	parse_unix(1718459130)                        // return "2024-06-15T13:45:30Z"
+//	parse_unix_milli(1718459130500)               // return "2024-06-15T13:45:30.5Z"
+//	int("1718459130500000000").parse_unix_nano()  // return "2024-06-15T13:45:30.5Z"
+//
+// # Unix
+//
+// Returns the number of seconds, milliseconds or nanoseconds since the Unix
+// epoch of a timestamp. The result of unix_nano is undefined for timestamps
+// that cannot be represented as an int64 number of nanoseconds:
+//
+//	<timestamp>.unix() -> <int>
+//	<timestamp>.unix_milli() -> <int>
+//	<timestamp>.unix_nano() -> <int>
+//
+// Examples:
+//
+//	timestamp("2024-06-15T13:45:30.5Z").unix()        // return 1718459130
+//	timestamp("2024-06-15T13:45:30.5Z").unix_milli()  // return 1718459130500
+//	timestamp("2024-06-15T13:45:30.5Z").unix_nano()   // return 1718459130500000000
+//
+// # Parse Duration
+//
+// Returns a duration parsed from a string of signed decimal numbers with
+// optional fractions and unit suffixes as accepted by Go's time.ParseDuration.
+// Valid units are "ns", "us" (or "µs"), "ms", "s", "m" and "h":
+//
+//	<string>.parse_duration() -> <duration>
+//
+// Examples:
+//
+//	"1h30m".parse_duration()  // return "5400s"
+//	"90m".parse_duration()    // return "5400s"
+//	"1d".parse_duration()     // return error
+//
+// # Format Duration
+//
+// Returns the duration expressed as a number of the named unit. Valid units
+// are "ns", "us" (or "µs"), "ms", "s", "m" and "h":
+//
+//	<duration>.format_duration(<string>) -> <double>
+//
+// Examples:
+//
+//	duration("90m").format_duration("h")   // return 1.5
+//	duration("1.5s").format_duration("ms")  // return 1500.0
+//
+// # Truncate
+//
+// Returns the result of rounding the timestamp down to a multiple of the
+// provided duration since the zero time. If the duration is not positive
+// the timestamp is returned unchanged:
+//
+//	<timestamp>.truncate(<duration>) -> <timestamp>
+//
+// Examples:
+//
+//	timestamp("2024-06-15T13:45:30Z").truncate(duration("1h"))  // return "2024-06-15T13:00:00Z"
+//
+// # Round
+//
+// Returns the result of rounding the timestamp to the nearest multiple of
+// the provided duration since the zero time. Halfway values are rounded up.
+// If the duration is not positive the timestamp is returned unchanged:
+//
+//	<timestamp>.round(<duration>) -> <timestamp>
+//
+// Examples:
+//
+//	timestamp("2024-06-15T13:45:30Z").round(duration("1h"))  // return "2024-06-15T14:00:00Z"
+//
+// # In Location
+//
+// Returns the timestamp with its location set to the provided IANA time zone
+// name. The instant represented by the timestamp is not changed, only the
+// zone used when it is formatted:
+//
+//	<timestamp>.in_location(<string>) -> <timestamp>
+//
+// Examples:
+//
+//	timestamp("2024-06-15T13:45:30Z").in_location("America/New_York").format(time_layout.RFC3339)  // return "2024-06-15T09:45:30-04:00"
+//
 // # Global Variables
 //
 // A collection of global variable are provided to give access to the start
@@ -106,10 +244,23 @@ import (
 //	    "HTTP":        http.TimeFormat
 //	}
 func Time() cel.EnvOption {
-	return cel.Lib(timeLib{})
+	return TimeWithClock(nil)
+}
+
+// TimeWithClock returns a cel.EnvOption to configure extended functions for
+// handling timestamps as described in Time, using the provided clock for the
+// now function and global variable. If clock is nil, the current time in UTC
+// is used.
+func TimeWithClock(clock func() time.Time) cel.EnvOption {
+	if clock == nil {
+		clock = func() time.Time { return time.Now().In(time.UTC) }
+	}
+	return cel.Lib(timeLib{clock: clock})
 }
 
-type timeLib struct{}
+type timeLib struct {
+	clock func() time.Time
+}
 
 func (timeLib) CompileOptions() []cel.EnvOption {
 	return []cel.EnvOption{
@@ -142,14 +293,127 @@ func (timeLib) CompileOptions() []cel.EnvOption {
 					decls.Timestamp,
 				),
 			),
+			decls.NewFunction("parse_unix",
+				decls.NewOverload(
+					"parse_unix_int",
+					[]*expr.Type{decls.Int},
+					decls.Timestamp,
+				),
+				decls.NewInstanceOverload(
+					"int_parse_unix",
+					[]*expr.Type{decls.Int},
+					decls.Timestamp,
+				),
+			),
+			decls.NewFunction("parse_unix_milli",
+				decls.NewOverload(
+					"parse_unix_milli_int",
+					[]*expr.Type{decls.Int},
+					decls.Timestamp,
+				),
+				decls.NewInstanceOverload(
+					"int_parse_unix_milli",
+					[]*expr.Type{decls.Int},
+					decls.Timestamp,
+				),
+			),
+			decls.NewFunction("parse_unix_nano",
+				decls.NewOverload(
+					"parse_unix_nano_int",
+					[]*expr.Type{decls.Int},
+					decls.Timestamp,
+				),
+				decls.NewInstanceOverload(
+					"int_parse_unix_nano",
+					[]*expr.Type{decls.Int},
+					decls.Timestamp,
+				),
+			),
+			decls.NewFunction("unix",
+				decls.NewInstanceOverload(
+					"timestamp_unix",
+					[]*expr.Type{decls.Timestamp},
+					decls.Int,
+				),
+			),
+			decls.NewFunction("unix_milli",
+				decls.NewInstanceOverload(
+					"timestamp_unix_milli",
+					[]*expr.Type{decls.Timestamp},
+					decls.Int,
+				),
+			),
+			decls.NewFunction("unix_nano",
+				decls.NewInstanceOverload(
+					"timestamp_unix_nano",
+					[]*expr.Type{decls.Timestamp},
+					decls.Int,
+				),
+			),
+			decls.NewFunction("parse_duration",
+				decls.NewInstanceOverload(
+					"string_parse_duration",
+					[]*expr.Type{decls.String},
+					decls.Duration,
+				),
+			),
+			decls.NewFunction("format_duration",
+				decls.NewInstanceOverload(
+					"duration_format_duration_string",
+					[]*expr.Type{decls.Duration, decls.String},
+					decls.Double,
+				),
+			),
+			decls.NewFunction("truncate",
+				decls.NewInstanceOverload(
+					"timestamp_truncate_duration",
+					[]*expr.Type{decls.Timestamp, decls.Duration},
+					decls.Timestamp,
+				),
+			),
+			decls.NewFunction("round",
+				decls.NewInstanceOverload(
+					"timestamp_round_duration",
+					[]*expr.Type{decls.Timestamp, decls.Duration},
+					decls.Timestamp,
+				),
+			),
+			decls.NewFunction("in_location",
+				decls.NewInstanceOverload(
+					"timestamp_in_location_string",
+					[]*expr.Type{decls.Timestamp, decls.String},
+					decls.Timestamp,
+				),
+			),
+			decls.NewFunction("parse_time_strptime",
+				decls.NewInstanceOverload(
+					"string_parse_time_strptime_string",
+					[]*expr.Type{decls.String, decls.String},
+					decls.Timestamp,
+				),
+			),
+			decls.NewFunction("format_strftime",
+				decls.NewInstanceOverload(
+					"timestamp_format_strftime_string",
+					[]*expr.Type{decls.Timestamp, decls.String},
+					decls.String,
+				),
+			),
+			decls.NewFunction("time_bucket_key",
+				decls.NewOverload(
+					"time_bucket_key_timestamp_string",
+					[]*expr.Type{decls.Timestamp, decls.String},
+					decls.String,
+				),
+			),
 		),
 	}
 }
 
-func (timeLib) ProgramOptions() []cel.ProgramOption {
+func (l timeLib) ProgramOptions() []cel.ProgramOption {
 	return []cel.ProgramOption{
 		cel.Globals(map[string]interface{}{
-			"now": func() interface{} { return time.Now().In(time.UTC) },
+			"now": func() interface{} { return l.clock() },
 			"time_layout": map[string]string{
 				"Layout":      time.Layout,
 				"ANSIC":       time.ANSIC,
@@ -173,7 +437,7 @@ func (timeLib) ProgramOptions() []cel.ProgramOption {
 		cel.Functions(
 			&functions.Overload{
 				Operator: "now_void",
-				Function: now,
+				Function: l.now,
 			},
 			&functions.Overload{
 				Operator: "timestamp_format_string",
@@ -187,15 +451,83 @@ func (timeLib) ProgramOptions() []cel.ProgramOption {
 				Operator: "string_parse_time_list_string",
 				Binary:   parseTimeWithLayouts,
 			},
+			&functions.Overload{
+				Operator: "parse_unix_int",
+				Unary:    parseUnix,
+			},
+			&functions.Overload{
+				Operator: "int_parse_unix",
+				Unary:    parseUnix,
+			},
+			&functions.Overload{
+				Operator: "parse_unix_milli_int",
+				Unary:    parseUnixMilli,
+			},
+			&functions.Overload{
+				Operator: "int_parse_unix_milli",
+				Unary:    parseUnixMilli,
+			},
+			&functions.Overload{
+				Operator: "parse_unix_nano_int",
+				Unary:    parseUnixNano,
+			},
+			&functions.Overload{
+				Operator: "int_parse_unix_nano",
+				Unary:    parseUnixNano,
+			},
+			&functions.Overload{
+				Operator: "timestamp_unix",
+				Unary:    unixTime,
+			},
+			&functions.Overload{
+				Operator: "timestamp_unix_milli",
+				Unary:    unixMilli,
+			},
+			&functions.Overload{
+				Operator: "timestamp_unix_nano",
+				Unary:    unixNano,
+			},
+			&functions.Overload{
+				Operator: "string_parse_duration",
+				Unary:    parseDuration,
+			},
+			&functions.Overload{
+				Operator: "duration_format_duration_string",
+				Binary:   formatDuration,
+			},
+			&functions.Overload{
+				Operator: "timestamp_truncate_duration",
+				Binary:   truncateTime,
+			},
+			&functions.Overload{
+				Operator: "timestamp_round_duration",
+				Binary:   roundTime,
+			},
+			&functions.Overload{
+				Operator: "timestamp_in_location_string",
+				Binary:   inLocation,
+			},
+			&functions.Overload{
+				Operator: "string_parse_time_strptime_string",
+				Binary:   parseTimeStrptime,
+			},
+			&functions.Overload{
+				Operator: "timestamp_format_strftime_string",
+				Binary:   formatTimeStrftime,
+			},
+			&functions.Overload{
+				Operator: "time_bucket_key_timestamp_string",
+				Binary:   timeBucketKey,
+			},
 		),
 	}
 }
 
-func now(args ...ref.Val) ref.Val {
+func (l timeLib) now(args ...ref.Val) ref.Val {
 	if len(args) != 0 {
 		return types.NewErr("no such overload")
 	}
-	return types.Timestamp{Time: time.Now().In(time.UTC)}
+	return types.Timestamp{Time: l.clock()}
 }
 
 func formatTime(arg, layout ref.Val) ref.Val {
@@ -246,3 +578,260 @@ func parseTimeWithLayouts(arg, layout ref.Val) ref.Val {
 	}
 	return types.NewErr("failed to parse %s with any provided layout", obj)
 }
+
+func parseUnix(arg ref.Val) ref.Val {
+	sec, ok := arg.(types.Int)
+	if !ok {
+		return types.ValOrErr(sec, "no such overload for parse_unix: %s", arg.Type())
+	}
+	return types.Timestamp{Time: time.Unix(int64(sec), 0).In(time.UTC)}
+}
+
+func parseUnixMilli(arg ref.Val) ref.Val {
+	msec, ok := arg.(types.Int)
+	if !ok {
+		return types.ValOrErr(msec, "no such overload for parse_unix_milli: %s", arg.Type())
+	}
+	return types.Timestamp{Time: time.UnixMilli(int64(msec)).In(time.UTC)}
+}
+
+func parseUnixNano(arg ref.Val) ref.Val {
+	nsec, ok := arg.(types.Int)
+	if !ok {
+		return types.ValOrErr(nsec, "no such overload for parse_unix_nano: %s", arg.Type())
+	}
+	return types.Timestamp{Time: time.Unix(0, int64(nsec)).In(time.UTC)}
+}
+
+func unixTime(arg ref.Val) ref.Val {
+	obj, ok := arg.(types.Timestamp)
+	if !ok {
+		return types.ValOrErr(obj, "no such overload for unix: %s", arg.Type())
+	}
+	return types.Int(obj.Unix())
+}
+
+func unixMilli(arg ref.Val) ref.Val {
+	obj, ok := arg.(types.Timestamp)
+	if !ok {
+		return types.ValOrErr(obj, "no such overload for unix_milli: %s", arg.Type())
+	}
+	return types.Int(obj.UnixMilli())
+}
+
+func unixNano(arg ref.Val) ref.Val {
+	obj, ok := arg.(types.Timestamp)
+	if !ok {
+		return types.ValOrErr(obj, "no such overload for unix_nano: %s", arg.Type())
+	}
+	return types.Int(obj.UnixNano())
+}
+
+func parseDuration(arg ref.Val) ref.Val {
+	obj, ok := arg.(types.String)
+	if !ok {
+		return types.ValOrErr(obj, "no such overload for parse_duration: %s", arg.Type())
+	}
+	d, err := time.ParseDuration(string(obj))
+	if err != nil {
+		return types.NewErr("parse_duration: %s", strings.TrimPrefix(err.Error(), "time: "))
+	}
+	return types.Duration{Duration: d}
+}
+
+// durationUnits are the units supported by format_duration.
+var durationUnits = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"µs": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+}
+
+func formatDuration(arg, unit ref.Val) ref.Val {
+	obj, ok := arg.(types.Duration)
+	if !ok {
+		return types.ValOrErr(obj, "no such overload for format_duration: %s", arg.Type())
+	}
+	u, ok := unit.(types.String)
+	if !ok {
+		return types.ValOrErr(u, "no such overload for format_duration: %s", unit.Type())
+	}
+	scale, ok := durationUnits[string(u)]
+	if !ok {
+		return types.NewErr("format_duration: invalid unit: %q", u)
+	}
+	// Split the division to avoid loss of precision for long durations.
+	whole := obj.Duration / scale
+	frac := obj.Duration % scale
+	return types.Double(float64(whole) + float64(frac)/float64(scale))
+}
+
+func truncateTime(arg, dur ref.Val) ref.Val {
+	obj, ok := arg.(types.Timestamp)
+	if !ok {
+		return types.ValOrErr(obj, "no such overload for truncate: %s", arg.Type())
+	}
+	d, ok := dur.(types.Duration)
+	if !ok {
+		return types.ValOrErr(d, "no such overload for truncate: %s", dur.Type())
+	}
+	return types.Timestamp{Time: obj.Truncate(d.Duration)}
+}
+
+func roundTime(arg, dur ref.Val) ref.Val {
+	obj, ok := arg.(types.Timestamp)
+	if !ok {
+		return types.ValOrErr(obj, "no such overload for round: %s", arg.Type())
+	}
+	d, ok := dur.(types.Duration)
+	if !ok {
+		return types.ValOrErr(d, "no such overload for round: %s", dur.Type())
+	}
+	return types.Timestamp{Time: obj.Round(d.Duration)}
+}
+
+func inLocation(arg, name ref.Val) ref.Val {
+	obj, ok := arg.(types.Timestamp)
+	if !ok {
+		return types.ValOrErr(obj, "no such overload for in_location: %s", arg.Type())
+	}
+	n, ok := name.(types.String)
+	if !ok {
+		return types.ValOrErr(n, "no such overload for in_location: %s", name.Type())
+	}
+	loc, err := time.LoadLocation(string(n))
+	if err != nil {
+		return types.NewErr("in_location: %v", err)
+	}
+	return types.Timestamp{Time: obj.In(loc)}
+}
+
+func parseTimeStrptime(arg, format ref.Val) ref.Val {
+	obj, ok := arg.(types.String)
+	if !ok {
+		return types.ValOrErr(obj, "no such overload for parse_time_strptime: %s", arg.Type())
+	}
+	f, ok := format.(types.String)
+	if !ok {
+		return types.ValOrErr(f, "no such overload for parse_time_strptime: %s", format.Type())
+	}
+	layout, err := strftimeLayout(string(f))
+	if err != nil {
+		return types.NewErr("parse_time_strptime: %v", err)
+	}
+	t, err := time.Parse(layout, string(obj))
+	if err != nil {
+		return types.NewErr("parse_time_strptime: failed to parse %q with %q", obj, f)
+	}
+	return types.Timestamp{Time: t}
+}
+
+func formatTimeStrftime(arg, format ref.Val) ref.Val {
+	obj, ok := arg.(types.Timestamp)
+	if !ok {
+		return types.ValOrErr(obj, "no such overload for format_strftime: %s", arg.Type())
+	}
+	f, ok := format.(types.String)
+	if !ok {
+		return types.ValOrErr(f, "no such overload for format_strftime: %s", format.Type())
+	}
+	layout, err := strftimeLayout(string(f))
+	if err != nil {
+		return types.NewErr("format_strftime: %v", err)
+	}
+	return types.String(obj.Format(layout))
+}
+
+// strftimeDirectives maps strftime directives to Go time layout elements.
+var strftimeDirectives = map[byte]string{
+	'Y': "2006",
+	'y': "06",
+	'm': "01",
+	'b': "Jan",
+	'B': "January",
+	'd': "02",
+	'e': "_2",
+	'a': "Mon",
+	'A': "Monday",
+	'H': "15",
+	'I': "03",
+	'p': "PM",
+	'M': "04",
+	'S': "05",
+	'z': "-0700",
+	'Z': "MST",
+	'F': "2006-01-02",
+	'T': "15:04:05",
+	'D': "01/02/06",
+	'R': "15:04",
+	'%': "%",
+}
+
+// layoutSignificant holds literal text that would be interpreted as a layout
+// element by the time package.
+var layoutSignificant = []string{"Jan", "Mon", "MST", "PM", "pm"}
+
+// strftimeLayout returns the Go time layout equivalent to the strftime
+// format f.
+func strftimeLayout(f string) (string, error) {
+	var layout strings.Builder
+	for len(f) != 0 {
+		idx := strings.IndexByte(f, '%')
+		if idx < 0 {
+			idx = len(f)
+		}
+		lit := f[:idx]
+		if strings.ContainsAny(lit, "0123456789") {
+			return "", fmt.Errorf("invalid literal text in format: %q", lit)
+		}
+		for _, s := range layoutSignificant {
+			if strings.Contains(lit, s) {
+				return "", fmt.Errorf("invalid literal text in format: %q", lit)
+			}
+		}
+		layout.WriteString(lit)
+		f = f[idx:]
+		if len(f) == 0 {
+			break
+		}
+		if len(f) == 1 {
+			return "", errors.New("incomplete directive at end of format")
+		}
+		elem, ok := strftimeDirectives[f[1]]
+		if !ok {
+			return "", fmt.Errorf("unknown directive: %%%c", f[1])
+		}
+		layout.WriteString(elem)
+		f = f[2:]
+	}
+	return layout.String(), nil
+}
+
+// bucketLayouts are the time layouts used to render time bucket keys for
+// each supported granularity.
+var bucketLayouts = map[string]string{
+	"year":   "2006",
+	"month":  "2006-01",
+	"day":    "2006-01-02",
+	"hour":   "2006-01-02T15",
+	"minute": "2006-01-02T15:04",
+}
+
+func timeBucketKey(arg, granularity ref.Val) ref.Val {
+	obj, ok := arg.(types.Timestamp)
+	if !ok {
+		return types.ValOrErr(obj, "no such overload for time_bucket_key: %s", arg.Type())
+	}
+	g, ok := granularity.(types.String)
+	if !ok {
+		return types.ValOrErr(g, "no such overload for time_bucket_key: %s", granularity.Type())
+	}
+	layout, ok := bucketLayouts[string(g)]
+	if !ok {
+		return types.NewErr("invalid time bucket granularity: %q", g)
+	}
+	return types.String(obj.In(time.UTC).Format(layout))
+}
@@ -18,7 +18,9 @@
 package lib
 
 import (
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/google/cel-go/cel"
 	"github.com/google/cel-go/checker/decls"
@@ -47,6 +49,10 @@ import (
 //	try(0/0)            // return "division by zero"
 //	try(0/0, "error")   // return {"error": "division by zero"}
 //
+// If the error holds the raw data that resulted in the error, as is the case
+// for invalid messages in ND-JSON streams read by the NDJSONRawErrors
+// transform, the object also holds the raw data as bytes in the "raw" field.
+//
 // # Is Error
 //
 // is_error returns a bool indicating whether the argument is an error:
@@ -57,11 +63,40 @@ import (
 //
 //	is_error(0/1)            // return false
 //	is_error(0/0)            // return true
+//
+// # Quarantine
+//
+// quarantine returns a standard dead-letter envelope for an event that could
+// not be processed, holding the event, an error object and the time the event
+// was quarantined. The error object holds the provided reason and a message.
+// If the event is an error, the message is the error's text and the event
+// field holds the raw data that resulted in the error as bytes if it is
+// available, as described for try, or null otherwise. If the event is not an
+// error, the message is the reason:
+//
+//	quarantine(<dyn>, <string>) -> <map<string,dyn>>
+//
+// Examples:
+//
+//	quarantine({"id":1}, "missing user")  // return {"event":{"id":1}, "error":{"message":"missing user", "reason":"missing user"}, "@timestamp":<timestamp>}
+//	quarantine(0/0, "bad value")          // return {"event":null, "error":{"message":"division by zero", "reason":"bad value"}, "@timestamp":<timestamp>}
 func Try() cel.EnvOption {
-	return cel.Lib(tryLib{})
+	return TryWithClock(nil)
 }
 
-type tryLib struct{}
+// TryWithClock returns a cel.EnvOption to configure extended functions for
+// handling errors as described in Try, using the provided clock for the
+// quarantine timestamp. If clock is nil, the current time in UTC is used.
+func TryWithClock(clock func() time.Time) cel.EnvOption {
+	if clock == nil {
+		clock = func() time.Time { return time.Now().In(time.UTC) }
+	}
+	return cel.Lib(tryLib{clock: clock})
+}
+
+type tryLib struct {
+	clock func() time.Time
+}
 
 func (tryLib) CompileOptions() []cel.EnvOption {
 	return []cel.EnvOption{
@@ -78,6 +113,13 @@ func (tryLib) CompileOptions() []cel.EnvOption {
 					decls.Dyn,
 				),
 			),
+			decls.NewFunction("quarantine",
+				decls.NewOverload(
+					"quarantine_dyn_string",
+					[]*expr.Type{decls.Dyn, decls.String},
+					decls.NewMapType(decls.String, decls.Dyn),
+				),
+			),
 			decls.NewFunction("is_error",
 				decls.NewOverload(
 					"is_error_dyn",
@@ -89,7 +131,7 @@ func (tryLib) CompileOptions() []cel.EnvOption {
 	}
 }
 
-func (tryLib) ProgramOptions() []cel.ProgramOption {
+func (l tryLib) ProgramOptions() []cel.ProgramOption {
 	return []cel.ProgramOption{
 		cel.Functions(
 			&functions.Overload{
@@ -103,6 +145,13 @@ func (tryLib) ProgramOptions() []cel.ProgramOption {
 				NonStrict: true,
 			},
 		),
+		cel.Functions(
+			&functions.Overload{
+				Operator:  "quarantine_dyn_string",
+				Binary:    l.quarantine,
+				NonStrict: true,
+			},
+		),
 		cel.Functions(
 			&functions.Overload{
 				Operator:  "is_error_dyn",
@@ -126,13 +175,41 @@ func tryMessage(arg, msg ref.Val) ref.Val {
 		return types.NoSuchOverloadErr()
 	}
 	if types.IsError(arg) {
-		return types.NewRefValMap(types.DefaultTypeAdapter, map[ref.Val]ref.Val{
+		m := map[ref.Val]ref.Val{
 			str: types.String(fmt.Sprint(arg)),
-		})
+		}
+		var raw *RawDataError
+		if err, ok := arg.(error); ok && errors.As(err, &raw) {
+			m[types.String("raw")] = types.Bytes(raw.Data)
+		}
+		return types.NewRefValMap(types.DefaultTypeAdapter, m)
 	}
 	return arg
 }
 
+func (l tryLib) quarantine(arg, reason ref.Val) ref.Val {
+	str, ok := reason.(types.String)
+	if !ok {
+		return types.ValOrErr(reason, "no such overload for quarantine")
+	}
+	event, msg := arg, str
+	if types.IsError(arg) {
+		event, msg = types.NullValue, types.String(fmt.Sprint(arg))
+		var raw *RawDataError
+		if err, ok := arg.(error); ok && errors.As(err, &raw) {
+			event = types.Bytes(raw.Data)
+		}
+	}
+	return types.NewRefValMap(types.DefaultTypeAdapter, map[ref.Val]ref.Val{
+		types.String("event"): event,
+		types.String("error"): types.NewRefValMap(types.DefaultTypeAdapter, map[ref.Val]ref.Val{
+			types.String("message"): msg,
+			types.String("reason"):  str,
+		}),
+		types.String("@timestamp"): types.Timestamp{Time: l.clock()},
+	})
+}
+
 func isError(arg ref.Val) ref.Val {
 	return types.Bool(types.IsError(arg))
 }
@@ -18,6 +18,7 @@
 package lib
 
 import (
+	"net/http"
 	"reflect"
 	"time"
 
@@ -41,6 +42,8 @@ var (
 	listV        = decls.NewListType(typeV)
 	listK        = decls.NewListType(typeK)
 	listString   = decls.NewListType(decls.String)
+
+	listMapStringDyn = decls.NewListType(mapStringDyn)
 )
 
 // Types used for conversion to native.
@@ -76,6 +79,7 @@ var (
 var (
 	reflectBoolType                 = reflect.TypeOf(true)
 	reflectByteSliceType            = reflect.TypeOf([]byte(nil))
+	reflectHTTPHeaderType           = reflect.TypeOf(http.Header(nil))
 	reflectIntType                  = reflect.TypeOf(0)
 	reflectInt64Type                = reflect.TypeOf(int64(0))
 	reflectMapStringAnyType         = reflect.TypeOf(map[string]interface{}(nil))
@@ -19,6 +19,7 @@ package lib
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"strings"
 
@@ -193,9 +194,59 @@ func (l xmlLib) decodeXMLWithXSD(arg0, arg1 ref.Val) ref.Val {
 	default:
 		return types.NoSuchOverloadErr()
 	}
+	m, err := xmlDocument(r, details)
+	if err != nil {
+		return types.NewErr("%v", err)
+	}
+	return l.adapter.NativeToValue(m)
+}
+
+// XMLTransform returns a file transform that returns a <map<string,dyn>>
+// from an io.Reader holding an XML document, decoded as for decode_xml
+// using the provided XSD details. The details may be obtained from an XSD
+// document using xml.Details. If details is nil, a best effort decoding
+// leaving all values as strings is performed. The returned transform should
+// be handed to the File or MIME lib with
+//
+//	File(map[string]interface{}{
+//		"application/xml": lib.XMLTransform(details),
+//	})
+//
+// or
+//
+//	MIME(map[string]interface{}{
+//		"application/xml": lib.XMLTransform(details),
+//	})
+//
+// It will then be able to be used in a file or mime call.
+//
+// Example:
+//
+//	Given a file hello.xml:
+//	   <greeting lang="en">hello</greeting>
+//
+//	file('hello.xml', 'application/xml')
+//
+//	will return:
+//
+//	{"doc": {"greeting": {"#text": "hello", "lang": "en"}}}
+func XMLTransform(details map[string]xml.Detail) func(io.Reader) ref.Val {
+	return func(r io.Reader) ref.Val {
+		m, err := xmlDocument(r, details)
+		if err != nil {
+			return types.NewErr("%v", err)
+		}
+		return types.DefaultTypeAdapter.NativeToValue(m)
+	}
+}
+
+// xmlDocument returns the decoded XML document held in r, with the document's
+// elements in the "doc" field and any top-level character data in the "#text"
+// field.
+func xmlDocument(r io.Reader, details map[string]xml.Detail) (map[string]any, error) {
 	cdata, v, err := xml.Unmarshal(r, details)
 	if err != nil {
-		return types.NewErr("failed to unmarshal XML document: %v", err)
+		return nil, fmt.Errorf("failed to unmarshal XML document: %w", err)
 	}
 	m := make(map[string]any)
 	if cdata != "" {
@@ -204,5 +255,5 @@ func (l xmlLib) decodeXMLWithXSD(arg0, arg1 ref.Val) ref.Val {
 	if v != nil {
 		m["doc"] = v
 	}
-	return l.adapter.NativeToValue(m)
+	return m, nil
 }
@@ -26,6 +26,7 @@ import (
 	"compress/gzip"
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"flag"
@@ -42,6 +43,9 @@ import (
 	"github.com/goccy/go-yaml"
 	"github.com/google/cel-go/cel"
 	"github.com/google/cel-go/checker/decls"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
 	"github.com/google/cel-go/interpreter"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/clientcredentials"
@@ -52,6 +56,7 @@ import (
 	"google.golang.org/protobuf/types/known/structpb"
 
 	"github.com/elastic/mito/lib"
+	"github.com/elastic/mito/lib/xml"
 )
 
 const root = "state"
@@ -69,6 +74,10 @@ func Main() int {
 	data := flag.String("data", "", "path to a JSON object holding input (exposed as the label "+root+")")
 	cfgPath := flag.String("cfg", "", "path to a YAML file holding configuration for global vars and regular expressions")
 	insecure := flag.Bool("insecure", false, "disable TLS verification in the HTTP client")
+	exactInts := flag.Bool("exact-ints", false, "render integer results without conversion to floating point")
+	allowWrite := flag.Bool("allow-write", false, "allow file writing functions (temporary files are removed on exit)")
+	canonical := flag.Bool("canonical", false, "render results as compact JSON without HTML escaping (object keys are always sorted)")
+	maxOutput := flag.Int64("max-output", 0, "maximum number of bytes of each result to print (0 for no limit)")
 	version := flag.Bool("version", false, "print version and exit")
 	flag.Parse()
 	if *version {
@@ -82,6 +91,11 @@ func Main() int {
 	libs := []cel.EnvOption{
 		cel.OptionalTypes(cel.OptionalTypesVersion(lib.OptionalTypesVersion)),
 	}
+	var (
+		tlsClientConfig *tls.Config
+		headers         http.Header
+		client          *http.Client // Nil uses http.DefaultClient.
+	)
 	if *cfgPath != "" {
 		f, err := os.Open(*cfgPath)
 		if err != nil {
@@ -96,6 +110,24 @@ func Main() int {
 			fmt.Fprintln(os.Stderr, err)
 			return 2
 		}
+		if len(cfg.Headers) != 0 {
+			headers = make(http.Header)
+			for k, v := range cfg.Headers {
+				headers.Set(k, v)
+			}
+		}
+		if cfg.TLS != nil {
+			tlsClientConfig, err = cfg.TLS.clientConfig()
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 2
+			}
+		}
+		client, err = setClientTLS(nil, tlsClientConfig, *insecure)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
 		if len(cfg.Globals) != 0 {
 			libs = append(libs, lib.Globals(cfg.Globals))
 		}
@@ -120,33 +152,55 @@ func Main() int {
 					return 2
 				}
 				xsds[name] = string(b)
+				details, err := xml.Details(b)
+				if err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					return 2
+				}
+				mimetypes["application/xml; xsd="+name] = lib.XMLTransform(details)
 			}
-			xml, err := lib.XML(nil, xsds)
+			xmlLib, err := lib.XML(nil, xsds)
 			if err != nil {
 				fmt.Fprintln(os.Stderr, err)
 				return 2
 			}
-			libs = append(libs, xml)
+			libs = append(libs, xmlLib)
 		}
 		if cfg.Auth != nil {
 			switch auth := cfg.Auth; {
 			case auth.Basic != nil && auth.OAuth2 != nil:
 				fmt.Fprintln(os.Stderr, "configured basic authentication and OAuth2")
 				return 2
+			case auth.Digest != nil && (auth.Basic != nil || auth.OAuth2 != nil):
+				fmt.Fprintln(os.Stderr, "configured digest authentication with another authentication method")
+				return 2
 			case auth.Basic != nil:
-				libMap["http"] = lib.HTTP(setClientInsecure(nil, *insecure), nil, auth.Basic)
+				libMap["http"] = lib.HTTP(setClientHeaders(client, headers), nil, auth.Basic)
+			case auth.Digest != nil:
+				libMap["http"] = lib.HTTPWithOptions(context.Background(), setClientHeaders(client, headers), nil, nil, lib.HTTPDigestAuth(auth.Digest))
 			case auth.OAuth2 != nil:
-				client, err := oAuth2Client(*auth.OAuth2)
+				// The OAuth2 client wraps the TLS configured client so
+				// that token and resource requests both use the TLS
+				// configuration.
+				oauthClient, err := oAuth2Client(*auth.OAuth2, client)
 				if err != nil {
 					fmt.Fprintln(os.Stderr, err)
 					return 2
 				}
-				libMap["http"] = lib.HTTP(setClientInsecure(client, *insecure), nil, nil)
+				libMap["http"] = lib.HTTP(setClientHeaders(oauthClient, headers), nil, nil)
 			}
 		}
 	}
+	if *cfgPath == "" {
+		var err error
+		client, err = setClientTLS(nil, nil, *insecure)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
 	if libMap["http"] == nil {
-		libMap["http"] = lib.HTTP(setClientInsecure(nil, *insecure), nil, nil)
+		libMap["http"] = lib.HTTP(setClientHeaders(client, headers), nil, nil)
 	}
 	if *use == "all" {
 		for _, l := range libMap {
@@ -162,6 +216,16 @@ func Main() int {
 			libs = append(libs, l)
 		}
 	}
+	if *allowWrite {
+		write, cleanup := lib.WriteFile("")
+		defer func() {
+			err := cleanup()
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}()
+		libs = append(libs, write)
+	}
 	b, err := os.ReadFile(flag.Args()[0])
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
@@ -184,12 +248,27 @@ func Main() int {
 	}
 
 	for {
-		res, val, err := eval(string(b), root, input, libs...)
+		res, val, err := eval(string(b), root, input, *exactInts, libs...)
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err)
 			return 1
 		}
-		fmt.Println(res)
+		if *canonical {
+			res, err = canonicalJSON(val)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 1
+			}
+		}
+		if *maxOutput > 0 {
+			w := &limitWriter{w: os.Stdout, n: *maxOutput}
+			fmt.Fprintln(w, res)
+			if w.truncated {
+				fmt.Printf("\n... output truncated to %d of %d bytes\n", *maxOutput, len(res)+1)
+			}
+		} else {
+			fmt.Println(res)
+		}
 
 		// Check if we want more. This can happen when we have a map
 		// and the map has a true boolean field, want_more.
@@ -205,6 +284,31 @@ func Main() int {
 	return 0
 }
 
+// limitWriter is an io.Writer that writes at most n bytes to w, silently
+// discarding the remainder.
+type limitWriter struct {
+	w         io.Writer
+	n         int64
+	truncated bool
+}
+
+func (w *limitWriter) Write(p []byte) (int, error) {
+	if int64(len(p)) <= w.n {
+		n, err := w.w.Write(p)
+		w.n -= int64(n)
+		return n, err
+	}
+	w.truncated = true
+	if w.n > 0 {
+		n, err := w.w.Write(p[:w.n])
+		w.n -= int64(n)
+		if err != nil {
+			return n, err
+		}
+	}
+	return len(p), nil
+}
+
 func printVersion() int {
 	bi, ok := runtimedebug.ReadBuildInfo()
 	if !ok {
@@ -236,26 +340,75 @@ func printVersion() int {
 	return 0
 }
 
-// setClientInsecure returns an http.Client that will skip TLS certificate
-// verification when insecure is true. If c is nil and insecure is true
-// http.DefaultClient and http.DefaultTransport are used and will be mutated.
-func setClientInsecure(c *http.Client, insecure bool) *http.Client {
-	if !insecure {
-		return c
+// setClientTLS returns an http.Client that will use the provided TLS
+// configuration and will skip TLS certificate verification when insecure is
+// true. If c is nil http.DefaultClient is used. The transport of c, or
+// http.DefaultTransport if c has no transport, is cloned so that neither c
+// nor the default transport are mutated. An error is returned if the
+// transport is not an *http.Transport and the TLS configuration cannot be
+// applied.
+func setClientTLS(c *http.Client, tc *tls.Config, insecure bool) (*http.Client, error) {
+	if tc == nil && !insecure {
+		return c, nil
 	}
 	if c == nil {
 		c = http.DefaultClient
 	}
-	if c.Transport == nil {
-		c.Transport = http.DefaultTransport
+	transport := c.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
 	}
-	t, ok := c.Transport.(*http.Transport)
+	t, ok := transport.(*http.Transport)
 	if !ok {
+		return nil, fmt.Errorf("cannot apply TLS configuration to %T transport", transport)
+	}
+	t = t.Clone()
+	if tc == nil {
+		tc = &tls.Config{}
+	} else {
+		tc = tc.Clone()
+	}
+	tc.InsecureSkipVerify = insecure
+	t.TLSClientConfig = tc
+	cc := *c
+	cc.Transport = t
+	return &cc, nil
+}
+
+// setClientHeaders returns a client that adds the provided headers to
+// every outgoing request that does not already set them. If h is empty
+// c is returned unaltered.
+func setClientHeaders(c *http.Client, h http.Header) *http.Client {
+	if len(h) == 0 {
 		return c
 	}
-	t.TLSClientConfig = &tls.Config{InsecureSkipVerify: insecure}
-	c.Transport = t
-	return c
+	if c == nil {
+		c = http.DefaultClient
+	}
+	cc := *c
+	cc.Transport = headerTransport{header: h, base: c.Transport}
+	return &cc
+}
+
+// headerTransport is an http.RoundTripper that adds default headers
+// to requests.
+type headerTransport struct {
+	header http.Header
+	base   http.RoundTripper // If nil, http.DefaultTransport is used.
+}
+
+func (t headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	req = req.Clone(req.Context())
+	for k, v := range t.header {
+		if _, ok := req.Header[k]; !ok {
+			req.Header[k] = v
+		}
+	}
+	return base.RoundTrip(req)
 }
 
 var (
@@ -270,22 +423,40 @@ var (
 		"mime":        lib.MIME(mimetypes),
 		"http":        nil, // This will be populated by Main.
 		"limit":       lib.Limit(limitPolicies),
+		"number":      lib.Number(),
 		"strings":     lib.Strings(),
 	}
 
 	mimetypes = map[string]interface{}{
-		"text/rot13":               func(r io.Reader) io.Reader { return rot13{r} },
-		"text/upper":               toUpper,
-		"application/gzip":         func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
-		"text/csv; header=present": lib.CSVHeader,
-		"text/csv; header=absent":  lib.CSVNoHeader,
-		"application/x-ndjson":     lib.NDJSON,
-		"application/zip":          lib.Zip,
+		"text/rot13":                                        func(r io.Reader) io.Reader { return rot13{r} },
+		"text/upper":                                        toUpper,
+		"application/gzip":                                  func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		"application/gzip; dir=encode":                      lib.GzipEncode,
+		"application/zstd":                                  lib.ZstdDecode,
+		"application/zstd; dir=encode":                      lib.ZstdEncode,
+		"text/csv; header=present":                          lib.CSVHeader,
+		"text/csv; header=absent":                           lib.CSVNoHeader,
+		"text/tab-separated-values; header=present":         lib.TSVHeader,
+		"text/tab-separated-values; header=absent":          lib.TSVNoHeader,
+		"text/lines":                                        lib.Lines,
+		"text/lines; empty=absent":                          lib.LinesNonEmpty,
+		"application/x-ndjson":                              lib.NDJSON,
+		"application/x-ndjson; errors=compact":              lib.NDJSONCompactErrors,
+		"application/x-ndjson; raw=present":                 lib.NDJSONRawErrors,
+		"application/x-ndjson; errors=compact; raw=present": lib.NDJSONCompactRawErrors,
+		"application/zip":                                   lib.Zip,
+		"application/zip; data=absent":                      lib.ZipMetadata,
+		"application/zip; glob":                             lib.ZipGlob,
+		"application/x-tar":                                 lib.Tar,
+		"application/cbor":                                  lib.CBOR,
+		"application/xml":                                   lib.XMLTransform(nil),
 	}
 
 	limitPolicies = map[string]lib.LimitPolicy{
-		"okta":  lib.OktaRateLimit,
-		"draft": lib.DraftRateLimit,
+		"okta":   lib.OktaRateLimit,
+		"draft":  lib.DraftRateLimit,
+		"draft8": lib.DraftV8RateLimit,
+		"github": lib.GitHubRateLimit,
 	}
 )
 
@@ -297,12 +468,53 @@ func debug(tag string, value any) {
 	fmt.Fprintf(os.Stderr, "%s: logging %q: %v\n", level, tag, value)
 }
 
-func eval(src, root string, input interface{}, libs ...cel.EnvOption) (string, any, error) {
+// Stage is the evaluation stage at which an Error occurred.
+type Stage int
+
+const (
+	Compile Stage = iota + 1 // Environment construction or compilation.
+	Program                  // Program instantiation.
+	Eval                     // Program evaluation.
+	Convert                  // Conversion of the result to JSON.
+)
+
+func (s Stage) String() string {
+	switch s {
+	case Compile:
+		return "compile"
+	case Program:
+		return "program"
+	case Eval:
+		return "eval"
+	case Convert:
+		return "convert"
+	default:
+		return fmt.Sprintf("Stage(%d)", int(s))
+	}
+}
+
+// Error is an error returned during evaluation of a CEL program. The Stage
+// field indicates where in the evaluation the failure happened.
+type Error struct {
+	Stage Stage
+	Msg   string
+	Err   error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %v", e.Msg, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func eval(src, root string, input interface{}, exact bool, libs ...cel.EnvOption) (string, any, error) {
 	prg, ast, err := compile(src, root, libs...)
 	if err != nil {
-		return "", nil, fmt.Errorf("failed program instantiation: %v", err)
+		return "", nil, fmt.Errorf("failed program instantiation: %w", err)
 	}
-	return run(prg, ast, false, input)
+	return run(prg, ast, false, exact, input)
 }
 
 func compile(src, root string, libs ...cel.EnvOption) (cel.Program, *cel.Ast, error) {
@@ -311,50 +523,122 @@ func compile(src, root string, libs ...cel.EnvOption) (cel.Program, *cel.Ast, er
 	}, libs...)
 	env, err := cel.NewEnv(opts...)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to create env: %v", err)
+		return nil, nil, &Error{Stage: Compile, Msg: "failed to create env", Err: err}
 	}
 
 	ast, iss := env.Compile(src)
 	if iss.Err() != nil {
-		return nil, nil, fmt.Errorf("failed compilation: %v", iss.Err())
+		return nil, nil, &Error{Stage: Compile, Msg: "failed compilation", Err: iss.Err()}
 	}
 
 	prg, err := env.Program(ast)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed program instantiation: %v", err)
+		return nil, nil, &Error{Stage: Program, Msg: "failed program instantiation", Err: err}
 	}
 	return prg, ast, nil
 }
 
-func run(prg cel.Program, ast *cel.Ast, fast bool, input interface{}) (string, any, error) {
+// run evaluates prg with the provided input and renders the result as JSON.
+// If fast is true, the result is rendered with protojson without indentation.
+// If exact is true, integer values are rendered without conversion through
+// float64, preserving their precision; exact takes precedence over fast.
+func run(prg cel.Program, ast *cel.Ast, fast, exact bool, input interface{}) (string, any, error) {
 	if input == nil {
 		input = interpreter.EmptyActivation()
 	}
 	out, _, err := prg.Eval(input)
 	if err != nil {
-		return "", nil, fmt.Errorf("failed eval: %v", lib.DecoratedError{AST: ast, Err: err})
+		return "", nil, &Error{Stage: Eval, Msg: "failed eval", Err: lib.DecoratedError{AST: ast, Err: err}}
 	}
 
+	var val any
+	if exact {
+		val, err = exactNative(out)
+		if err != nil {
+			return "", nil, &Error{Stage: Convert, Msg: "failed native conversion", Err: err}
+		}
+		return encode(val)
+	}
 	v, err := out.ConvertToNative(reflect.TypeOf(&structpb.Value{}))
 	if err != nil {
-		return "", nil, fmt.Errorf("failed proto conversion: %v", err)
+		return "", nil, &Error{Stage: Convert, Msg: "failed proto conversion", Err: err}
 	}
-	val := v.(*structpb.Value).AsInterface()
+	val = v.(*structpb.Value).AsInterface()
 	if fast {
 		b, err := protojson.MarshalOptions{}.Marshal(v.(proto.Message))
 		if err != nil {
-			return "", nil, fmt.Errorf("failed native conversion: %v", err)
+			return "", nil, &Error{Stage: Convert, Msg: "failed native conversion", Err: err}
 		}
 		return string(b), val, nil
 	}
+	return encode(val)
+}
+
+func encode(val any) (string, any, error) {
 	var buf strings.Builder
 	enc := json.NewEncoder(&buf)
 	enc.SetEscapeHTML(false)
 	enc.SetIndent("", "\t")
-	err = enc.Encode(val)
+	err := enc.Encode(val)
 	return strings.TrimRight(buf.String(), "\n"), val, err
 }
 
+// canonicalJSON renders val as compact JSON without HTML escaping. Object
+// keys are sorted by the encoding/json package.
+func canonicalJSON(val any) (string, error) {
+	var buf strings.Builder
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	err := enc.Encode(val)
+	return strings.TrimRight(buf.String(), "\n"), err
+}
+
+// exactNative converts v to a JSON-compatible Go value, retaining int and
+// uint values as int64 and uint64 rather than converting them to float64.
+func exactNative(v ref.Val) (any, error) {
+	switch v := v.(type) {
+	case types.Int:
+		return int64(v), nil
+	case types.Uint:
+		return uint64(v), nil
+	case traits.Mapper:
+		m := make(map[string]any)
+		it := v.Iterator()
+		for it.HasNext() == types.True {
+			k := it.Next()
+			key, err := k.ConvertToNative(reflect.TypeOf(""))
+			if err != nil {
+				return nil, err
+			}
+			m[key.(string)], err = exactNative(v.Get(k))
+			if err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	case traits.Lister:
+		var l []any
+		it := v.Iterator()
+		for it.HasNext() == types.True {
+			e, err := exactNative(it.Next())
+			if err != nil {
+				return nil, err
+			}
+			l = append(l, e)
+		}
+		if l == nil {
+			l = []any{}
+		}
+		return l, nil
+	default:
+		pv, err := v.ConvertToNative(reflect.TypeOf(&structpb.Value{}))
+		if err != nil {
+			return nil, err
+		}
+		return pv.(*structpb.Value).AsInterface(), nil
+	}
+}
+
 // rot13 is provided for testing purposes.
 type rot13 struct {
 	r io.Reader
@@ -390,11 +674,86 @@ type config struct {
 	Regexps map[string]string      `yaml:"regexp"`
 	XSDs    map[string]string      `yaml:"xsd"`
 	Auth    *authConfig            `yaml:"auth"`
+	TLS     *tlsConfig             `yaml:"tls"`
+
+	// Headers are default headers added to all HTTP requests
+	// that do not already set them.
+	Headers map[string]string `yaml:"headers"`
+}
+
+// tlsConfig is the TLS configuration for the HTTP client. CertFile and
+// KeyFile are the paths to a PEM encoded client certificate and key for
+// mutual TLS, and CAFile is the path to a PEM encoded set of root
+// certificate authorities used to verify servers in place of the system
+// roots. Certificates and keys may also be provided directly as PEM text.
+type tlsConfig struct {
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+	CAFile   string `yaml:"ca_file"`
+
+	Cert string `yaml:"cert"`
+	Key  string `yaml:"key"`
+	CA   string `yaml:"ca"`
+}
+
+func (c *tlsConfig) clientConfig() (*tls.Config, error) {
+	cert, err := c.pem("cert", c.Cert, c.CertFile)
+	if err != nil {
+		return nil, err
+	}
+	key, err := c.pem("key", c.Key, c.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	ca, err := c.pem("ca", c.CA, c.CAFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var tc tls.Config
+	switch {
+	case cert != nil && key != nil:
+		pair, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			return nil, fmt.Errorf("tls: invalid client certificate: %w", err)
+		}
+		tc.Certificates = []tls.Certificate{pair}
+	case cert != nil:
+		return nil, errors.New("tls: client certificate configured without key")
+	case key != nil:
+		return nil, errors.New("tls: client key configured without certificate")
+	}
+	if ca != nil {
+		tc.RootCAs = x509.NewCertPool()
+		if !tc.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, errors.New("tls: no valid certificate authorities")
+		}
+	}
+	return &tc, nil
+}
+
+// pem returns the PEM data configured either as text or as a file path.
+func (c *tlsConfig) pem(name, text, path string) ([]byte, error) {
+	switch {
+	case text != "" && path != "":
+		return nil, fmt.Errorf("tls: %s configured as both text and file", name)
+	case text != "":
+		return []byte(text), nil
+	case path != "":
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("tls: %w", err)
+		}
+		return b, nil
+	default:
+		return nil, nil
+	}
 }
 
 type authConfig struct {
-	Basic  *lib.BasicAuth `yaml:"basic"`
-	OAuth2 *oAuth2        `yaml:"oauth2"`
+	Basic  *lib.BasicAuth  `yaml:"basic"`
+	Digest *lib.DigestAuth `yaml:"digest"`
+	OAuth2 *oAuth2         `yaml:"oauth2"`
 }
 
 type oAuth2 struct {
@@ -418,8 +777,14 @@ type oAuth2 struct {
 	AzureResource string `yaml:"azure.resource"`
 }
 
-func oAuth2Client(cfg oAuth2) (*http.Client, error) {
-	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{})
+// oAuth2Client returns an OAuth2 client configured by cfg. Token and
+// resource requests are made using base, or a default client if base is
+// nil.
+func oAuth2Client(cfg oAuth2, base *http.Client) (*http.Client, error) {
+	if base == nil {
+		base = &http.Client{}
+	}
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, base)
 
 	switch prov := strings.ToLower(cfg.Provider); prov {
 	case "":
@@ -309,7 +309,7 @@ func BenchmarkMito(b *testing.B) {
 			b.StartTimer()
 
 			for i := 0; i < b.N; i++ {
-				v, _, err := run(prg, ast, *fastMarshal, state)
+				v, _, err := run(prg, ast, *fastMarshal, false, state)
 				if err != nil {
 					b.Fatalf("failed operation: %v", err)
 				}
@@ -18,17 +18,43 @@
 package mito
 
 import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"math/big"
+	mathrand "math/rand"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/google/cel-go/cel"
 	"github.com/google/cel-go/interpreter"
 	"github.com/google/go-cmp/cmp"
 	"github.com/rogpeppe/go-internal/testscript"
@@ -54,6 +80,7 @@ func TestScripts(t *testing.T) {
 			"base64":    bas64decode,
 			"serve":     serve,
 			"serve_tls": serveTLS,
+			"serve_hdr": serveHeaders,
 			"expand":    expand,
 		},
 	}
@@ -116,6 +143,22 @@ func server(ts *testscript.TestScript, neg bool, name string, newServer func(han
 	ts.Defer(func() { srv.Close() })
 }
 
+// serveHeaders starts a server that responds with the JSON encoding
+// of the request's headers.
+func serveHeaders(ts *testscript.TestScript, neg bool, args []string) {
+	if neg {
+		ts.Fatalf("unsupported: ! serve_hdr")
+	}
+	if len(args) != 0 {
+		ts.Fatalf("usage: serve_hdr")
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		json.NewEncoder(w).Encode(req.Header)
+	}))
+	ts.Setenv("URL", srv.URL)
+	ts.Defer(func() { srv.Close() })
+}
+
 func expand(ts *testscript.TestScript, neg bool, args []string) {
 	if neg {
 		ts.Fatalf("unsupported: ! expand")
@@ -144,7 +187,7 @@ func TestSend(t *testing.T) {
 		got = <-chans["ch"]
 	}()
 
-	res, _, err := eval(`42.send_to("ch").close("ch")`, "", nil, send)
+	res, _, err := eval(`42.send_to("ch").close("ch")`, "", nil, false, send)
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
@@ -243,7 +286,7 @@ func TestVars(t *testing.T) {
 }`
 	)
 
-	got, _, err := eval(src, "", interpreter.EmptyActivation(), vars)
+	got, _, err := eval(src, "", interpreter.EmptyActivation(), false, vars)
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
@@ -359,7 +402,7 @@ var regexpTests = []struct {
 func TestRegaxp(t *testing.T) {
 	for _, test := range regexpTests {
 		t.Run(test.name, func(t *testing.T) {
-			got, _, err := eval(test.src, "", interpreter.EmptyActivation(), lib.Regexp(test.regexps))
+			got, _, err := eval(test.src, "", interpreter.EmptyActivation(), false, lib.Regexp(test.regexps))
 			if err != nil {
 				t.Errorf("unexpected error: %v", err)
 			}
@@ -369,3 +412,1384 @@ func TestRegaxp(t *testing.T) {
 		})
 	}
 }
+
+var errorStageTests = []struct {
+	name    string
+	src     string
+	want    Stage
+	wantMsg string
+}{
+	{
+		name:    "compile",
+		src:     `1 +`,
+		want:    Compile,
+		wantMsg: "failed program instantiation: failed compilation: ",
+	},
+	{
+		name:    "eval",
+		src:     `1/0`,
+		want:    Eval,
+		wantMsg: "failed eval: ",
+	},
+}
+
+func TestErrorStage(t *testing.T) {
+	for _, test := range errorStageTests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, err := eval(test.src, "", nil, false)
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			var e *Error
+			if !errors.As(err, &e) {
+				t.Fatalf("unexpected error type: %T", err)
+			}
+			if e.Stage != test.want {
+				t.Errorf("unexpected stage: got:%v want:%v", e.Stage, test.want)
+			}
+			if !strings.HasPrefix(err.Error(), test.wantMsg) {
+				t.Errorf("unexpected error message: got:%q want prefix:%q", err, test.wantMsg)
+			}
+		})
+	}
+}
+
+// TestEvalPanic checks that a panic during evaluation, recovered by
+// cel.Program.Eval as an internal error, is reported as an Eval stage error
+// with the panic message retained.
+func TestEvalPanic(t *testing.T) {
+	src := `request("GET", "http://localhost/").with({"MultipartForm": {}}).do_request()`
+	_, _, err := eval(src, "", nil, false, lib.Collections(), lib.HTTP(nil, nil, nil))
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	var e *Error
+	if !errors.As(err, &e) {
+		t.Fatalf("unexpected error type: %T", err)
+	}
+	if e.Stage != Eval {
+		t.Errorf("unexpected stage: got:%v want:%v", e.Stage, Eval)
+	}
+	if !strings.Contains(err.Error(), "TODO") {
+		t.Errorf("panic message not preserved: %v", err)
+	}
+}
+
+var exactIntTests = []struct {
+	name  string
+	src   string
+	exact bool
+	want  string
+}{
+	{
+		name: "default",
+		src:  `{"int": 9223372036854775807}`,
+		want: "{\n\t\"int\": \"9223372036854775807\"\n}",
+	},
+	{
+		name:  "exact",
+		src:   `{"int": 9223372036854775807, "uint": 18446744073709551615u, "list": [9007199254740993, 1.5, "a"]}`,
+		exact: true,
+		want:  "{\n\t\"int\": 9223372036854775807,\n\t\"list\": [\n\t\t9007199254740993,\n\t\t1.5,\n\t\t\"a\"\n\t],\n\t\"uint\": 18446744073709551615\n}",
+	},
+}
+
+func TestExactInts(t *testing.T) {
+	for _, test := range exactIntTests {
+		t.Run(test.name, func(t *testing.T) {
+			got, _, err := eval(test.src, "", nil, test.exact)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != test.want {
+				t.Errorf("unexpected result: got:- want:+\n%v", cmp.Diff(got, test.want))
+			}
+		})
+	}
+}
+
+func TestCachedTransform(t *testing.T) {
+	var calls int
+	mime := lib.MIME(map[string]interface{}{
+		"text/upper": lib.CachedTransform(func(p []byte) {
+			calls++
+			toUpper(p)
+		}),
+	})
+	src := `[b"hello world!".mime("text/upper"), b"hello world!".mime("text/upper")].map(b, string(b))`
+	got, _, err := eval(src, "", nil, false, mime)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "[\n\t\"HELLO WORLD!\",\n\t\"HELLO WORLD!\"\n]"
+	if got != want {
+		t.Errorf("unexpected result: got:- want:+\n%v", cmp.Diff(got, want))
+	}
+	if calls != 1 {
+		t.Errorf("unexpected number of transform calls: got:%d want:1", calls)
+	}
+}
+
+var jsonStreamLimitTests = []struct {
+	name     string
+	limit    int
+	truncate bool
+	want     string
+	wantErr  string
+}{
+	{
+		name: "no_limit",
+		want: "[\n\t1,\n\t2,\n\t3\n]",
+	},
+	{
+		name:  "under_limit",
+		limit: 3,
+		want:  "[\n\t1,\n\t2,\n\t3\n]",
+	},
+	{
+		name:     "truncate",
+		limit:    2,
+		truncate: true,
+		want:     "[\n\t1,\n\t2\n]",
+	},
+	{
+		name:    "error",
+		limit:   2,
+		wantErr: "failed to unmarshal JSON stream: exceeded element limit of 2",
+	},
+}
+
+func TestJSONStreamLimit(t *testing.T) {
+	for _, test := range jsonStreamLimitTests {
+		t.Run(test.name, func(t *testing.T) {
+			got, _, err := eval(`"1 2 3".decode_json_stream()`, "", nil, false, lib.JSONWithStreamLimit(nil, test.limit, test.truncate))
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Errorf("unexpected error: got:%v want:%s", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != test.want {
+				t.Errorf("unexpected result: got:- want:+\n%v", cmp.Diff(got, test.want))
+			}
+		})
+	}
+}
+
+func TestDigestAuth(t *testing.T) {
+	const (
+		user   = "Mufasa"
+		pass   = "Circle of Life"
+		realm  = "http-auth@example.org"
+		nonce  = "7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v"
+		opaque = "FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"
+	)
+	paramPattern := regexp.MustCompile(`(\w+)=(?:"([^"]*)"|([^,\s]*))`)
+	h := func(s string) string {
+		sum := md5.Sum([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+
+	var (
+		mu       sync.Mutex
+		requests int
+		counts   []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		auth := req.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "Digest ") {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Digest realm=%q, qop="auth, auth-int", algorithm=MD5, nonce=%q, opaque=%q`, realm, nonce, opaque))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		p := make(map[string]string)
+		for _, m := range paramPattern.FindAllStringSubmatch(auth, -1) {
+			p[m[1]] = m[2] + m[3]
+		}
+		want := h(h(user+":"+realm+":"+pass) + ":" + nonce + ":" + p["nc"] + ":" + p["cnonce"] + ":auth:" + h(req.Method+":"+req.URL.RequestURI()))
+		if p["username"] != user || p["realm"] != realm || p["nonce"] != nonce || p["opaque"] != opaque || p["qop"] != "auth" || p["response"] != want {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprintf(w, "invalid authorization: %s", auth)
+			return
+		}
+		counts = append(counts, p["nc"])
+		body, _ := io.ReadAll(req.Body)
+		fmt.Fprintf(w, "%s %s", req.Method, body)
+	}))
+	defer srv.Close()
+
+	src := fmt.Sprintf(`[get(%[1]q), post(%[1]q, "text/plain", "hello"), request("PUT", %[1]q, "world").do_request()].map(r, string(r.Body))`, srv.URL)
+	got, _, err := eval(src, "", nil, false, lib.HTTPWithOptions(context.Background(), srv.Client(), nil, nil, lib.HTTPDigestAuth(&lib.DigestAuth{Username: user, Password: pass})))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "[\n\t\"GET \",\n\t\"POST hello\",\n\t\"PUT world\"\n]"
+	if got != want {
+		t.Errorf("unexpected result: got:- want:+\n%v", cmp.Diff(got, want))
+	}
+	if requests != 4 {
+		t.Errorf("unexpected number of requests: got:%d want:4", requests)
+	}
+	wantCounts := []string{"00000001", "00000002", "00000003"}
+	if !cmp.Equal(counts, wantCounts) {
+		t.Errorf("unexpected nonce counts: got:- want:+\n%v", cmp.Diff(counts, wantCounts))
+	}
+}
+
+func TestDigestAuthStale(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		stale := req.Header.Get("Authorization") != ""
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Digest realm="test", qop="auth", nonce="%d", stale=%t`, time.Now().UnixNano(), stale))
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	src := fmt.Sprintf(`get(%q)`, srv.URL)
+	_, _, err := eval(src, "", nil, false, lib.HTTPWithOptions(context.Background(), srv.Client(), nil, nil, lib.HTTPDigestAuth(&lib.DigestAuth{Username: "user", Password: "pass"})))
+	if err == nil || !strings.Contains(err.Error(), "repeated stale nonce challenge") {
+		t.Errorf("unexpected error: got:%v want:repeated stale nonce challenge", err)
+	}
+}
+
+func TestDoRequestRetry(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests = make(map[string]int)
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		requests[req.URL.Path]++
+		n := requests[req.URL.Path]
+		mu.Unlock()
+		switch req.URL.Path {
+		case "/flaky":
+			if n < 3 {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			body, _ := io.ReadAll(req.Body)
+			fmt.Fprintf(w, "ok %s", body)
+		case "/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	src := fmt.Sprintf(`[
+	request("POST", %[1]q+"/flaky", "data").do_request({"max_attempts": 5, "initial_backoff": duration("1ms")}),
+	request("GET", %[1]q+"/broken").do_request({"max_attempts": 2, "initial_backoff": duration("1ms")}),
+	request("GET", %[1]q+"/missing").do_request({"initial_backoff": duration("1ms")}),
+	request("GET", %[1]q+"/missing").do_request({"initial_backoff": duration("1ms"), "retry_on": [404]}),
+].map(r, [string(r.Body), r.StatusCode, r.Attempts])`, srv.URL)
+	got, _, err := eval(src, "", nil, false, lib.HTTP(srv.Client(), nil, nil), lib.Collections())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `[
+	[
+		"ok data",
+		200,
+		3
+	],
+	[
+		"",
+		500,
+		2
+	],
+	[
+		"",
+		404,
+		1
+	],
+	[
+		"",
+		404,
+		3
+	]
+]`
+	if got != want {
+		t.Errorf("unexpected result: got:- want:+\n%v", cmp.Diff(got, want))
+	}
+}
+
+func TestHTTPMetrics(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		w.Write([]byte("hello"))
+	}))
+	defer srv.Close()
+
+	src := fmt.Sprintf(`[get(%[1]q), post(%[1]q, "text/plain", "data"), request("GET", %[1]q).do_request()].map(r, [
+	has(r.Duration) && r.Duration >= duration("10ms"),
+	has(r.StartedAt) && r.StartedAt <= now(),
+])`, srv.URL)
+	for _, test := range []struct {
+		name string
+		http cel.EnvOption
+		want string
+	}{
+		{
+			name: "metrics",
+			http: lib.HTTPWithOptions(context.Background(), srv.Client(), nil, nil, lib.HTTPMetrics()),
+			want: "[\n\t[\n\t\ttrue,\n\t\ttrue\n\t],\n\t[\n\t\ttrue,\n\t\ttrue\n\t],\n\t[\n\t\ttrue,\n\t\ttrue\n\t]\n]",
+		},
+		{
+			name: "metrics_with_options",
+			http: lib.HTTPWithOptions(context.Background(), srv.Client(), nil, nil,
+				lib.HTTPMetrics(),
+				lib.HTTPCookieJar(nil),
+				lib.HTTPIdempotencyKeys(),
+				lib.HTTPRedirectLimit(1),
+				lib.HTTPJitter(time.Millisecond, nil),
+				lib.HTTPResponseHeaders([]string{"Content-Type"}),
+			),
+			want: "[\n\t[\n\t\ttrue,\n\t\ttrue\n\t],\n\t[\n\t\ttrue,\n\t\ttrue\n\t],\n\t[\n\t\ttrue,\n\t\ttrue\n\t]\n]",
+		},
+		{
+			name: "no_metrics",
+			http: lib.HTTP(srv.Client(), nil, nil),
+			want: "[\n\t[\n\t\tfalse,\n\t\tfalse\n\t],\n\t[\n\t\tfalse,\n\t\tfalse\n\t],\n\t[\n\t\tfalse,\n\t\tfalse\n\t]\n]",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got, _, err := eval(src, "", nil, false, test.http, lib.Time())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != test.want {
+				t.Errorf("unexpected result: got:- want:+\n%v", cmp.Diff(got, test.want))
+			}
+		})
+	}
+}
+
+func TestCookieJar(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "secret", Path: "/"})
+		case "/data":
+			c, err := req.Cookie("session")
+			if err != nil {
+				w.Write([]byte("no session"))
+				return
+			}
+			w.Write([]byte(c.Value))
+		}
+	}))
+	defer srv.Close()
+
+	src := fmt.Sprintf(`{
+	"login": get_request(%[1]q+"/login").do_request().StatusCode,
+	"cookies": cookies(%[1]q),
+}.as(s, s.with({
+	"data": string(get_request(%[1]q+"/data").do_request().Body),
+}))`, srv.URL)
+	for _, test := range []struct {
+		name    string
+		http    cel.EnvOption
+		want    string
+		wantErr string
+	}{
+		{
+			name: "jar",
+			http: lib.HTTPWithOptions(context.Background(), srv.Client(), nil, nil, lib.HTTPCookieJar(nil)),
+			want: `{
+	"cookies": [
+		{
+			"Name": "session",
+			"Value": "secret"
+		}
+	],
+	"data": "secret",
+	"login": 200
+}`,
+		},
+		{
+			name:    "no_jar",
+			http:    lib.HTTP(srv.Client(), nil, nil),
+			wantErr: "cookies: no cookie jar",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got, _, err := eval(src, "", nil, false, test.http, lib.Collections())
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Errorf("unexpected error: got:%v want:%s", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != test.want {
+				t.Errorf("unexpected result: got:- want:+\n%v", cmp.Diff(got, test.want))
+			}
+		})
+	}
+}
+
+func TestGetAll(t *testing.T) {
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/1":
+			w.Header().Add("Link", `</2>; rel="next", </1>; rel="first"`)
+		case "/2":
+			w.Header().Add("Link", `</1>; rel=prev`)
+			w.Header().Add("Link", `</3>; title="page;3"; rel="next last"`)
+		case "/broken":
+			w.Header().Add("Link", "<"+closed.URL+`>; rel="next"`)
+		}
+		w.Write([]byte(req.URL.Path))
+	}))
+	defer srv.Close()
+
+	for _, test := range []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "all",
+			src:  fmt.Sprintf(`get_all(%q).map(r, string(r.Body))`, srv.URL+"/1"),
+			want: "[\n\t\"/1\",\n\t\"/2\",\n\t\"/3\"\n]",
+		},
+		{
+			name: "limit",
+			src:  fmt.Sprintf(`get_all(%q, 2).map(r, string(r.Body))`, srv.URL+"/1"),
+			want: "[\n\t\"/1\",\n\t\"/2\"\n]",
+		},
+		{
+			name: "error",
+			src:  fmt.Sprintf(`get_all(%q).map(r, has(r.Error) ? r.URL : string(r.Body))`, srv.URL+"/broken"),
+			want: fmt.Sprintf("[\n\t\"/broken\",\n\t%q\n]", closed.URL),
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got, _, err := eval(test.src, "", nil, false, lib.HTTP(srv.Client(), nil, nil))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != test.want {
+				t.Errorf("unexpected result: got:- want:+\n%v", cmp.Diff(got, test.want))
+			}
+		})
+	}
+}
+
+func TestHTTPJitter(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		times []time.Time
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		times = append(times, time.Now())
+		mu.Unlock()
+		w.Write([]byte("hello"))
+	}))
+	defer srv.Close()
+
+	const (
+		seed = 1
+		max  = 50 * time.Millisecond
+	)
+	// Replicate the jitter sequence to find the delay before the second request.
+	rnd := mathrand.New(mathrand.NewSource(seed))
+	rnd.Int63n(int64(max) + 1)
+	floor := time.Duration(rnd.Int63n(int64(max) + 1))
+
+	src := fmt.Sprintf(`[get(%[1]q), get_request(%[1]q).do_request()].map(r, r.StatusCode)`, srv.URL)
+	got, _, err := eval(src, "", nil, false, lib.HTTPWithOptions(context.Background(), srv.Client(), nil, nil, lib.HTTPJitter(max, mathrand.NewSource(seed))))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "[\n\t200,\n\t200\n]"
+	if got != want {
+		t.Errorf("unexpected result: got:- want:+\n%v", cmp.Diff(got, want))
+	}
+	if len(times) != 2 {
+		t.Fatalf("unexpected number of requests: got:%d want:2", len(times))
+	}
+	if gap := times[1].Sub(times[0]); gap < floor {
+		t.Errorf("unexpected time between requests: got:%v want:>=%v", gap, floor)
+	}
+}
+
+func TestCompressBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var (
+			r   io.Reader
+			err error
+		)
+		switch enc := req.Header.Get("Content-Encoding"); enc {
+		case "gzip":
+			r, err = gzip.NewReader(req.Body)
+		case "deflate":
+			r, err = zlib.NewReader(req.Body)
+		default:
+			err = fmt.Errorf("unexpected content encoding: %q", enc)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body, err := io.ReadAll(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.ContentLength == int64(len(body)) {
+			http.Error(w, "content length not updated", http.StatusBadRequest)
+			return
+		}
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	for _, coding := range []string{"gzip", "deflate"} {
+		t.Run(coding, func(t *testing.T) {
+			src := fmt.Sprintf(`post_request(%q, "application/json", {"message": "hello world"}.encode_json()).compress_body(%q).do_request().as(r, {
+	"status": r.StatusCode,
+	"body": string(r.Body),
+})`, srv.URL, coding)
+			got, _, err := eval(src, "", nil, false, lib.HTTP(srv.Client(), nil, nil), lib.JSON(nil), lib.Collections())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			want := "{\n\t\"body\": \"{\\\"message\\\":\\\"hello world\\\"}\",\n\t\"status\": 200\n}"
+			if got != want {
+				t.Errorf("unexpected result: got:- want:+\n%v", cmp.Diff(got, want))
+			}
+		})
+	}
+}
+
+func TestRedirectPolicy(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/a":
+			http.Redirect(w, req, "/b", http.StatusFound)
+		case "/b":
+			http.Redirect(w, req, "/c", http.StatusFound)
+		default:
+			w.Write([]byte(req.URL.Path))
+		}
+	}))
+	defer srv.Close()
+
+	src := fmt.Sprintf(`get(%q).as(r, [r.StatusCode, r.FinalURL])`, srv.URL+"/a")
+	for _, test := range []struct {
+		name string
+		http cel.EnvOption
+		want string
+	}{
+		{
+			name: "default",
+			http: lib.HTTP(srv.Client(), nil, nil),
+			want: fmt.Sprintf("[\n\t200,\n\t%q\n]", srv.URL+"/c"),
+		},
+		{
+			name: "cap",
+			http: lib.HTTPWithOptions(context.Background(), srv.Client(), nil, nil, lib.HTTPRedirectLimit(1)),
+			want: fmt.Sprintf("[\n\t302,\n\t%q\n]", srv.URL+"/b"),
+		},
+		{
+			name: "disabled",
+			http: lib.HTTPWithOptions(context.Background(), srv.Client(), nil, nil, lib.HTTPRedirectLimit(0)),
+			want: fmt.Sprintf("[\n\t302,\n\t%q\n]", srv.URL+"/a"),
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got, _, err := eval(src, "", nil, false, test.http, lib.Collections())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != test.want {
+				t.Errorf("unexpected result: got:- want:+\n%v", cmp.Diff(got, test.want))
+			}
+		})
+	}
+}
+
+func TestDynamicGlobals(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		vals = map[string]interface{}{"greeting": "hello", "count": 1}
+	)
+	provider := func() map[string]interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+		return vals
+	}
+	prg, ast, err := compile(`greeting + " " + string(count)`, root, lib.DynamicGlobals(provider))
+	if err != nil {
+		t.Fatalf("unexpected error compiling program: %v", err)
+	}
+
+	for _, test := range []struct {
+		vals map[string]interface{}
+		want string
+	}{
+		{want: `"hello 1"`},
+		{vals: map[string]interface{}{"greeting": "goodbye", "count": 2}, want: `"goodbye 2"`},
+	} {
+		if test.vals != nil {
+			mu.Lock()
+			vals = test.vals
+			mu.Unlock()
+		}
+		got, _, err := run(prg, ast, false, false, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != test.want {
+			t.Errorf("unexpected result: got:%s want:%s", got, test.want)
+		}
+	}
+}
+
+func TestBase64DecodeToFile(t *testing.T) {
+	data := make([]byte, 1<<20)
+	_, err := mathrand.New(mathrand.NewSource(1)).Read(data)
+	if err != nil {
+		t.Fatalf("unexpected error generating data: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "decoded.bin")
+	input := map[string]any{
+		"data": base64.StdEncoding.EncodeToString(data),
+		"path": path,
+	}
+
+	write, cleanup := lib.WriteFile("")
+	defer cleanup()
+	got, _, err := eval(`base64_decode_to_file(state.data, state.path)`, root, map[string]any{root: input}, false, write)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := fmt.Sprint(len(data)); got != want {
+		t.Errorf("unexpected result: got:%s want:%s", got, want)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error reading decoded file: %v", err)
+	}
+	if !bytes.Equal(b, data) {
+		t.Error("decoded file does not match original data")
+	}
+
+	_, _, err = eval(`base64_decode_to_file("not base64!", state.path)`, root, map[string]any{root: input}, false, write)
+	if err == nil {
+		t.Error("expected error decoding invalid base64")
+	}
+	_, err = os.Stat(path)
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected partial file to be removed: %v", err)
+	}
+}
+
+func TestBodyFromFile(t *testing.T) {
+	data := make([]byte, 1<<20)
+	_, err := mathrand.New(mathrand.NewSource(1)).Read(data)
+	if err != nil {
+		t.Fatalf("unexpected error generating data: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "upload.bin")
+	err = os.WriteFile(path, data, 0o600)
+	if err != nil {
+		t.Fatalf("unexpected error writing file: %v", err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.ContentLength != int64(len(body)) {
+			http.Error(w, fmt.Sprintf("content length mismatch: %d != %d", req.ContentLength, len(body)), http.StatusBadRequest)
+			return
+		}
+		if !bytes.Equal(body, data) {
+			http.Error(w, "body mismatch", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, len(body))
+	}))
+	defer srv.Close()
+
+	src := fmt.Sprintf(`post_request(%q, "application/octet-stream", "").body_from_file(%q).do_request().as(r, {
+	"status": r.StatusCode,
+	"body": string(r.Body),
+})`, srv.URL, path)
+	got, _, err := eval(src, "", nil, false, lib.HTTP(srv.Client(), nil, nil), lib.Collections())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := fmt.Sprintf("{\n\t\"body\": \"%d\",\n\t\"status\": 200\n}", len(data))
+	if got != want {
+		t.Errorf("unexpected result: got:- want:+\n%v", cmp.Diff(got, want))
+	}
+}
+
+func TestBodyFromFileWaitError(t *testing.T) {
+	fds, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Skipf("cannot count open files: %v", err)
+	}
+	before := len(fds)
+
+	path := filepath.Join(t.TempDir(), "upload.bin")
+	err = os.WriteFile(path, []byte("data"), 0o600)
+	if err != nil {
+		t.Fatalf("unexpected error writing file: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	httpLib := lib.HTTPWithContext(ctx, nil, nil, nil)
+
+	const n = 20
+	for _, call := range []string{`do_request()`, `do_request({"max_attempts": 2})`} {
+		src := fmt.Sprintf(`post_request("http://localhost", "application/octet-stream", "").body_from_file(%q).%s`, path, call)
+		for i := 0; i < n; i++ {
+			_, _, err := eval(src, "", nil, false, httpLib)
+			if err == nil || !strings.Contains(err.Error(), "context canceled") {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+	}
+
+	fds, err = os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Fatalf("unexpected error counting open files: %v", err)
+	}
+	// Allow for files opened by the runtime.
+	if after := len(fds); after >= before+n {
+		t.Errorf("leaked file descriptors: before:%d after:%d", before, after)
+	}
+}
+
+func TestIdempotencyKeys(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		keys = make(map[string][]string)
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		keys[req.URL.Path] = append(keys[req.URL.Path], req.Header.Get("Idempotency-Key"))
+		n := len(keys[req.URL.Path])
+		mu.Unlock()
+		if req.URL.Path == "/flaky" && n < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(req.Body)
+		fmt.Fprintf(w, "ok %s", body)
+	}))
+	defer srv.Close()
+
+	src := fmt.Sprintf(`[
+	request("POST", %[1]q+"/flaky", "data").do_request({"max_attempts": 5, "initial_backoff": duration("1ms")}),
+	request("POST", %[1]q+"/other", "other data").do_request({}),
+	request("GET", %[1]q+"/get").do_request({}),
+].map(r, [string(r.Body), r.Attempts])`, srv.URL)
+	got, _, err := eval(src, "", nil, false, lib.HTTPWithOptions(context.Background(), srv.Client(), nil, nil, lib.HTTPIdempotencyKeys()), lib.Collections())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `[
+	[
+		"ok data",
+		3
+	],
+	[
+		"ok other data",
+		1
+	],
+	[
+		"ok ",
+		1
+	]
+]`
+	if got != want {
+		t.Errorf("unexpected result: got:- want:+\n%v", cmp.Diff(got, want))
+	}
+
+	flaky := keys["/flaky"]
+	if len(flaky) != 3 {
+		t.Fatalf("unexpected number of requests: got:%d want:3", len(flaky))
+	}
+	for i, k := range flaky {
+		if k == "" {
+			t.Errorf("missing idempotency key on attempt %d", i+1)
+		}
+		if k != flaky[0] {
+			t.Errorf("idempotency key changed on attempt %d: got:%s want:%s", i+1, k, flaky[0])
+		}
+	}
+	if other := keys["/other"]; len(other) != 1 || other[0] == "" || other[0] == flaky[0] {
+		t.Errorf("unexpected idempotency key for distinct request: %q", other)
+	}
+	if get := keys["/get"]; len(get) != 1 || get[0] != "" {
+		t.Errorf("unexpected idempotency key for GET request: %q", get)
+	}
+}
+
+func TestResponseHeaders(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("X-Keep", "kept")
+		w.Header().Set("X-Drop", "dropped")
+		w.Write([]byte("hello"))
+	}))
+	defer srv.Close()
+
+	src := fmt.Sprintf(`[get(%[1]q), request("GET", %[1]q).do_request()].map(r, r.Header)`, srv.URL)
+	for _, test := range []struct {
+		name    string
+		headers []string
+		want    []string
+	}{
+		{name: "default", headers: nil, want: []string{"Content-Length", "Content-Type", "Date", "X-Drop", "X-Keep"}},
+		{name: "allowed", headers: []string{"x-keep", "Content-Type"}, want: []string{"Content-Type", "X-Keep"}},
+		{name: "none", headers: []string{}, want: []string{}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, got, err := eval(src, "", nil, false,
+				lib.HTTPWithOptions(context.Background(), srv.Client(), nil, nil, lib.HTTPResponseHeaders(test.headers)),
+				lib.Collections(),
+			)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for i, h := range got.([]any) {
+				keys := []string{}
+				for k := range h.(map[string]any) {
+					keys = append(keys, k)
+				}
+				sort.Strings(keys)
+				if !cmp.Equal(keys, test.want) {
+					t.Errorf("unexpected headers for response %d: got:- want:+\n%v", i, cmp.Diff(keys, test.want))
+				}
+			}
+		})
+	}
+}
+
+func TestAutoDecode(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.Header.Get("Accept") {
+		case "application/json":
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.Write([]byte(`{"greeting":"hello","count":2}`))
+		case "application/xml":
+			w.Header().Set("Content-Type", "application/xml")
+			w.Write([]byte(`<doc><greeting>hello</greeting></doc>`))
+		case "text/csv":
+			w.Header().Set("Content-Type", "text/csv; header=present")
+			w.Write([]byte("greeting,count\nhello,2\n"))
+		default:
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte("hello"))
+		}
+	}))
+	defer srv.Close()
+
+	for _, test := range []struct {
+		name    string
+		accept  string
+		want    any
+		wantErr string
+	}{
+		{
+			name:   "json",
+			accept: "application/json",
+			want:   map[string]any{"greeting": "hello", "count": 2.0},
+		},
+		{
+			name:   "xml",
+			accept: "application/xml",
+			want:   map[string]any{"doc": map[string]any{"doc": map[string]any{"#text": "hello", "greeting": "hello"}}},
+		},
+		{
+			name:   "csv",
+			accept: "text/csv",
+			want:   []any{map[string]any{"greeting": "hello", "count": "2"}},
+		},
+		{
+			name:    "unsupported",
+			accept:  "text/plain",
+			wantErr: "auto_decode: unsupported content type: text/plain",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			src := fmt.Sprintf(`get_request(%q).accept(%q).do_request().auto_decode()`, srv.URL, test.accept)
+			_, got, err := eval(src, "", nil, false, lib.HTTP(srv.Client(), nil, nil))
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Errorf("unexpected error: got:%v want:%s", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !cmp.Equal(got, test.want) {
+				t.Errorf("unexpected result: got:- want:+\n%v", cmp.Diff(got, test.want))
+			}
+		})
+	}
+}
+
+func TestTimeWithClock(t *testing.T) {
+	clock := func() time.Time {
+		return time.Date(2024, 6, 15, 13, 45, 30, 0, time.UTC)
+	}
+	_, got, err := eval(`[now, now()]`, "", nil, false, lib.TimeWithClock(clock))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []any{"2024-06-15T13:45:30Z", "2024-06-15T13:45:30Z"}
+	if !cmp.Equal(got, want) {
+		t.Errorf("unexpected result: got:- want:+\n%v", cmp.Diff(got, want))
+	}
+}
+
+func TestTryWithClock(t *testing.T) {
+	clock := func() time.Time {
+		return time.Date(2024, 6, 15, 13, 45, 30, 0, time.UTC)
+	}
+	_, got, err := eval(`quarantine({"id": 1}, "missing user")`, "", nil, false, lib.TryWithClock(clock))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]any{
+		"@timestamp": "2024-06-15T13:45:30Z",
+		"error":      map[string]any{"message": "missing user", "reason": "missing user"},
+		"event":      map[string]any{"id": 1.0},
+	}
+	if !cmp.Equal(got, want) {
+		t.Errorf("unexpected result: got:- want:+\n%v", cmp.Diff(got, want))
+	}
+}
+
+func TestTar(t *testing.T) {
+	modTime := time.Date(2022, 4, 14, 11, 39, 32, 0, time.UTC)
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, f := range []struct {
+		name string
+		data string
+	}{
+		{name: "a.txt", data: "hello world!\n"},
+		{name: "subdir/b.txt", data: "goodbye\n"},
+	} {
+		err := tw.WriteHeader(&tar.Header{
+			Name:    f.name,
+			Mode:    0o644,
+			Size:    int64(len(f.data)),
+			ModTime: modTime,
+		})
+		if err != nil {
+			t.Fatalf("failed to write tar header: %v", err)
+		}
+		_, err = tw.Write([]byte(f.data))
+		if err != nil {
+			t.Fatalf("failed to write tar data: %v", err)
+		}
+	}
+	err := tw.Close()
+	if err != nil {
+		t.Fatalf("failed to close tar writer: %v", err)
+	}
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	gw.Write(buf.Bytes())
+	err = gw.Close()
+	if err != nil {
+		t.Fatalf("failed to close gzip writer: %v", err)
+	}
+
+	want := map[string]any{
+		"File": []any{
+			map[string]any{
+				"Name":    "a.txt",
+				"Size":    13.0,
+				"Mode":    420.0,
+				"ModTime": "2022-04-14T11:39:32Z",
+				"IsDir":   false,
+				"Data":    base64.StdEncoding.EncodeToString([]byte("hello world!\n")),
+			},
+			map[string]any{
+				"Name":    "subdir/b.txt",
+				"Size":    8.0,
+				"Mode":    420.0,
+				"ModTime": "2022-04-14T11:39:32Z",
+				"IsDir":   false,
+				"Data":    base64.StdEncoding.EncodeToString([]byte("goodbye\n")),
+			},
+		},
+	}
+	for _, test := range []struct {
+		name string
+		data []byte
+	}{
+		{name: "tar", data: buf.Bytes()},
+		{name: "tar_gzip", data: gz.Bytes()},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, got, err := eval(`state.mime("application/x-tar")`, root, map[string]any{root: test.data}, false,
+				lib.MIME(map[string]interface{}{"application/x-tar": lib.Tar}),
+			)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !cmp.Equal(got, want) {
+				t.Errorf("unexpected result: got:- want:+\n%v", cmp.Diff(got, want))
+			}
+		})
+	}
+}
+
+func TestZipGlob(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range []struct {
+		name string
+		data string
+	}{
+		{name: "a.txt", data: "hello world!\n"},
+		{name: "b.json", data: `{"hello":"world"}`},
+		{name: "subdir/c.txt", data: "goodbye\n"},
+	} {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			t.Fatalf("failed to create zip entry: %v", err)
+		}
+		_, err = w.Write([]byte(f.data))
+		if err != nil {
+			t.Fatalf("failed to write zip data: %v", err)
+		}
+	}
+	err := zw.Close()
+	if err != nil {
+		t.Fatalf("failed to close zip writer: %v", err)
+	}
+
+	txt, err := lib.ZipGlob("*.txt")
+	if err != nil {
+		t.Fatalf("unexpected error constructing transform: %v", err)
+	}
+	_, err = lib.ZipGlob("[")
+	if err == nil {
+		t.Error("expected error for malformed pattern")
+	}
+	mime := lib.MIME(map[string]interface{}{
+		"application/zip; data=absent": lib.ZipMetadata,
+		"application/zip; data=txt":    txt,
+	})
+
+	for _, test := range []struct {
+		name     string
+		mimetype string
+		want     map[string]any
+	}{
+		{
+			name:     "metadata",
+			mimetype: "application/zip; data=absent",
+			want:     map[string]any{"a.txt": nil, "b.json": nil, "subdir/c.txt": nil},
+		},
+		{
+			name:     "glob",
+			mimetype: "application/zip; data=txt",
+			want: map[string]any{
+				"a.txt":        base64.StdEncoding.EncodeToString([]byte("hello world!\n")),
+				"b.json":       nil,
+				"subdir/c.txt": nil,
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			src := fmt.Sprintf(`state.mime(%q).File.map(f, [f.Name, has(f.Data) ? f.Data : null])`, test.mimetype)
+			_, got, err := eval(src, root, map[string]any{root: buf.Bytes()}, false, mime)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			files := make(map[string]any)
+			for _, f := range got.([]any) {
+				f := f.([]any)
+				files[f[0].(string)] = f[1]
+			}
+			if !cmp.Equal(files, test.want) {
+				t.Errorf("unexpected result: got:- want:+\n%v", cmp.Diff(files, test.want))
+			}
+		})
+	}
+}
+
+func TestHexDump(t *testing.T) {
+	data := []byte("\x00\x01\x02binary\xfe\xffpayload!\n")
+	src := fmt.Sprintf(`[b"%s".hexdump(), hexdump(b"%[1]s"), "hello".hexdump()]`, escapeBytes(data))
+	_, got, err := eval(src, "", nil, false, lib.Crypto())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []any{hex.Dump(data), hex.Dump(data), hex.Dump([]byte("hello"))}
+	if !cmp.Equal(got, want) {
+		t.Errorf("unexpected result: got:- want:+\n%v", cmp.Diff(got, want))
+	}
+}
+
+// escapeBytes returns b as hex escapes suitable for use in a CEL bytes literal.
+func escapeBytes(b []byte) string {
+	var buf strings.Builder
+	for _, c := range b {
+		fmt.Fprintf(&buf, `\x%02x`, c)
+	}
+	return buf.String()
+}
+
+func TestMutualTLS(t *testing.T) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate CA key: %v", err)
+	}
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "mito test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("failed to create CA certificate: %v", err)
+	}
+	caCert, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatalf("failed to parse CA certificate: %v", err)
+	}
+	clientKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate client key: %v", err)
+	}
+	clientDER, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "mito test client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, caCert, &clientKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("failed to create client certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(clientKey)
+	if err != nil {
+		t.Fatalf("failed to marshal client key: %v", err)
+	}
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(caCert)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/token" {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"access_token":"token","token_type":"bearer"}`)
+			return
+		}
+		fmt.Fprintf(w, "hello %s", req.TLS.PeerCertificates[0].Subject.CommonName)
+		if auth := req.Header.Get("Authorization"); auth != "" {
+			fmt.Fprintf(w, " with %s", auth)
+		}
+	}))
+	srv.TLS = &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  clientCAs,
+	}
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	err = os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0o600)
+	if err != nil {
+		t.Fatalf("failed to write CA file: %v", err)
+	}
+	certPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: clientDER}))
+	keyPEM := string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}))
+
+	for _, test := range []struct {
+		name    string
+		cfg     tlsConfig
+		oauth2  bool
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "client_cert",
+			cfg:  tlsConfig{Cert: certPEM, Key: keyPEM, CAFile: caFile},
+			want: `"hello mito test client"`,
+		},
+		{
+			name:    "no_client_cert",
+			cfg:     tlsConfig{CAFile: caFile},
+			wantErr: true,
+		},
+		{
+			name:   "oauth2_client_cert",
+			cfg:    tlsConfig{Cert: certPEM, Key: keyPEM, CAFile: caFile},
+			oauth2: true,
+			want:   `"hello mito test client with Bearer token"`,
+		},
+		{
+			name:    "oauth2_no_client_cert",
+			cfg:     tlsConfig{CAFile: caFile},
+			oauth2:  true,
+			wantErr: true,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			tc, err := test.cfg.clientConfig()
+			if err != nil {
+				t.Fatalf("unexpected error building TLS config: %v", err)
+			}
+			client, err := setClientTLS(&http.Client{Transport: &http.Transport{}}, tc, false)
+			if err != nil {
+				t.Fatalf("unexpected error setting client TLS: %v", err)
+			}
+			if test.oauth2 {
+				client, err = oAuth2Client(oAuth2{User: "user", Password: "pass", TokenURL: srv.URL + "/token"}, client)
+				if err != nil {
+					if !test.wantErr {
+						t.Fatalf("unexpected error building OAuth2 client: %v", err)
+					}
+					return
+				}
+			}
+			got, _, err := eval(fmt.Sprintf(`string(get(%q).Body)`, srv.URL), "", nil, false, lib.HTTP(client, nil, nil))
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("expected error, got: %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != test.want {
+				t.Errorf("unexpected result: got:%s want:%s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSetClientTLS(t *testing.T) {
+	orig := http.DefaultTransport.(*http.Transport).TLSClientConfig
+	client, err := setClientTLS(nil, &tls.Config{ServerName: "example.com"}, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if client == http.DefaultClient {
+		t.Error("unexpected use of default client")
+	}
+	if http.DefaultTransport.(*http.Transport).TLSClientConfig != orig {
+		t.Error("unexpected mutation of default transport")
+	}
+	tc := client.Transport.(*http.Transport).TLSClientConfig
+	if tc.ServerName != "example.com" || !tc.InsecureSkipVerify {
+		t.Errorf("unexpected TLS config: server name:%q insecure:%t", tc.ServerName, tc.InsecureSkipVerify)
+	}
+
+	_, err = setClientTLS(&http.Client{Transport: headerTransport{}}, nil, true)
+	if err == nil {
+		t.Error("expected error for unsupported transport")
+	}
+}
+
+func TestMultipartRequest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		err := req.ParseMultipartForm(1 << 20)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		parts := make(map[string]interface{})
+		for name, vals := range req.MultipartForm.Value {
+			parts[name] = vals
+		}
+		for name, files := range req.MultipartForm.File {
+			var got []map[string]string
+			for _, fh := range files {
+				f, err := fh.Open()
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				b, _ := io.ReadAll(f)
+				f.Close()
+				got = append(got, map[string]string{
+					"filename":     fh.Filename,
+					"content_type": fh.Header.Get("Content-Type"),
+					"content":      string(b),
+				})
+			}
+			parts[name] = got
+		}
+		json.NewEncoder(w).Encode(parts)
+	}))
+	defer srv.Close()
+
+	src := fmt.Sprintf(`bytes(multipart_request(%q, {
+	"description": "test file",
+	"file": {
+		"filename": "test.txt",
+		"content": b"hello world!",
+		"content_type": "text/plain",
+	},
+	"data": {
+		"filename": "data.bin",
+		"content": "\x00\x01",
+	},
+}).do_request().Body).decode_json()`, srv.URL)
+	got, _, err := eval(src, "", nil, false, lib.HTTP(srv.Client(), nil, nil), lib.JSON(nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `{
+	"data": [
+		{
+			"content": "\u0000\u0001",
+			"content_type": "application/octet-stream",
+			"filename": "data.bin"
+		}
+	],
+	"description": [
+		"test file"
+	],
+	"file": [
+		{
+			"content": "hello world!",
+			"content_type": "text/plain",
+			"filename": "test.txt"
+		}
+	]
+}`
+	if got != want {
+		t.Errorf("unexpected result: got:- want:+\n%v", cmp.Diff(got, want))
+	}
+}